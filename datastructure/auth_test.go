@@ -0,0 +1,64 @@
+package datastructure
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuthBeforeArgs(t *testing.T) {
+	m, c := start(t)
+	m.RequireAuth("secret")
+
+	// the arguments are not looked at before AUTH
+	for _, args := range [][]string{
+		{"INCR", "k"},
+		{"DECR", "k"},
+		{"INCRBY", "k", "abc"},
+		{"DECRBY", "k", "abc"},
+		{"DECRBY", "k", "-9223372036854775808"},
+	} {
+		c.must(`-NOAUTH Authentication required.`, args...)
+	}
+
+	c.must(`+OK`, "AUTH", "secret")
+	c.must("-"+msgInvalidInt, "INCRBY", "k", "abc")
+	c.must(`-ERR decrement would overflow`, "DECRBY", "k", "-9223372036854775808")
+	c.must(`:5`, "INCRBY", "k", "5")
+}
+
+func TestAuth(t *testing.T) {
+	m, c := start(t)
+	c.must(`-ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?`, "AUTH", "pw")
+	c.must(`+OK`, "SET", "a", "1")
+
+	m.RequireAuth("secret")
+	m.RequireUserAuth("bob", "bobpw")
+	// already connected clients need to authenticate as well
+	c.must(`-NOAUTH Authentication required.`, "GET", "a")
+	c.must(`-NOAUTH Authentication required.`, "MULTI")
+	c.must(`-NOAUTH Authentication required.`, "CLIENT", "ID")
+	c.must(`-NOAUTH Authentication required.`, "PING")
+
+	c.must(`-WRONGPASS invalid username-password pair or user is disabled.`, "AUTH", "wrong")
+	c.must(`-WRONGPASS invalid username-password pair or user is disabled.`, "AUTH", "bob", "secret")
+	c.must(`-WRONGPASS invalid username-password pair or user is disabled.`, "AUTH", "nosuch", "secret")
+	c.must(`-ERR wrong number of arguments for 'auth' command`, "AUTH")
+	c.must("-"+msgSyntaxError, "AUTH", "a", "b", "c")
+	c.must(`-NOAUTH Authentication required.`, "GET", "a")
+
+	c.must(`+OK`, "AUTH", "secret")
+	c.must(`$"1"`, "GET", "a")
+
+	// a named user
+	c2 := dial(t, m)
+	c2.must(`+OK`, "AUTH", "bob", "bobpw")
+	c2.must(`$"1"`, "GET", "a")
+	if got := unquote(t, c2.do("CLIENT", "INFO")); !strings.Contains(got, " user=bob ") {
+		t.Errorf("CLIENT INFO: got %q", got)
+	}
+
+	// QUIT works without AUTH, and closes the connection after its reply
+	c3 := dial(t, m)
+	c3.must(`+OK`, "QUIT")
+	expectClosed(t, c3)
+}
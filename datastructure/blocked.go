@@ -0,0 +1,155 @@
+package datastructure
+
+import (
+	"shiny_redis/server"
+	"sort"
+)
+
+// Blocked clients are kept in a queue per key, in the order they started
+// waiting. After every command the queues of the keys which changed are
+// served, first come first served, same as redis does.
+
+// blockedClient is a client waiting in blocking().
+type blockedClient struct {
+	c         *server.Peer
+	ctx       *connCtx
+	keys      []dbKey
+	fn        blockCmd
+	onTimeout func(*server.Peer)
+	done      chan struct{} // closed when fn is done
+}
+
+// waitQueue has the clients waiting on a single key.
+type waitQueue struct {
+	version uint // key version when the queue was last served
+	clients []*blockedClient
+}
+
+// block adds a client to the queues of all its keys. Needs the lock.
+func (m *ShinyRedis) block(c *server.Peer, ctx *connCtx, keys []string, fn blockCmd, onTimeout func(*server.Peer)) *blockedClient {
+	db := m.db(ctx.selectedDB)
+	b := &blockedClient{
+		c:         c,
+		ctx:       ctx,
+		fn:        fn,
+		onTimeout: onTimeout,
+		done:      make(chan struct{}),
+	}
+	for _, key := range keys {
+		k := dbKey{db: db.id, key: key}
+		q, ok := m.blocked[k]
+		if !ok {
+			q = &waitQueue{version: db.keyVersion[key]}
+			m.blocked[k] = q
+		}
+		if q.has(b) {
+			// BLPOP a a
+			continue
+		}
+		q.clients = append(q.clients, b)
+		b.keys = append(b.keys, k)
+	}
+	return b
+}
+
+// unblock removes a client from all its queues. Returns false if it was
+// already removed. Needs the lock.
+func (m *ShinyRedis) unblock(b *blockedClient) bool {
+	found := false
+	for _, k := range b.keys {
+		q, ok := m.blocked[k]
+		if !ok {
+			continue
+		}
+		for i, o := range q.clients {
+			if o == b {
+				q.clients = append(q.clients[:i], q.clients[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if len(q.clients) == 0 {
+			delete(m.blocked, k)
+		}
+	}
+	return found
+}
+
+// gone tells whether the client disconnected while it was waiting. It
+// mustn't be served then, since nobody reads the reply.
+func (b *blockedClient) gone() bool {
+	select {
+	case <-b.c.Gone():
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *waitQueue) has(b *blockedClient) bool {
+	for _, o := range q.clients {
+		if o == b {
+			return true
+		}
+	}
+	return false
+}
+
+// serveBlocked tries the blocked clients waiting on keys which changed since
+// they were last tried, in the order they started waiting. Clients which are
+// done are removed from all their queues. It repeats until nothing changes,
+// since a served command, BLMOVE say, can change other keys. Needs the lock.
+func (m *ShinyRedis) serveBlocked() {
+	for served := true; served; {
+		served = false
+		for _, k := range m.blockedKeys() {
+			q, ok := m.blocked[k]
+			if !ok {
+				continue
+			}
+			db := m.db(k.db)
+			if db.keyVersion[k.key] == q.version {
+				continue
+			}
+			q.version = db.keyVersion[k.key]
+
+			for _, b := range append([]*blockedClient(nil), q.clients...) {
+				// the client might be served via another key already, or
+				// be gone, and then blocking() unblocks it.
+				if !q.has(b) || b.gone() || !b.fn(b.c, b.ctx) {
+					continue
+				}
+				m.unblock(b)
+				close(b.done)
+				served = true
+			}
+		}
+	}
+}
+
+// blockedKeys gives all keys with waiting clients, sorted by db and key.
+// Needs the lock.
+func (m *ShinyRedis) blockedKeys() []dbKey {
+	keys := make([]dbKey, 0, len(m.blocked))
+	for k := range m.blocked {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].db != keys[j].db {
+			return keys[i].db < keys[j].db
+		}
+		return keys[i].key < keys[j].key
+	})
+	return keys
+}
+
+// blockedPeers gives the blocked clients, by peer. Needs the lock.
+func (m *ShinyRedis) blockedPeers() map[*server.Peer]*blockedClient {
+	res := map[*server.Peer]*blockedClient{}
+	for _, q := range m.blocked {
+		for _, b := range q.clients {
+			res[b.c] = b
+		}
+	}
+	return res
+}
@@ -0,0 +1,156 @@
+package datastructure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlpop(t *testing.T) {
+	m, c := start(t)
+	c.must(`:2`, "RPUSH", "b", "x", "y")
+	c.must(`*[$"b" $"x"]`, "BLPOP", "a", "b", "0")
+	c.must(`*[$"b" $"y"]`, "BRPOP", "a", "b", "0")
+	c.must(`:0`, "EXISTS", "b")
+
+	c.must("-"+msgInvalidTimeout, "BLPOP", "a", "x")
+	c.must("-"+msgInvalidTimeout, "BLPOP", "a", "inf")
+	c.must("-"+msgNegTimeout, "BLPOP", "a", "-1")
+	c.must("-"+msgTimeoutOutOfRange, "BLPOP", "a", "1e300")
+	c.must(`-ERR wrong number of arguments for 'blpop' command`, "BLPOP", "a")
+	c.must(`+OK`, "SET", "str", "x")
+	c.must("-"+msgWrongType, "BLPOP", "str", "0")
+
+	// timeouts are in seconds, as a float
+	start := time.Now()
+	c.must(`nilarr`, "BLPOP", "a", "0.1")
+	if d := time.Since(start); d < 100*time.Millisecond || d > 2*time.Second {
+		t.Errorf("BLPOP 0.1 took %s", d)
+	}
+	c.must(`nilarr`, "BRPOP", "a", "0.01")
+
+	// served in the order they started waiting
+	c2 := dial(t, m)
+	c3 := dial(t, m)
+	c2.send("BRPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	c3.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 2)
+	c.must(`:2`, "RPUSH", "q", "1", "2")
+	if got := c2.read(); got != `*[$"q" $"2"]` {
+		t.Errorf("BRPOP: got %q", got)
+	}
+	if got := c3.read(); got != `*[$"q" $"1"]` {
+		t.Errorf("BLPOP: got %q", got)
+	}
+}
+
+func TestBlpopInMulti(t *testing.T) {
+	m, c := start(t)
+
+	// inside MULTI they don't block, an empty list times out right away
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "BLPOP", "a", "0")
+	c.must(`+QUEUED`, "RPUSH", "a", "x")
+	c.must(`+QUEUED`, "BRPOP", "a", "0")
+	c.must(`+QUEUED`, "BLMOVE", "a", "b", "LEFT", "LEFT", "0")
+	c.must(`*[nilarr :1 *[$"a" $"x"] nil]`, "EXEC")
+
+	// bad arguments abort the transaction
+	c.must(`+OK`, "MULTI")
+	c.must("-"+msgNegTimeout, "BLPOP", "a", "-1")
+	c.must(`-EXECABORT Transaction discarded because of previous errors.`, "EXEC")
+
+	// EXEC serves the clients blocked on the keys it changed
+	c2 := dial(t, m)
+	c2.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "RPUSH", "q", "1")
+	c.must(`+QUEUED`, "RPUSH", "q", "2")
+	c.must(`*[:1 :2]`, "EXEC")
+	if got := c2.read(); got != `*[$"q" $"1"]` {
+		t.Errorf("BLPOP: got %q", got)
+	}
+	c.must(`*[$"2"]`, "LRANGE", "q", "0", "-1")
+}
+
+func TestBlockedFIFO(t *testing.T) {
+	m, c := start(t)
+
+	// clients on other keys stay blocked
+	other := dial(t, m)
+	other.send("BLPOP", "other", "0")
+	waitBlocked(t, m, 1)
+
+	var cs []*testConn
+	for i := 0; i < 5; i++ {
+		ci := dial(t, m)
+		ci.send("BLPOP", "q", "0")
+		waitBlocked(t, m, i+2)
+		cs = append(cs, ci)
+	}
+	c.must(`:5`, "RPUSH", "q", "0", "1", "2", "3", "4")
+	for i, ci := range cs {
+		if got, want := ci.read(), `*[$"q" $"`+string(rune('0'+i))+`"]`; got != want {
+			t.Errorf("client %d: got %q, want %q", i, got, want)
+		}
+	}
+	waitBlocked(t, m, 1)
+
+	// a client blocked on several keys is served once, by the first key
+	// which changes
+	c2 := dial(t, m)
+	c2.send("BLPOP", "k1", "k2", "0")
+	waitBlocked(t, m, 2)
+	c.must(`:1`, "RPUSH", "k2", "a")
+	if got := c2.read(); got != `*[$"k2" $"a"]` {
+		t.Errorf("BLPOP: got %q", got)
+	}
+	c.must(`:1`, "RPUSH", "k1", "b")
+	c.must(`:1`, "LLEN", "k1")
+
+	// BLMOVE pushes to a key others wait on
+	c3 := dial(t, m)
+	c4 := dial(t, m)
+	c3.send("BLPOP", "dst", "0")
+	waitBlocked(t, m, 2)
+	c4.send("BLMOVE", "src", "dst", "LEFT", "LEFT", "0")
+	waitBlocked(t, m, 3)
+	c.must(`:1`, "RPUSH", "src", "v")
+	if got := c4.read(); got != `$"v"` {
+		t.Errorf("BLMOVE: got %q", got)
+	}
+	if got := c3.read(); got != `*[$"dst" $"v"]` {
+		t.Errorf("BLPOP: got %q", got)
+	}
+	waitBlocked(t, m, 1)
+}
+
+func TestBlockedDisconnect(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+	c2.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	c2.c.Close()
+	waitBlocked(t, m, 0)
+
+	// the push isn't handed to the closed client
+	c.must(`:1`, "RPUSH", "q", "x")
+	c.must(`:1`, "LLEN", "q")
+
+	// same with CLIENT KILL, and the next client in line gets it
+	c3 := dial(t, m)
+	c4 := dial(t, m)
+	id := c3.do("CLIENT", "ID")[1:]
+	c3.send("BLMOVE", "q2", "dst", "LEFT", "LEFT", "0")
+	waitBlocked(t, m, 1)
+	c4.send("BLPOP", "q2", "0")
+	waitBlocked(t, m, 2)
+	c.must(`:1`, "CLIENT", "KILL", "ID", id)
+	waitBlocked(t, m, 1)
+	c.must(`:1`, "RPUSH", "q2", "y")
+	if got := c4.read(); got != `*[$"q2" $"y"]` {
+		t.Errorf("BLPOP: got %q", got)
+	}
+	c.must(`:0`, "EXISTS", "dst")
+}
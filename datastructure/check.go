@@ -0,0 +1,239 @@
+package datastructure
+
+// Assertion helpers for tests. They call T.Errorf() on a mismatch.
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// T is implemented by testing.T and testing.B.
+type T interface {
+	Helper()
+	Errorf(string, ...interface{})
+}
+
+// CheckGet does not call Errorf() iff there is a string key with the
+// expected value. Normal use case is `m.CheckGet(t, "username", "theking")`.
+func (m *ShinyRedis) CheckGet(t T, key, expected string) {
+	t.Helper()
+
+	found, err := m.Get(key)
+	if err != nil {
+		t.Errorf("GET error, key %#v: %v", key, err)
+		return
+	}
+	if found != expected {
+		t.Errorf("GET error, key %#v: expected %#v, got %#v", key, expected, found)
+	}
+}
+
+// CheckList does not call Errorf() iff there is a list key with the
+// expected values, in that order.
+// Normal use case is `m.CheckList(t, "favorite_colors", "red", "green", "infrared")`.
+func (m *ShinyRedis) CheckList(t T, key string, expected ...string) {
+	t.Helper()
+
+	found, err := m.List(key)
+	if err != nil {
+		t.Errorf("List error, key %#v: %v", key, err)
+		return
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("List error, key %#v:\n%s", key, diffList(expected, found))
+	}
+}
+
+// CheckSet does not call Errorf() iff there is a set key with the expected
+// values. The order doesn't matter.
+// Normal use case is `m.CheckSet(t, "visited", "Rome", "Stockholm", "Dublin")`.
+func (m *ShinyRedis) CheckSet(t T, key string, expected ...string) {
+	t.Helper()
+
+	found, err := m.Members(key)
+	if err != nil {
+		t.Errorf("Set error, key %#v: %v", key, err)
+		return
+	}
+	want := append([]string(nil), expected...)
+	sort.Strings(want)
+	if missing, extra := diffSet(want, found); len(missing)+len(extra) > 0 {
+		t.Errorf("Set error, key %#v: missing %q, unexpected %q", key, missing, extra)
+	}
+}
+
+// CheckHash does not call Errorf() iff there is a hash key with exactly the
+// expected fields and values.
+func (m *ShinyRedis) CheckHash(t T, key string, expected map[string]string) {
+	t.Helper()
+
+	found, err := m.selected().hash(key)
+	if err != nil {
+		t.Errorf("Hash error, key %#v: %v", key, err)
+		return
+	}
+	var diff []string
+	var fields []string
+	for f := range expected {
+		fields = append(fields, f)
+	}
+	for f := range found {
+		fields = append(fields, f)
+	}
+	for _, f := range uniqSorted(fields) {
+		want, wok := expected[f]
+		got, gok := found[f]
+		switch {
+		case !gok:
+			diff = append(diff, fmt.Sprintf("- %q: %q", f, want))
+		case !wok:
+			diff = append(diff, fmt.Sprintf("+ %q: %q", f, got))
+		case got != want:
+			diff = append(diff, fmt.Sprintf("- %q: %q", f, want), fmt.Sprintf("+ %q: %q", f, got))
+		}
+	}
+	if len(diff) > 0 {
+		t.Errorf("Hash error, key %#v:\n%s", key, strings.Join(diff, "\n"))
+	}
+}
+
+// CheckZSet does not call Errorf() iff there is a sorted set key with
+// exactly the expected members and scores.
+func (m *ShinyRedis) CheckZSet(t T, key string, expected map[string]float64) {
+	t.Helper()
+
+	found, err := m.selected().sortedSet(key)
+	if err != nil {
+		t.Errorf("Sorted set error, key %#v: %v", key, err)
+		return
+	}
+	var diff []string
+	var members []string
+	for member := range expected {
+		members = append(members, member)
+	}
+	for member := range found {
+		members = append(members, member)
+	}
+	for _, member := range uniqSorted(members) {
+		want, wok := expected[member]
+		got, gok := found[member]
+		switch {
+		case !gok:
+			diff = append(diff, fmt.Sprintf("- %q: %v", member, want))
+		case !wok:
+			diff = append(diff, fmt.Sprintf("+ %q: %v", member, got))
+		case got != want:
+			diff = append(diff, fmt.Sprintf("- %q: %v", member, want), fmt.Sprintf("+ %q: %v", member, got))
+		}
+	}
+	if len(diff) > 0 {
+		t.Errorf("Sorted set error, key %#v:\n%s", key, strings.Join(diff, "\n"))
+	}
+}
+
+// CheckTTL does not call Errorf() iff the key has the expected TTL. Use 0 to
+// check there is no TTL.
+func (m *ShinyRedis) CheckTTL(t T, key string, expected time.Duration) {
+	t.Helper()
+
+	if !m.Exists(key) {
+		t.Errorf("TTL error, key %#v: %v", key, ErrKeyNotFound)
+		return
+	}
+	if found := m.TTL(key); found != expected {
+		t.Errorf("TTL error, key %#v: expected %v, got %v", key, expected, found)
+	}
+}
+
+// hash gives a copy of a hash key.
+func (db *RedisDB) hash(k string) (map[string]string, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if !db.exists(k) {
+		return nil, ErrKeyNotFound
+	}
+	if db.t(k) != "hash" {
+		return nil, ErrWrongType
+	}
+	res := map[string]string{}
+	for f, v := range db.hashKeys[k] {
+		res[f] = v
+	}
+	return res, nil
+}
+
+// sortedSet gives the members and scores of a sorted set key.
+func (db *RedisDB) sortedSet(k string) (map[string]float64, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if !db.exists(k) {
+		return nil, ErrKeyNotFound
+	}
+	if db.t(k) != "zset" {
+		return nil, ErrWrongType
+	}
+	res := map[string]float64{}
+	for _, e := range db.sortedsetKeys[k].elems() {
+		res[e.member] = e.score
+	}
+	return res, nil
+}
+
+// diffList gives a line per position, marking the positions which differ.
+func diffList(want, got []string) string {
+	n := len(want)
+	if len(got) > n {
+		n = len(got)
+	}
+	var lines []string
+	for i := 0; i < n; i++ {
+		switch {
+		case i >= len(got):
+			lines = append(lines, fmt.Sprintf("- %d: %q", i, want[i]))
+		case i >= len(want):
+			lines = append(lines, fmt.Sprintf("+ %d: %q", i, got[i]))
+		case want[i] != got[i]:
+			lines = append(lines, fmt.Sprintf("- %d: %q", i, want[i]), fmt.Sprintf("+ %d: %q", i, got[i]))
+		default:
+			lines = append(lines, fmt.Sprintf("  %d: %q", i, got[i]))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diffSet gives the elements missing from got, and the elements in got which
+// are not wanted. Both arguments must be sorted.
+func diffSet(want, got []string) ([]string, []string) {
+	var missing, extra []string
+	for len(want) > 0 || len(got) > 0 {
+		switch {
+		case len(got) == 0 || (len(want) > 0 && want[0] < got[0]):
+			missing = append(missing, want[0])
+			want = want[1:]
+		case len(want) == 0 || got[0] < want[0]:
+			extra = append(extra, got[0])
+			got = got[1:]
+		default:
+			want, got = want[1:], got[1:]
+		}
+	}
+	return missing, extra
+}
+
+// uniqSorted sorts the strings and removes the duplicates.
+func uniqSorted(ss []string) []string {
+	sort.Strings(ss)
+	var res []string
+	for i, s := range ss {
+		if i == 0 || s != ss[i-1] {
+			res = append(res, s)
+		}
+	}
+	return res
+}
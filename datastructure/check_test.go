@@ -0,0 +1,74 @@
+package datastructure
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// fakeT records the errors of the Check* helpers.
+type fakeT struct {
+	errs []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errs = append(t.errs, fmt.Sprintf(format, args...))
+}
+
+// expect checks the helper called by fn reported exactly want, or nothing
+// for "".
+func expect(t *testing.T, want string, fn func(T)) {
+	t.Helper()
+	f := &fakeT{}
+	fn(f)
+	switch {
+	case want == "" && len(f.errs) > 0:
+		t.Errorf("unexpected error: %q", f.errs)
+	case want != "" && (len(f.errs) != 1 || f.errs[0] != want):
+		t.Errorf("got %q, want %q", f.errs, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	m, c := start(t)
+	c.must(`+OK`, "SET", "s", "v")
+	c.must(`:3`, "RPUSH", "l", "a", "b", "c")
+	c.must(`:2`, "SADD", "set", "x", "y")
+	c.must(`:2`, "HSET", "h", "f", "1", "g", "2")
+	c.must(`:2`, "ZADD", "z", "1", "a", "2.5", "b")
+	c.must(`:1`, "EXPIRE", "s", "10")
+
+	expect(t, "", func(t T) { m.CheckGet(t, "s", "v") })
+	expect(t, `GET error, key "s": expected "w", got "v"`, func(t T) { m.CheckGet(t, "s", "w") })
+	expect(t, `GET error, key "nosuch": ERR no such key`, func(t T) { m.CheckGet(t, "nosuch", "v") })
+	expect(t, `GET error, key "l": `+msgWrongType, func(t T) { m.CheckGet(t, "l", "v") })
+
+	expect(t, "", func(t T) { m.CheckList(t, "l", "a", "b", "c") })
+	expect(t, "List error, key \"l\":\n  0: \"a\"\n- 1: \"x\"\n+ 1: \"b\"\n+ 2: \"c\"", func(t T) { m.CheckList(t, "l", "a", "x") })
+	expect(t, "List error, key \"l\":\n  0: \"a\"\n  1: \"b\"\n  2: \"c\"\n- 3: \"d\"", func(t T) { m.CheckList(t, "l", "a", "b", "c", "d") })
+	expect(t, `List error, key "s": `+msgWrongType, func(t T) { m.CheckList(t, "s") })
+
+	expect(t, "", func(t T) { m.CheckSet(t, "set", "y", "x") })
+	expect(t, `Set error, key "set": missing ["z"], unexpected ["y"]`, func(t T) { m.CheckSet(t, "set", "x", "z") })
+	expect(t, `Set error, key "nosuch": ERR no such key`, func(t T) { m.CheckSet(t, "nosuch") })
+
+	expect(t, "", func(t T) { m.CheckHash(t, "h", map[string]string{"f": "1", "g": "2"}) })
+	expect(t, "Hash error, key \"h\":\n- \"f\": \"2\"\n+ \"f\": \"1\"\n+ \"g\": \"2\"\n- \"k\": \"3\"",
+		func(t T) { m.CheckHash(t, "h", map[string]string{"f": "2", "k": "3"}) })
+	expect(t, `Hash error, key "s": `+msgWrongType, func(t T) { m.CheckHash(t, "s", nil) })
+
+	expect(t, "", func(t T) { m.CheckZSet(t, "z", map[string]float64{"a": 1, "b": 2.5}) })
+	expect(t, "Sorted set error, key \"z\":\n- \"a\": 2\n+ \"a\": 1\n+ \"b\": 2.5",
+		func(t T) { m.CheckZSet(t, "z", map[string]float64{"a": 2}) })
+	expect(t, `Sorted set error, key "nosuch": ERR no such key`, func(t T) { m.CheckZSet(t, "nosuch", nil) })
+
+	expect(t, "", func(t T) { m.CheckTTL(t, "s", 10*time.Second) })
+	expect(t, "", func(t T) { m.CheckTTL(t, "l", 0) })
+	expect(t, `TTL error, key "s": expected 5s, got 10s`, func(t T) { m.CheckTTL(t, "s", 5*time.Second) })
+	expect(t, `TTL error, key "nosuch": ERR no such key`, func(t T) { m.CheckTTL(t, "nosuch", 0) })
+
+	// testing.T is a T
+	m.CheckGet(t, "s", "v")
+}
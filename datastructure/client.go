@@ -0,0 +1,591 @@
+package datastructure
+
+import (
+	"fmt"
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clientPause is an active CLIENT PAUSE.
+type clientPause struct {
+	until     time.Time
+	writeOnly bool          // WRITE mode, read commands can continue
+	done      chan struct{} // closed by CLIENT UNPAUSE
+}
+
+// commandsClient handles the CLIENT command.
+func commandsClient(m *ShinyRedis) {
+	m.srv.Register("CLIENT", m.cmdClient, -2, "admin noscript random loading stale", 0, 0, 0)
+}
+
+// CLIENT
+func (m *ShinyRedis) cmdClient(c *server.Peer, cmd string, args []string) {
+	if len(args) == 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	switch sub := strings.ToUpper(args[0]); sub {
+	case "ID":
+		m.cmdClientID(c, sub, args[1:])
+	case "SETNAME":
+		m.cmdClientSetName(c, sub, args[1:])
+	case "GETNAME":
+		m.cmdClientGetName(c, sub, args[1:])
+	case "LIST":
+		m.cmdClientList(c, sub, args[1:])
+	case "INFO":
+		m.cmdClientInfo(c, sub, args[1:])
+	case "KILL":
+		m.cmdClientKill(c, sub, args[1:])
+	case "PAUSE":
+		m.cmdClientPause(c, sub, args[1:])
+	case "UNPAUSE":
+		m.cmdClientUnpause(c, sub, args[1:])
+	case "REPLY":
+		m.cmdClientReply(c, sub, args[1:])
+	case "NO-EVICT":
+		m.cmdClientNoEvict(c, sub, args[1:])
+	case "UNBLOCK":
+		m.cmdClientUnblock(c, sub, args[1:])
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try CLIENT HELP.", args[0]))
+	}
+}
+
+// errClientWrongNumber is the error for a CLIENT subcommand with the wrong
+// number of arguments.
+func errClientWrongNumber(sub string) string {
+	return errWrongNumber("client|" + sub)
+}
+
+// CLIENT ID
+func (m *ShinyRedis) cmdClientID(c *server.Peer, sub string, args []string) {
+	if len(args) != 0 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.WriteInt(c.ID())
+	})
+}
+
+// CLIENT SETNAME
+func (m *ShinyRedis) cmdClientSetName(c *server.Peer, sub string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	name := args[0]
+	if !validClientName(name) {
+		setDirty(c)
+		c.WriteError("ERR Client names cannot contain spaces, newlines or special characters.")
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.SetName(name)
+		c.WriteOK()
+	})
+}
+
+// CLIENT GETNAME
+func (m *ShinyRedis) cmdClientGetName(c *server.Peer, sub string, args []string) {
+	if len(args) != 0 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		name := c.Name()
+		if name == "" {
+			c.WriteNull()
+			return
+		}
+		c.WriteBulk(name)
+	})
+}
+
+// CLIENT LIST
+func (m *ShinyRedis) cmdClientList(c *server.Peer, sub string, args []string) {
+	var (
+		withType string
+		withIDs  map[int]bool
+	)
+	for len(args) > 0 {
+		switch opt := strings.ToUpper(args[0]); {
+		case opt == "TYPE" && len(args) == 2:
+			withType = strings.ToLower(args[1])
+			switch withType {
+			case "normal", "master", "replica", "slave", "pubsub":
+			default:
+				setDirty(c)
+				c.WriteError(fmt.Sprintf("ERR Unknown client type '%s'", args[1]))
+				return
+			}
+			args = args[2:]
+		case opt == "ID" && len(args) > 1:
+			withIDs = map[int]bool{}
+			for _, a := range args[1:] {
+				id, err := strconv.Atoi(a)
+				if err != nil || id <= 0 {
+					setDirty(c)
+					c.WriteError("ERR Invalid client ID")
+					return
+				}
+				withIDs[id] = true
+			}
+			args = nil
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		var (
+			b       strings.Builder
+			blocked = m.blockedPeers()
+		)
+		for _, p := range m.srv.Peers() {
+			if withIDs != nil && !withIDs[p.ID()] {
+				continue
+			}
+			if withType != "" && withType != clientType(p) {
+				continue
+			}
+			b.WriteString(m.clientLine(p, blocked[p] != nil))
+			b.WriteString("\n")
+		}
+		c.WriteBulk(b.String())
+	})
+}
+
+// CLIENT INFO
+func (m *ShinyRedis) cmdClientInfo(c *server.Peer, sub string, args []string) {
+	if len(args) != 0 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		line := m.clientLine(c, false) + "\n"
+		if c.Resp3() {
+			c.WriteVerbatim("txt", line)
+			return
+		}
+		c.WriteBulk(line)
+	})
+}
+
+// clientType gives the CLIENT LIST TYPE of a peer. Needs the lock.
+func clientType(p *server.Peer) string {
+	if ctx, ok := p.Ctx.(*connCtx); ok && ctx.subscriber != nil {
+		return "pubsub"
+	}
+	return "normal"
+}
+
+// clientLine gives the CLIENT LIST line of a peer. Needs the lock.
+func (m *ShinyRedis) clientLine(p *server.Peer, blocked bool) string {
+	ctx, ok := p.Ctx.(*connCtx)
+	if !ok {
+		ctx = &connCtx{}
+	}
+
+	var (
+		now                = time.Now()
+		lastCmd, lastTime  = p.LastCmd()
+		flags              string
+		subs, psubs, ssubs int
+		multi              = -1
+		user               = DefaultUsername
+		resp               = 2
+		name               = p.Name()
+	)
+	if inTx(ctx) {
+		flags += "x"
+		multi = len(ctx.transaction)
+	}
+	if blocked {
+		flags += "b"
+	}
+	if sub := ctx.subscriber; sub != nil {
+		flags += "P"
+		subs = len(sub.Channels())
+		psubs = len(sub.Patterns())
+		ssubs = len(sub.ShardChannels())
+	}
+	if ctx.noEvict {
+		flags += "e"
+	}
+	if flags == "" {
+		flags = "N"
+	}
+	if lastCmd == "" {
+		lastCmd = "NULL"
+	}
+	if ctx.user != "" {
+		user = ctx.user
+	}
+	if p.Resp3() {
+		resp = 3
+	}
+
+	return fmt.Sprintf(
+		"id=%d addr=%s laddr=%s name=%s age=%d idle=%d flags=%s db=%d sub=%d psub=%d ssub=%d multi=%d cmd=%s user=%s resp=%d",
+		p.ID(),
+		p.Addr(),
+		p.LocalAddr(),
+		name,
+		int(now.Sub(p.Created()).Seconds()),
+		int(now.Sub(lastTime).Seconds()),
+		flags,
+		ctx.selectedDB,
+		subs,
+		psubs,
+		ssubs,
+		multi,
+		lastCmd,
+		user,
+		resp,
+	)
+}
+
+// CLIENT KILL
+func (m *ShinyRedis) cmdClientKill(c *server.Peer, sub string, args []string) {
+	if len(args) == 0 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	// old style: CLIENT KILL addr:port
+	if len(args) == 1 {
+		addr := args[0]
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			for _, p := range m.srv.Peers() {
+				if p.Addr() == addr {
+					m.killPeer(c, p)
+					c.WriteOK()
+					return
+				}
+			}
+			c.WriteError("ERR No such client")
+		})
+		return
+	}
+
+	if len(args)%2 != 0 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	var (
+		id     int
+		addr   string
+		laddr  string
+		user   string
+		skipMe = true
+	)
+	for i := 0; i < len(args); i += 2 {
+		v := args[i+1]
+		switch strings.ToUpper(args[i]) {
+		case "ID":
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				setDirty(c)
+				c.WriteError("ERR client-id should be greater than 0")
+				return
+			}
+			id = n
+		case "ADDR":
+			addr = v
+		case "LADDR":
+			laddr = v
+		case "USER":
+			user = v
+		case "SKIPME":
+			switch strings.ToLower(v) {
+			case "yes":
+				skipMe = true
+			case "no":
+				skipMe = false
+			default:
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		killed := 0
+		for _, p := range m.srv.Peers() {
+			if skipMe && p == c {
+				continue
+			}
+			if id != 0 && p.ID() != id {
+				continue
+			}
+			if addr != "" && p.Addr() != addr {
+				continue
+			}
+			if laddr != "" && p.LocalAddr() != laddr {
+				continue
+			}
+			if user != "" && peerUser(p) != user {
+				continue
+			}
+			m.killPeer(c, p)
+			killed++
+		}
+		c.WriteInt(killed)
+	})
+}
+
+// peerUser gives the user a peer is authenticated as. Needs the lock.
+func peerUser(p *server.Peer) string {
+	if ctx, ok := p.Ctx.(*connCtx); ok && ctx.user != "" {
+		return ctx.user
+	}
+	return DefaultUsername
+}
+
+// killPeer disconnects a peer. A blocked peer is released without a reply.
+// c itself is closed after its reply is sent. Needs the lock.
+func (m *ShinyRedis) killPeer(c, p *server.Peer) {
+	if p == c {
+		c.Close()
+		return
+	}
+	if b, ok := m.blockedPeers()[p]; ok {
+		m.unblock(b)
+		close(b.done)
+	}
+	p.Kill()
+}
+
+// CLIENT PAUSE
+func (m *ShinyRedis) cmdClientPause(c *server.Peer, sub string, args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	ms, err := strconv.Atoi(args[0])
+	if err != nil {
+		setDirty(c)
+		c.WriteError("ERR timeout is not an integer or out of range")
+		return
+	}
+	if ms < 0 {
+		setDirty(c)
+		c.WriteError(msgNegTimeout)
+		return
+	}
+	writeOnly := false
+	if len(args) == 2 {
+		switch strings.ToUpper(args[1]) {
+		case "WRITE":
+			writeOnly = true
+		case "ALL":
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		m.unpause()
+		m.pause = &clientPause{
+			until:     time.Now().Add(time.Duration(ms) * time.Millisecond),
+			writeOnly: writeOnly,
+			done:      make(chan struct{}),
+		}
+		c.WriteOK()
+	})
+}
+
+// CLIENT UNPAUSE
+func (m *ShinyRedis) cmdClientUnpause(c *server.Peer, sub string, args []string) {
+	if len(args) != 0 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		m.unpause()
+		c.WriteOK()
+	})
+}
+
+// unpause ends a CLIENT PAUSE, if any. Needs the lock.
+func (m *ShinyRedis) unpause() {
+	if m.pause != nil {
+		close(m.pause.done)
+		m.pause = nil
+	}
+}
+
+// waitPause waits while the command is paused by CLIENT PAUSE. CLIENT itself
+// is never paused, so CLIENT UNPAUSE always works. In WRITE mode only the
+// commands which write wait.
+func (m *ShinyRedis) waitPause(s *server.Server, cmd string) {
+	if cmd == "CLIENT" {
+		return
+	}
+	for {
+		m.Lock()
+		p := m.pause
+		m.Unlock()
+		if p == nil {
+			return
+		}
+		if p.writeOnly {
+			if spec, ok := s.Command(cmd); !ok || !spec.HasFlag("write") {
+				return
+			}
+		}
+
+		t := time.NewTimer(time.Until(p.until))
+		select {
+		case <-t.C:
+			m.Lock()
+			if m.pause == p {
+				m.unpause()
+			}
+			m.Unlock()
+		case <-p.done:
+		case <-m.Ctx.Done():
+			t.Stop()
+			return
+		}
+		t.Stop()
+	}
+}
+
+// CLIENT REPLY
+func (m *ShinyRedis) cmdClientReply(c *server.Peer, sub string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	var mode server.ReplyMode
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		mode = server.ReplyOn
+	case "OFF":
+		mode = server.ReplyOff
+	case "SKIP":
+		mode = server.ReplySkip
+	default:
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.SetReplyMode(mode)
+		if mode == server.ReplyOn {
+			c.WriteOK()
+		}
+	})
+}
+
+// CLIENT NO-EVICT
+func (m *ShinyRedis) cmdClientNoEvict(c *server.Peer, sub string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	var on bool
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		on = true
+	case "OFF":
+	default:
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		ctx.noEvict = on
+		c.WriteOK()
+	})
+}
+
+// CLIENT UNBLOCK
+func (m *ShinyRedis) cmdClientUnblock(c *server.Peer, sub string, args []string) {
+	if len(args) != 1 && len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errClientWrongNumber(sub))
+		return
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	withError := false
+	if len(args) == 2 {
+		switch strings.ToUpper(args[1]) {
+		case "TIMEOUT":
+		case "ERROR":
+			withError = true
+		default:
+			setDirty(c)
+			c.WriteError("ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR")
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		for p, b := range m.blockedPeers() {
+			if p.ID() != id {
+				continue
+			}
+			m.unblock(b)
+			if withError {
+				p.WriteError("UNBLOCKED client unblocked via CLIENT UNBLOCK")
+			} else {
+				b.onTimeout(p)
+			}
+			close(b.done)
+			c.WriteInt(1)
+			return
+		}
+		c.WriteInt(0)
+	})
+}
@@ -0,0 +1,194 @@
+package datastructure
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientName(t *testing.T) {
+	_, c := start(t)
+	c.must(`:1`, "CLIENT", "ID")
+	c.must(`nil`, "CLIENT", "GETNAME")
+	c.must(`+OK`, "CLIENT", "SETNAME", "app")
+	c.must(`$"app"`, "CLIENT", "GETNAME")
+	c.must(`-ERR Client names cannot contain spaces, newlines or special characters.`, "CLIENT", "SETNAME", "a b")
+	c.must(`-ERR wrong number of arguments for 'client|id' command`, "CLIENT", "ID", "x")
+	c.must(`-ERR unknown subcommand 'foo'. Try CLIENT HELP.`, "CLIENT", "foo")
+	c.must(`-ERR wrong number of arguments for 'client' command`, "CLIENT")
+}
+
+func TestClientList(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+	c.must(`+OK`, "CLIENT", "SETNAME", "one")
+	c.must(`+OK`, "SELECT", "2")
+	c2.must(`*[$"subscribe" $"ch" :1]`, "SUBSCRIBE", "ch")
+
+	list := c.do("CLIENT", "LIST")
+	lines := strings.Split(strings.TrimSuffix(unquote(t, list), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("CLIENT LIST: got %q", list)
+	}
+	re := regexp.MustCompile(`^id=1 addr=127\.0\.0\.1:\d+ laddr=` + regexp.QuoteMeta(m.Addr()) + ` name=one age=\d+ idle=\d+ flags=N db=2 sub=0 psub=0 ssub=0 multi=-1 cmd=client user=default resp=2$`)
+	if !re.MatchString(lines[0]) {
+		t.Errorf("CLIENT LIST: got %q", lines[0])
+	}
+	if want := " flags=P db=0 sub=1 psub=0 ssub=0 multi=-1 cmd=subscribe "; !strings.Contains(lines[1], want) {
+		t.Errorf("CLIENT LIST: got %q, want %q", lines[1], want)
+	}
+
+	if got := unquote(t, c.do("CLIENT", "LIST", "TYPE", "pubsub")); !strings.HasPrefix(got, "id=2 ") {
+		t.Errorf("CLIENT LIST TYPE pubsub: got %q", got)
+	}
+	if got := unquote(t, c.do("CLIENT", "LIST", "ID", "2", "3")); !strings.HasPrefix(got, "id=2 ") || strings.Count(got, "\n") != 1 {
+		t.Errorf("CLIENT LIST ID: got %q", got)
+	}
+	c.must(`$""`, "CLIENT", "LIST", "TYPE", "master")
+	c.must(`-ERR Unknown client type 'foo'`, "CLIENT", "LIST", "TYPE", "foo")
+	c.must(`-ERR Invalid client ID`, "CLIENT", "LIST", "ID", "x")
+	c.must("-"+msgSyntaxError, "CLIENT", "LIST", "foo")
+
+	// flags for a transaction and a blocked client
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "CLIENT", "INFO")
+	info := c.do("EXEC")
+	if want := " flags=x db=2 sub=0 psub=0 ssub=0 multi=1 "; !strings.Contains(info, want) {
+		t.Errorf("CLIENT INFO in MULTI: got %q", info)
+	}
+	c3 := dial(t, m)
+	c3.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	if got := unquote(t, c.do("CLIENT", "LIST", "ID", "3")); !strings.Contains(got, " flags=b ") {
+		t.Errorf("CLIENT LIST of a blocked client: got %q", got)
+	}
+}
+
+func TestClientKill(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+	c3 := dial(t, m)
+	c2.must(`:2`, "CLIENT", "ID")
+	c3.must(`:3`, "CLIENT", "ID")
+
+	c.must(`:0`, "CLIENT", "KILL", "ID", "99")
+	c.must(`:0`, "CLIENT", "KILL", "ID", "1")
+	c.must(`:1`, "CLIENT", "KILL", "ID", "2")
+	c.must(`-ERR client-id should be greater than 0`, "CLIENT", "KILL", "ID", "0")
+	c.must("-"+msgSyntaxError, "CLIENT", "KILL", "ID", "1", "SKIPME")
+	c.must("-"+msgSyntaxError, "CLIENT", "KILL", "FOO", "1")
+	c.must(`-ERR No such client`, "CLIENT", "KILL", "1.2.3.4:5")
+	expectClosed(t, c2)
+
+	// old style, by address
+	addr := c3.c.LocalAddr().String()
+	c.must(`+OK`, "CLIENT", "KILL", addr)
+	expectClosed(t, c3)
+
+	// USER, and SKIPME no kills ourselves, after the reply
+	dial(t, m).must(`+PONG`, "PING")
+	for i := 0; len(m.srv.Peers()) != 2; i++ {
+		if i == 300 {
+			t.Fatal("killed clients are still connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.must(`:2`, "CLIENT", "KILL", "USER", "default", "SKIPME", "no")
+	expectClosed(t, c)
+}
+
+// expectClosed checks the server closed the connection.
+func expectClosed(t *testing.T, c *testConn) {
+	t.Helper()
+	c.c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := c.rd.ReadByte(); err == nil {
+		t.Error("connection is still open")
+	}
+}
+
+func TestClientPause(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+
+	c.must(`+OK`, "CLIENT", "PAUSE", "100", "WRITE")
+	// reads go on, writes wait
+	c2.must(`nil`, "GET", "a")
+	start := time.Now()
+	c2.must(`+OK`, "SET", "a", "1")
+	if d := time.Since(start); d < 50*time.Millisecond {
+		t.Errorf("SET during a pause took %s", d)
+	}
+
+	c.must(`+OK`, "CLIENT", "PAUSE", "10000")
+	c2.send("GET", "a")
+	time.Sleep(50 * time.Millisecond)
+	c.must(`+OK`, "CLIENT", "UNPAUSE")
+	if got := c2.read(); got != `$"1"` {
+		t.Errorf("GET after UNPAUSE: got %q", got)
+	}
+
+	c.must(`-ERR timeout is not an integer or out of range`, "CLIENT", "PAUSE", "x")
+	c.must("-"+msgNegTimeout, "CLIENT", "PAUSE", "-1")
+	c.must("-"+msgSyntaxError, "CLIENT", "PAUSE", "1", "FOO")
+}
+
+func TestClientReply(t *testing.T) {
+	_, c := start(t)
+	c.send("CLIENT", "REPLY", "OFF")
+	c.send("SET", "a", "1")
+	c.send("GET", "a")
+	c.must(`+OK`, "CLIENT", "REPLY", "ON")
+	c.send("CLIENT", "REPLY", "SKIP")
+	c.send("SET", "a", "2")
+	c.must(`$"2"`, "GET", "a")
+	c.must("-"+msgSyntaxError, "CLIENT", "REPLY", "FOO")
+
+	c.must(`+OK`, "CLIENT", "NO-EVICT", "ON")
+	if got := unquote(t, c.do("CLIENT", "INFO")); !strings.Contains(got, " flags=e ") {
+		t.Errorf("CLIENT INFO: got %q", got)
+	}
+	c.must(`+OK`, "CLIENT", "NO-EVICT", "OFF")
+	c.must("-"+msgSyntaxError, "CLIENT", "NO-EVICT", "FOO")
+}
+
+func TestClientUnblock(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+	c2.must(`:2`, "CLIENT", "ID")
+
+	c.must(`:0`, "CLIENT", "UNBLOCK", "2")
+	c2.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	c.must(`:1`, "CLIENT", "UNBLOCK", "2")
+	if got := c2.read(); got != `nilarr` {
+		t.Errorf("BLPOP after UNBLOCK: got %q", got)
+	}
+
+	c2.send("BLMOVE", "q", "p", "LEFT", "LEFT", "0")
+	waitBlocked(t, m, 1)
+	c.must(`:1`, "CLIENT", "UNBLOCK", "2", "ERROR")
+	if got := c2.read(); got != `-UNBLOCKED client unblocked via CLIENT UNBLOCK` {
+		t.Errorf("BLMOVE after UNBLOCK ERROR: got %q", got)
+	}
+	c.must(`-ERR CLIENT UNBLOCK reason should be TIMEOUT or ERROR`, "CLIENT", "UNBLOCK", "2", "FOO")
+	c.must("-"+msgInvalidInt, "CLIENT", "UNBLOCK", "x")
+
+	// killing a blocked client releases it
+	c2.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	c.must(`:1`, "CLIENT", "KILL", "ID", "2")
+	waitBlocked(t, m, 0)
+	expectClosed(t, c2)
+}
+
+// unquote gives the value of a bulk string reply.
+func unquote(t *testing.T, reply string) string {
+	t.Helper()
+	s, err := strconv.Unquote(strings.TrimPrefix(reply, "$"))
+	if err != nil {
+		t.Fatalf("not a bulk string: %q", reply)
+	}
+	return s
+}
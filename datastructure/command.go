@@ -0,0 +1,188 @@
+package datastructure
+
+import (
+	"fmt"
+	"shiny_redis/server"
+	"strings"
+)
+
+// commandsCommand handles COMMAND and its subcommands
+func commandsCommand(m *ShinyRedis) {
+	m.srv.Register("COMMAND", m.cmdCommand, -1, "random loading stale", 0, 0, 0)
+}
+
+// COMMAND
+func (m *ShinyRedis) cmdCommand(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	if len(args) == 0 {
+		specs := m.srv.Commands()
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(specs))
+			for _, cs := range specs {
+				writeCmdSpec(w, cs)
+			}
+		})
+		return
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "COUNT":
+		if len(args) != 1 {
+			c.WriteError(errWrongNumber("command|count"))
+			return
+		}
+		c.WriteInt(len(m.srv.Commands()))
+	case "INFO":
+		names := args[1:]
+		if len(names) == 0 {
+			for _, cs := range m.srv.Commands() {
+				names = append(names, cs.Name)
+			}
+		}
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(names))
+			for _, n := range names {
+				cs, ok := m.srv.Command(n)
+				if !ok {
+					w.WriteNullArray()
+					continue
+				}
+				writeCmdSpec(w, cs)
+			}
+		})
+	case "GETKEYS":
+		if len(args) < 2 {
+			c.WriteError(errWrongNumber("command|getkeys"))
+			return
+		}
+		cs, ok := m.srv.Command(args[1])
+		if !ok {
+			c.WriteError("ERR Invalid command specified")
+			return
+		}
+		if !cs.ArityOK(len(args) - 1) {
+			c.WriteError("ERR Invalid number of arguments specified for command")
+			return
+		}
+		keys, ok := cs.Keys(args[2:])
+		if !ok {
+			c.WriteError("ERR Invalid arguments specified for command")
+			return
+		}
+		if len(keys) == 0 {
+			c.WriteError("ERR The command has no key arguments")
+			return
+		}
+		c.WriteStrings(keys)
+	default:
+		c.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try COMMAND HELP.", args[0]))
+	}
+}
+
+// writeCmdSpec writes a single COMMAND INFO entry, in the 10 element layout
+// of redis 7, which is the version we report: name, arity, flags, first key,
+// last key, step, ACL categories, tips, key specifications and subcommands.
+// The ACL categories and the key specification are derived from the flags
+// and the key positions, there are no tips and no subcommands.
+func writeCmdSpec(w *server.Writer, cs server.CmdSpec) {
+	w.WriteLen(10)
+	w.WriteBulk(cs.Name)
+	w.WriteInt(cs.Arity)
+	w.WriteSetLen(len(cs.Flags))
+	for _, f := range cs.Flags {
+		w.WriteInline(f)
+	}
+	w.WriteInt(cs.FirstKey)
+	w.WriteInt(cs.LastKey)
+	w.WriteInt(cs.Step)
+
+	cats := aclCategories(cs)
+	w.WriteSetLen(len(cats))
+	for _, c := range cats {
+		w.WriteInline(c)
+	}
+
+	w.WriteLen(0) // tips
+
+	if cs.FirstKey == 0 || cs.HasFlag("movablekeys") {
+		w.WriteLen(0)
+	} else {
+		w.WriteLen(1)
+		writeKeySpec(w, cs)
+	}
+
+	w.WriteLen(0) // subcommands
+}
+
+// aclCategories gives the ACL categories which follow from the command flags.
+func aclCategories(cs server.CmdSpec) []string {
+	var cats []string
+	for _, f := range []struct{ flag, cat string }{
+		{"write", "@write"},
+		{"readonly", "@read"},
+		{"admin", "@admin"},
+		{"pubsub", "@pubsub"},
+		{"blocking", "@blocking"},
+	} {
+		if cs.HasFlag(f.flag) {
+			cats = append(cats, f.cat)
+		}
+	}
+	if cs.HasFlag("admin") {
+		cats = append(cats, "@dangerous")
+	}
+	if cs.HasFlag("fast") {
+		return append(cats, "@fast")
+	}
+	return append(cats, "@slow")
+}
+
+// writeKeySpec writes the key specification for a command with fixed key
+// positions: an index to start at, and a range of keys from there.
+func writeKeySpec(w *server.Writer, cs server.CmdSpec) {
+	var flags []string
+	switch {
+	case cs.HasFlag("write"):
+		flags = []string{"RW"}
+	case cs.HasFlag("readonly"):
+		flags = []string{"RO"}
+	}
+	// redis counts a positive last key from the first key
+	last := cs.LastKey
+	if last > 0 {
+		last -= cs.FirstKey
+	}
+
+	w.WriteMapLen(3)
+	w.WriteBulk("flags")
+	w.WriteSetLen(len(flags))
+	for _, f := range flags {
+		w.WriteInline(f)
+	}
+	w.WriteBulk("begin_search")
+	w.WriteMapLen(2)
+	w.WriteBulk("type")
+	w.WriteBulk("index")
+	w.WriteBulk("spec")
+	w.WriteMapLen(1)
+	w.WriteBulk("index")
+	w.WriteInt(cs.FirstKey)
+	w.WriteBulk("find_keys")
+	w.WriteMapLen(2)
+	w.WriteBulk("type")
+	w.WriteBulk("range")
+	w.WriteBulk("spec")
+	w.WriteMapLen(3)
+	w.WriteBulk("lastkey")
+	w.WriteInt(last)
+	w.WriteBulk("keystep")
+	w.WriteInt(cs.Step)
+	w.WriteBulk("limit")
+	w.WriteInt(0)
+}
@@ -0,0 +1,33 @@
+package datastructure
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestUnknownCommand(t *testing.T) {
+	_, c := start(t)
+	c.must("-ERR unknown command 'foo', with args beginning with: 'a' 'b c' ", "foo", "a", "b c")
+	c.must("-ERR unknown command 'foo', with args beginning with: ", "foo")
+	c.must("-ERR wrong number of arguments for 'llen' command", "llen")
+	c.must("-ERR wrong number of arguments for 'lindex' command", "LINDEX", "a")
+	c.must("-ERR wrong number of arguments for 'get' command", "GET", "a", "b")
+	c.must("+PONG", "PING")
+}
+
+func TestCommand(t *testing.T) {
+	m, c := start(t)
+	c.must(`*[*[$"llen" :2 *[+readonly +fast] :1 :1 :1 *[+@read +@fast] *[] *[*[$"flags" *[+RO] $"begin_search" *[$"type" $"index" $"spec" *[$"index" :1]] $"find_keys" *[$"type" $"range" $"spec" *[$"lastkey" :0 $"keystep" :1 $"limit" :0]]]] *[]] nilarr]`, "COMMAND", "INFO", "llen", "nosuch")
+	c.must(`*[*[$"blpop" :-3 *[+write +noscript +blocking] :1 :-2 :1 *[+@write +@blocking +@slow] *[] *[*[$"flags" *[+RW] $"begin_search" *[$"type" $"index" $"spec" *[$"index" :1]] $"find_keys" *[$"type" $"range" $"spec" *[$"lastkey" :-2 $"keystep" :1 $"limit" :0]]]] *[]]]`, "COMMAND", "INFO", "BLPOP")
+	c.must(`*[*[$"multi" :1 *[+noscript +loading +stale +fast] :0 :0 :0 *[+@fast] *[] *[] *[]]]`, "COMMAND", "INFO", "MULTI")
+	c.must(`*[$"a" $"b"]`, "COMMAND", "GETKEYS", "blpop", "a", "b", "0")
+	c.must(`-ERR The command has no key arguments`, "COMMAND", "GETKEYS", "multi")
+	c.must(`-ERR Invalid command specified`, "COMMAND", "GETKEYS", "nosuch")
+	c.must(`-ERR Invalid number of arguments specified for command`, "COMMAND", "GETKEYS", "llen")
+	c.must("-ERR unknown subcommand 'foo'. Try COMMAND HELP.", "COMMAND", "foo")
+
+	n := len(m.srv.Commands())
+	if got, want := c.do("COMMAND", "COUNT"), ":"+strconv.Itoa(n); got != want {
+		t.Errorf("COMMAND COUNT: got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,305 @@
+package datastructure
+
+import (
+	"fmt"
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+)
+
+// DefaultUsername is the user used by HELLO and AUTH when no username is
+// given.
+const DefaultUsername = "default"
+
+// commandsConnection handles connection commands (HELLO &c.)
+func commandsConnection(m *ShinyRedis) {
+	m.srv.Register("AUTH", m.cmdAuth, -2, "noscript loading stale fast", 0, 0, 0)
+	m.srv.Register("HELLO", m.cmdHello, -1, "noscript loading stale fast", 0, 0, 0)
+	m.srv.Register("PING", m.cmdPing, -1, "stale fast", 0, 0, 0)
+	m.srv.Register("QUIT", m.cmdQuit, -1, "noscript loading stale fast", 0, 0, 0)
+	m.srv.Register("SELECT", m.cmdSelect, 2, "loading stale fast", 0, 0, 0)
+}
+
+// SELECT
+func (m *ShinyRedis) cmdSelect(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if !m.validDB(id) {
+		setDirty(c)
+		c.WriteError(msgDBIndexOutOfRange)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		ctx.selectedDB = id
+		c.WriteOK()
+	})
+}
+
+// PING
+func (m *ShinyRedis) cmdPing(c *server.Peer, cmd string, args []string) {
+	if len(args) > 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+
+	payload := ""
+	if len(args) > 0 {
+		payload = args[0]
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		// PING is allowed in subscribed mode, where RESP2 gets an array
+		if ctx.subscriber != nil && !c.Resp3() {
+			c.Block(func(w *server.Writer) {
+				w.WriteLen(2)
+				w.WriteBulk("pong")
+				w.WriteBulk(payload)
+			})
+			return
+		}
+		if len(args) > 0 {
+			c.WriteBulk(payload)
+			return
+		}
+		c.WriteInline("PONG")
+	})
+}
+
+// AUTH
+func (m *ShinyRedis) cmdAuth(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if len(args) > 2 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	username, password := DefaultUsername, args[0]
+	if len(args) == 2 {
+		username, password = args[0], args[1]
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if _, ok := m.Passwords[DefaultUsername]; len(args) == 1 && !ok {
+			c.WriteError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+			return
+		}
+		if !m.checkPassword(username, password) {
+			c.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+			return
+		}
+		ctx.authenticated = true
+		ctx.user = username
+		c.WriteOK()
+	})
+}
+
+// QUIT
+func (m *ShinyRedis) cmdQuit(c *server.Peer, cmd string, args []string) {
+	// QUIT doesn't care about the arguments, nor about AUTH.
+	c.WriteOK()
+	c.Close()
+}
+
+// handleAuth returns false if the client needs to authenticate first. The
+// NOAUTH error is written. Authentication is needed when the default user
+// has a password.
+func (m *ShinyRedis) handleAuth(c *server.Peer) bool {
+	ctx := getCtx(c)
+	if ctx.nested {
+		return true
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if !m.needsAuth(ctx) {
+		return true
+	}
+	c.WriteError("NOAUTH Authentication required.")
+	return false
+}
+
+// needsAuth tells whether the client still has to authenticate. Needs the
+// lock.
+func (m *ShinyRedis) needsAuth(ctx *connCtx) bool {
+	_, ok := m.Passwords[DefaultUsername]
+	return ok && !ctx.authenticated
+}
+
+// RequireAuth makes every connection need to AUTH first, with the given
+// password for the default user. Same as redis' requirepass.
+func (m *ShinyRedis) RequireAuth(pw string) {
+	m.RequireUserAuth(DefaultUsername, pw)
+}
+
+// RequireUserAuth adds a username/password pair for AUTH. Connections only
+// need to authenticate when the default user has a password.
+func (m *ShinyRedis) RequireUserAuth(username, pw string) {
+	m.Lock()
+	defer m.Unlock()
+	m.Passwords[username] = pw
+}
+
+// HELLO
+func (m *ShinyRedis) cmdHello(c *server.Peer, cmd string, args []string) {
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		version  = 2
+		auth     bool
+		username string
+		password string
+		name     string
+		setname  bool
+	)
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil {
+			setDirty(c)
+			c.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if v != 2 && v != 3 {
+			setDirty(c)
+			c.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		version = v
+		args = args[1:]
+	} else if c.Resp3() {
+		version = 3
+	}
+
+	for len(args) > 0 {
+		switch opt := strings.ToUpper(args[0]); opt {
+		case "AUTH":
+			if len(args) < 3 {
+				setDirty(c)
+				c.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[0]))
+				return
+			}
+			auth, username, password = true, args[1], args[2]
+			args = args[3:]
+		case "SETNAME":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[0]))
+				return
+			}
+			setname, name = true, args[1]
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(fmt.Sprintf("ERR Syntax error in HELLO option '%s'", args[0]))
+			return
+		}
+	}
+
+	if !auth && !m.handleHelloAuth(c) {
+		return
+	}
+	if setname && !validClientName(name) {
+		setDirty(c)
+		c.WriteError("ERR Client names cannot contain spaces, newlines or special characters.")
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if auth {
+			if !m.checkPassword(username, password) {
+				c.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+				return
+			}
+			ctx.authenticated = true
+			ctx.user = username
+		}
+		if setname {
+			c.SetName(name)
+		}
+
+		c.SetResp3(version == 3)
+
+		c.Block(func(w *server.Writer) {
+			w.WriteMapLen(7)
+			w.WriteBulk("server")
+			w.WriteBulk("redis")
+			w.WriteBulk("version")
+			w.WriteBulk(redisVersion)
+			w.WriteBulk("proto")
+			w.WriteInt(version)
+			w.WriteBulk("id")
+			w.WriteInt(c.ID())
+			w.WriteBulk("mode")
+			w.WriteBulk("standalone")
+			w.WriteBulk("role")
+			w.WriteBulk("master")
+			w.WriteBulk("modules")
+			w.WriteLen(0)
+		})
+	})
+}
+
+// handleHelloAuth is handleAuth() for HELLO without the AUTH option, which
+// has its own error.
+func (m *ShinyRedis) handleHelloAuth(c *server.Peer) bool {
+	ctx := getCtx(c)
+
+	m.Lock()
+	defer m.Unlock()
+	if !m.needsAuth(ctx) {
+		return true
+	}
+	c.WriteError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	return false
+}
+
+// checkPassword checks a username/password pair against m.Passwords. The
+// default user doesn't need a password if none is configured. Needs the lock.
+func (m *ShinyRedis) checkPassword(username, password string) bool {
+	pw, ok := m.Passwords[username]
+	if !ok {
+		return username == DefaultUsername
+	}
+	return pw == password
+}
+
+// validClientName is what redis accepts as a client name: printable ASCII
+// without spaces.
+func validClientName(name string) bool {
+	for _, r := range name {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
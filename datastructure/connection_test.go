@@ -0,0 +1,54 @@
+package datastructure
+
+import "testing"
+
+func TestHello(t *testing.T) {
+	m, c := start(t)
+	c.must(`*[$"server" $"redis" $"version" $"7.0.0" $"proto" :2 $"id" :1 $"mode" $"standalone" $"role" $"master" $"modules" *[]]`, "HELLO")
+	c.must(`%[$"server" $"redis" $"version" $"7.0.0" $"proto" :3 $"id" :1 $"mode" $"standalone" $"role" $"master" $"modules" *[]]`, "HELLO", "3", "SETNAME", "foo")
+	c.must(`_`, "LINDEX", "x", "0")
+	c.must(`$"foo"`, "CLIENT", "GETNAME")
+	c.must(`*[$"server" $"redis" $"version" $"7.0.0" $"proto" :2 $"id" :1 $"mode" $"standalone" $"role" $"master" $"modules" *[]]`, "HELLO", "2")
+	c.must(`nil`, "LINDEX", "x", "0")
+
+	c.must("-NOPROTO unsupported protocol version", "HELLO", "4")
+	c.must("-ERR Protocol version is not an integer or out of range", "HELLO", "x")
+	c.must("-ERR Syntax error in HELLO option 'foo'", "HELLO", "3", "foo")
+	c.must("-ERR Syntax error in HELLO option 'AUTH'", "HELLO", "3", "AUTH", "bob")
+
+	m.Lock()
+	m.Passwords["bob"] = "pw"
+	m.Unlock()
+	c.must("-WRONGPASS invalid username-password pair or user is disabled.", "HELLO", "3", "AUTH", "bob", "x")
+	c.must(`%[$"server" $"redis" $"version" $"7.0.0" $"proto" :3 $"id" :1 $"mode" $"standalone" $"role" $"master" $"modules" *[]]`, "HELLO", "3", "AUTH", "bob", "pw")
+}
+
+func TestHelloMulti(t *testing.T) {
+	_, c := start(t)
+	c.must("+OK", "MULTI")
+	c.must("+QUEUED", "HELLO", "3")
+	c.must("+QUEUED", "LINDEX", "x", "0")
+	c.must(`*[%[$"server" $"redis" $"version" $"7.0.0" $"proto" :3 $"id" :1 $"mode" $"standalone" $"role" $"master" $"modules" *[]] _]`, "EXEC")
+	c.must(`_`, "LINDEX", "x", "0")
+}
+
+func TestHelloSubscribed(t *testing.T) {
+	m, c := start(t)
+	c.must(`*[$"subscribe" $"a" :1]`, "SUBSCRIBE", "a")
+	c.must("-ERR Can't execute 'hello': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", "HELLO", "3")
+
+	c3 := dial(t, m)
+	c3.do("HELLO", "3")
+	c3.must(`>[$"subscribe" $"a" :1]`, "SUBSCRIBE", "a")
+	c3.must(`*[$"server" $"redis" $"version" $"7.0.0" $"proto" :2 $"id" :2 $"mode" $"standalone" $"role" $"master" $"modules" *[]]`, "HELLO", "2")
+	c3.must("-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", "GET", "a")
+}
+
+func TestHelloNoAuth(t *testing.T) {
+	m, c := start(t)
+	m.RequireAuth("secret")
+	c.must("-NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time", "HELLO", "3")
+	c.must("-NOAUTH Authentication required.", "GET", "a")
+	c.must(`%[$"server" $"redis" $"version" $"7.0.0" $"proto" :3 $"id" :1 $"mode" $"standalone" $"role" $"master" $"modules" *[]]`, "HELLO", "3", "AUTH", "default", "secret")
+	c.must(`_`, "GET", "a")
+}
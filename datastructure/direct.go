@@ -0,0 +1,327 @@
+package datastructure
+
+// Commands to modify and query the database directly, without going through
+// a client. They take the lock, and wake up blocked clients.
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrKeyNotFound is returned when a key doesn't exist.
+	ErrKeyNotFound = errors.New(msgKeyNotFound)
+
+	// ErrWrongType is returned when a key holds a different type.
+	ErrWrongType = errors.New(msgWrongType)
+
+	// ErrOddArguments is returned by HSet when there are no fields, or a
+	// field has no value.
+	ErrOddArguments = errors.New("odd number of field/value arguments")
+
+	// ErrScoreNaN is returned by ZAdd for a NaN score.
+	ErrScoreNaN = errors.New(msgInvalidFloat)
+)
+
+// Select sets the DB id for all direct commands.
+func (m *ShinyRedis) Select(i int) {
+	m.Lock()
+	defer m.Unlock()
+	m.selectedDB = i
+}
+
+// DB gives the database with the given id, for direct commands on a
+// specific database.
+func (m *ShinyRedis) DB(i int) *RedisDB {
+	m.Lock()
+	defer m.Unlock()
+	return m.db(i)
+}
+
+// selected gives the currently selected DB.
+func (m *ShinyRedis) selected() *RedisDB {
+	m.Lock()
+	defer m.Unlock()
+	return m.db(m.selectedDB)
+}
+
+// FlushAll removes all keys from all databases.
+func (m *ShinyRedis) FlushAll() {
+	m.Lock()
+	defer m.Unlock()
+	defer m.serveBlocked()
+
+	for _, db := range m.Dbs {
+		db.flush()
+	}
+}
+
+// Keys returns all keys in the selected DB, sorted.
+func (m *ShinyRedis) Keys() []string {
+	return m.selected().Keys()
+}
+
+// Keys returns all keys, sorted.
+func (db *RedisDB) Keys() []string {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	return db.allKeys()
+}
+
+// Exists tells whether a key exists in the selected DB.
+func (m *ShinyRedis) Exists(k string) bool {
+	return m.selected().Exists(k)
+}
+
+// Exists tells whether a key exists.
+func (db *RedisDB) Exists(k string) bool {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	return db.exists(k)
+}
+
+// Del deletes a key and its TTL from the selected DB. Returns whether the
+// key existed.
+func (m *ShinyRedis) Del(k string) bool {
+	return m.selected().Del(k)
+}
+
+// Del deletes a key and its TTL. Returns whether the key existed.
+func (db *RedisDB) Del(k string) bool {
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if !db.exists(k) {
+		return false
+	}
+	db.del(k, true)
+	return true
+}
+
+// SetTTL sets the TTL of a key in the selected DB.
+func (m *ShinyRedis) SetTTL(k string, ttl time.Duration) error {
+	return m.selected().SetTTL(k, ttl)
+}
+
+// SetTTL sets the TTL of an existing key. It's a relative duration, see
+// FastForward(). A TTL <= 0 deletes the key, as EXPIRE does.
+func (db *RedisDB) SetTTL(k string, ttl time.Duration) error {
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if !db.exists(k) {
+		return ErrKeyNotFound
+	}
+	db.setTTL(k, ttl)
+	return nil
+}
+
+// TTL gives the TTL of a key in the selected DB. 0 if no TTL is set.
+func (m *ShinyRedis) TTL(k string) time.Duration {
+	return m.selected().TTL(k)
+}
+
+// TTL gives the TTL of a key. 0 if no TTL is set.
+func (db *RedisDB) TTL(k string) time.Duration {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	return db.ttl[k]
+}
+
+// Get gives the value of a string key in the selected DB.
+func (m *ShinyRedis) Get(k string) (string, error) {
+	return m.selected().Get(k)
+}
+
+// Get gives the value of a string key.
+func (db *RedisDB) Get(k string) (string, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if !db.exists(k) {
+		return "", ErrKeyNotFound
+	}
+	if db.t(k) != "string" {
+		return "", ErrWrongType
+	}
+	return db.stringGet(k), nil
+}
+
+// Set sets a string key in the selected DB. Removes any TTL.
+func (m *ShinyRedis) Set(k, v string) error {
+	return m.selected().Set(k, v)
+}
+
+// Set sets a string key. Removes any TTL.
+func (db *RedisDB) Set(k, v string) error {
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if db.exists(k) && db.t(k) != "string" {
+		return ErrWrongType
+	}
+	db.del(k, true)
+	db.stringSet(k, v)
+	return nil
+}
+
+// Lpush prepends a value to a list in the selected DB. Returns the new
+// length.
+func (m *ShinyRedis) Lpush(k, v string) (int, error) {
+	return m.selected().Lpush(k, v)
+}
+
+// Lpush prepends a value to a list. Returns the new length.
+func (db *RedisDB) Lpush(k, v string) (int, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if db.exists(k) && db.t(k) != "list" {
+		return 0, ErrWrongType
+	}
+	return db.listLpush(k, v), nil
+}
+
+// List gives the elements of a list in the selected DB.
+func (m *ShinyRedis) List(k string) ([]string, error) {
+	return m.selected().List(k)
+}
+
+// List gives the elements of a list.
+func (db *RedisDB) List(k string) ([]string, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if !db.exists(k) {
+		return nil, ErrKeyNotFound
+	}
+	if db.t(k) != "list" {
+		return nil, ErrWrongType
+	}
+	return append([]string(nil), db.listKeys[k]...), nil
+}
+
+// HSet sets field/value pairs of a hash in the selected DB. Returns the
+// number of new fields.
+func (m *ShinyRedis) HSet(k string, fv ...string) (int, error) {
+	return m.selected().HSet(k, fv...)
+}
+
+// HSet sets field/value pairs of a hash. Returns the number of new fields.
+func (db *RedisDB) HSet(k string, fv ...string) (int, error) {
+	if len(fv) == 0 || len(fv)%2 != 0 {
+		return 0, ErrOddArguments
+	}
+
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if db.exists(k) && db.t(k) != "hash" {
+		return 0, ErrWrongType
+	}
+	n := 0
+	for i := 0; i < len(fv); i += 2 {
+		if db.hashSet(k, fv[i], fv[i+1]) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// HGet gives a hash field of a key in the selected DB.
+func (m *ShinyRedis) HGet(k, f string) (string, error) {
+	return m.selected().HGet(k, f)
+}
+
+// HGet gives a hash field. ErrKeyNotFound if the key or the field doesn't
+// exist.
+func (db *RedisDB) HGet(k, f string) (string, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if !db.exists(k) {
+		return "", ErrKeyNotFound
+	}
+	if db.t(k) != "hash" {
+		return "", ErrWrongType
+	}
+	v, ok := db.hashKeys[k][f]
+	if !ok {
+		return "", ErrKeyNotFound
+	}
+	return v, nil
+}
+
+// SetAdd adds elements to a set in the selected DB. Returns the number of
+// new elements.
+func (m *ShinyRedis) SetAdd(k string, elems ...string) (int, error) {
+	return m.selected().SetAdd(k, elems...)
+}
+
+// SetAdd adds elements to a set. Returns the number of new elements. Without
+// elements nothing happens, it doesn't create an empty set.
+func (db *RedisDB) SetAdd(k string, elems ...string) (int, error) {
+	if len(elems) == 0 {
+		return 0, nil
+	}
+
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if db.exists(k) && db.t(k) != "set" {
+		return 0, ErrWrongType
+	}
+	return db.setAdd(k, elems...), nil
+}
+
+// Members gives the sorted members of a set in the selected DB.
+func (m *ShinyRedis) Members(k string) ([]string, error) {
+	return m.selected().Members(k)
+}
+
+// Members gives the sorted members of a set.
+func (db *RedisDB) Members(k string) ([]string, error) {
+	db.master.Lock()
+	defer db.master.Unlock()
+
+	if !db.exists(k) {
+		return nil, ErrKeyNotFound
+	}
+	if db.t(k) != "set" {
+		return nil, ErrWrongType
+	}
+	return db.setMembers(k), nil
+}
+
+// ZAdd adds or updates a member of a sorted set in the selected DB. Returns
+// whether the member is new.
+func (m *ShinyRedis) ZAdd(k string, score float64, member string) (bool, error) {
+	return m.selected().ZAdd(k, score, member)
+}
+
+// ZAdd adds or updates a member of a sorted set. Returns whether the member
+// is new. A NaN score gives ErrScoreNaN.
+func (db *RedisDB) ZAdd(k string, score float64, member string) (bool, error) {
+	if math.IsNaN(score) {
+		return false, ErrScoreNaN
+	}
+
+	db.master.Lock()
+	defer db.master.Unlock()
+	defer db.master.serveBlocked()
+
+	if db.exists(k) && db.t(k) != "zset" {
+		return false, ErrWrongType
+	}
+	return db.ssetAdd(k, score, member), nil
+}
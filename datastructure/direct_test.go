@@ -0,0 +1,216 @@
+package datastructure
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDirectSetTTL(t *testing.T) {
+	m, c := start(t)
+	if err := m.SetTTL("nosuch", time.Minute); err != ErrKeyNotFound {
+		t.Errorf("SetTTL of a missing key: got %v", err)
+	}
+	if got := m.TTL("nosuch"); got != 0 {
+		t.Errorf("TTL of a missing key: got %s", got)
+	}
+
+	// the key was never created, so SET doesn't inherit a TTL
+	c.must(`+OK`, "SET", "nosuch", "1")
+	c.must(`:-1`, "TTL", "nosuch")
+
+	if err := m.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetTTL("a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	c.must(`:60`, "TTL", "a")
+
+	// as EXPIRE with a negative time
+	if err := m.SetTTL("a", 0); err != nil {
+		t.Fatal(err)
+	}
+	if m.Exists("a") {
+		t.Error("SetTTL(0) didn't delete the key")
+	}
+	if got := m.TTL("a"); got != 0 {
+		t.Errorf("TTL after SetTTL(0): got %s", got)
+	}
+}
+
+func TestDirectHash(t *testing.T) {
+	m, _ := start(t)
+	for _, fv := range [][]string{nil, {"f"}, {"f", "v", "g"}} {
+		if _, err := m.HSet("h", fv...); err != ErrOddArguments {
+			t.Errorf("HSet(%q): got %v", fv, err)
+		}
+	}
+	if m.Exists("h") {
+		t.Error("a failed HSet created the key")
+	}
+
+	if n, err := m.HSet("h", "f", "v", "g", "w"); err != nil || n != 2 {
+		t.Errorf("HSet: got %d, %v", n, err)
+	}
+	if n, err := m.HSet("h", "f", "v2", "k", "x"); err != nil || n != 1 {
+		t.Errorf("HSet: got %d, %v", n, err)
+	}
+	if v, err := m.HGet("h", "f"); err != nil || v != "v2" {
+		t.Errorf("HGet: got %q, %v", v, err)
+	}
+	if _, err := m.HGet("h", "nosuch"); err != ErrKeyNotFound {
+		t.Errorf("HGet of a missing field: got %v", err)
+	}
+	if _, err := m.HGet("nosuch", "f"); err != ErrKeyNotFound {
+		t.Errorf("HGet of a missing key: got %v", err)
+	}
+
+	// other types are left alone, as with Set, Lpush, and SetAdd
+	if err := m.Set("str", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.HSet("str", "f", "v"); err != ErrWrongType {
+		t.Errorf("HSet on a string: got %v", err)
+	}
+	if _, err := m.HGet("str", "f"); err != ErrWrongType {
+		t.Errorf("HGet on a string: got %v", err)
+	}
+	if v, err := m.Get("str"); err != nil || v != "x" {
+		t.Errorf("Get: got %q, %v", v, err)
+	}
+}
+
+func TestDirect(t *testing.T) {
+	m, c := start(t)
+
+	if err := m.Set("s", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Get("s"); err != nil || v != "v" {
+		t.Errorf("Get: got %q, %v", v, err)
+	}
+	if _, err := m.Get("nosuch"); err != ErrKeyNotFound {
+		t.Errorf("Get of a missing key: got %v", err)
+	}
+	if n, err := m.Lpush("l", "a"); err != nil || n != 1 {
+		t.Errorf("Lpush: got %d, %v", n, err)
+	}
+	if n, err := m.Lpush("l", "b"); err != nil || n != 2 {
+		t.Errorf("Lpush: got %d, %v", n, err)
+	}
+	if l, err := m.List("l"); err != nil || len(l) != 2 || l[0] != "b" {
+		t.Errorf("List: got %q, %v", l, err)
+	}
+	if n, err := m.SetAdd("set", "b", "a", "b"); err != nil || n != 2 {
+		t.Errorf("SetAdd: got %d, %v", n, err)
+	}
+	if mem, err := m.Members("set"); err != nil || len(mem) != 2 || mem[0] != "a" {
+		t.Errorf("Members: got %q, %v", mem, err)
+	}
+	if isNew, err := m.ZAdd("z", 1.5, "a"); err != nil || !isNew {
+		t.Errorf("ZAdd: got %t, %v", isNew, err)
+	}
+	if isNew, err := m.ZAdd("z", 2, "a"); err != nil || isNew {
+		t.Errorf("ZAdd again: got %t, %v", isNew, err)
+	}
+	if _, err := m.ZAdd("z", math.NaN(), "b"); err != ErrScoreNaN {
+		t.Errorf("ZAdd NaN: got %v", err)
+	}
+	if n, err := m.SetAdd("empty"); err != nil || n != 0 {
+		t.Errorf("SetAdd without elements: got %d, %v", n, err)
+	}
+	if m.Exists("empty") {
+		t.Error("SetAdd without elements made a key")
+	}
+
+	// a client sees all of it
+	c.must(`$"v"`, "GET", "s")
+	c.must(`*[$"b" $"a"]`, "LRANGE", "l", "0", "-1")
+	c.must(`:2`, "SCARD", "set")
+	c.must(`$"2"`, "ZSCORE", "z", "a")
+	c.must(`nil`, "ZSCORE", "z", "b")
+
+	// wrong types everywhere
+	if err := m.Set("l", "x"); err != ErrWrongType {
+		t.Errorf("Set on a list: got %v", err)
+	}
+	if _, err := m.Get("l"); err != ErrWrongType {
+		t.Errorf("Get on a list: got %v", err)
+	}
+	if _, err := m.Lpush("s", "x"); err != ErrWrongType {
+		t.Errorf("Lpush on a string: got %v", err)
+	}
+	if _, err := m.List("s"); err != ErrWrongType {
+		t.Errorf("List on a string: got %v", err)
+	}
+	if _, err := m.SetAdd("s", "x"); err != ErrWrongType {
+		t.Errorf("SetAdd on a string: got %v", err)
+	}
+	if _, err := m.Members("s"); err != ErrWrongType {
+		t.Errorf("Members on a string: got %v", err)
+	}
+	if _, err := m.ZAdd("s", 1, "x"); err != ErrWrongType {
+		t.Errorf("ZAdd on a string: got %v", err)
+	}
+
+	if got := strings.Join(m.Keys(), ","); got != "l,s,set,z" {
+		t.Errorf("Keys: got %q", got)
+	}
+	if !m.Del("s") || m.Del("s") || m.Exists("s") {
+		t.Error("Del")
+	}
+	m.FlushAll()
+	if len(m.Keys()) != 0 {
+		t.Errorf("FlushAll: got %q", m.Keys())
+	}
+}
+
+func TestDirectDB(t *testing.T) {
+	m, c := start(t)
+	m.Select(2)
+	if err := m.Set("a", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if m.DB(0).Exists("a") || !m.DB(2).Exists("a") {
+		t.Error("Set went to the wrong DB")
+	}
+	if err := m.DB(3).Set("b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	c.must(`:0`, "EXISTS", "a")
+	c.must(`+OK`, "SELECT", "3")
+	c.must(`$"2"`, "GET", "b")
+
+	// FlushAll empties all DBs
+	m.FlushAll()
+	c.must(`:0`, "DBSIZE")
+	if m.DB(2).Exists("a") {
+		t.Error("FlushAll left a key")
+	}
+}
+
+func TestDirectWakesBlocked(t *testing.T) {
+	m, c := start(t)
+	c.send("BRPOPLPUSH", "q", "dst", "0")
+	waitBlocked(t, m, 1)
+	if _, err := m.Lpush("q", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.read(); got != `$"x"` {
+		t.Errorf("BRPOPLPUSH: got %q", got)
+	}
+	if m.Exists("q") {
+		t.Error("the blocked client didn't pop")
+	}
+
+	c.send("BZPOPMIN", "z", "0")
+	waitBlocked(t, m, 1)
+	if _, err := m.ZAdd("z", 1, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.read(); got != `*[$"z" $"a" $"1"]` {
+		t.Errorf("BZPOPMIN: got %q", got)
+	}
+}
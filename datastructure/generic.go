@@ -0,0 +1,721 @@
+package datastructure
+
+import (
+	"fmt"
+	"math"
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandsGeneric handles EXPIRE, TTL, PERSIST, DEL, KEYS, &c.
+func commandsGeneric(m *ShinyRedis) {
+	m.srv.Register("COPY", m.cmdCopy, -3, "write denyoom", 1, 2, 1)
+	m.srv.Register("DEL", m.cmdDel, -2, "write", 1, -1, 1)
+	m.srv.Register("EXISTS", m.cmdExists, -2, "readonly fast", 1, -1, 1)
+	m.srv.Register("EXPIRE", m.makeCmdExpire(false, time.Second), -3, "write fast", 1, 1, 1)
+	m.srv.Register("EXPIREAT", m.makeCmdExpire(true, time.Second), -3, "write fast", 1, 1, 1)
+	m.srv.Register("EXPIRETIME", m.makeCmdExpiretime(time.Second), 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("KEYS", m.cmdKeys, 2, "readonly", 0, 0, 0)
+	m.srv.Register("MOVE", m.cmdMove, 3, "write fast", 1, 1, 1)
+	m.srv.Register("OBJECT", m.cmdObject, -2, "readonly random", 2, 2, 1)
+	m.srv.Register("PERSIST", m.cmdPersist, 2, "write fast", 1, 1, 1)
+	m.srv.Register("PEXPIRE", m.makeCmdExpire(false, time.Millisecond), -3, "write fast", 1, 1, 1)
+	m.srv.Register("PEXPIREAT", m.makeCmdExpire(true, time.Millisecond), -3, "write fast", 1, 1, 1)
+	m.srv.Register("PEXPIRETIME", m.makeCmdExpiretime(time.Millisecond), 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("PTTL", m.makeCmdTTL(time.Millisecond), 2, "readonly random fast", 1, 1, 1)
+	m.srv.Register("RANDOMKEY", m.cmdRandomkey, 1, "readonly random", 0, 0, 0)
+	m.srv.Register("RENAME", m.cmdRename, 3, "write", 1, 2, 1)
+	m.srv.Register("RENAMENX", m.cmdRenamenx, 3, "write fast", 1, 2, 1)
+	m.srv.Register("SCAN", m.cmdScan, -2, "readonly random", 0, 0, 0)
+	m.srv.Register("TOUCH", m.cmdTouch, -2, "readonly fast", 1, -1, 1)
+	m.srv.Register("TTL", m.makeCmdTTL(time.Second), 2, "readonly random fast", 1, 1, 1)
+	m.srv.Register("TYPE", m.cmdType, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("UNLINK", m.cmdDel, -2, "write fast", 1, -1, 1)
+}
+
+// makeCmdExpire makes EXPIRE, PEXPIRE, EXPIREAT, and PEXPIREAT. With absolute
+// set the value is a unix timestamp.
+func (m *ShinyRedis) makeCmdExpire(absolute bool, unit time.Duration) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 2 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		key := args[0]
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		if n > math.MaxInt64/int(unit) || n < math.MinInt64/int(unit) {
+			setDirty(c)
+			c.WriteError(errInvalidExpire(cmd))
+			return
+		}
+		var nx, xx, gt, lt bool
+		for _, opt := range args[2:] {
+			switch strings.ToUpper(opt) {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "GT":
+				gt = true
+			case "LT":
+				lt = true
+			default:
+				setDirty(c)
+				c.WriteError("ERR Unsupported option " + opt)
+				return
+			}
+		}
+		if nx && (xx || gt || lt) {
+			setDirty(c)
+			c.WriteError("ERR NX and XX, GT or LT options at the same time are not compatible")
+			return
+		}
+		if gt && lt {
+			setDirty(c)
+			c.WriteError("ERR GT and LT options at the same time are not compatible")
+			return
+		}
+
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			db := m.db(ctx.selectedDB)
+
+			if !db.exists(key) {
+				c.WriteInt(0)
+				return
+			}
+
+			ttl := time.Duration(n) * unit
+			if absolute {
+				ttl = time.Unix(0, 0).Add(ttl).Sub(m.effectiveNow())
+			}
+			// no TTL counts as an infinite TTL
+			old, hasTTL := db.ttl[key]
+			switch {
+			case nx && hasTTL,
+				xx && !hasTTL,
+				gt && (!hasTTL || ttl <= old),
+				lt && hasTTL && ttl >= old:
+				c.WriteInt(0)
+				return
+			}
+			db.setTTL(key, ttl)
+			c.WriteInt(1)
+		})
+	}
+}
+
+// makeCmdTTL makes TTL and PTTL.
+func (m *ShinyRedis) makeCmdTTL(unit time.Duration) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) != 1 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		key := args[0]
+
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			db := m.db(ctx.selectedDB)
+
+			if !db.exists(key) {
+				// -2 if the key does not exist
+				c.WriteInt(-2)
+				return
+			}
+
+			ttl, ok := db.ttl[key]
+			if !ok {
+				// -1 if the key exists but has no associated expire
+				c.WriteInt(-1)
+				return
+			}
+			// rounded, same as redis
+			c.WriteInt(int((ttl + unit/2) / unit))
+		})
+	}
+}
+
+// makeCmdExpiretime makes EXPIRETIME and PEXPIRETIME.
+func (m *ShinyRedis) makeCmdExpiretime(unit time.Duration) server.Cmd {
+	return func(c *server.Peer, cmd string, args []string) {
+		if len(args) != 1 {
+			setDirty(c)
+			c.WriteError(errWrongNumber(cmd))
+			return
+		}
+		if !m.handleAuth(c) {
+			return
+		}
+		if m.checkPubsub(c, cmd) {
+			return
+		}
+
+		key := args[0]
+
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			db := m.db(ctx.selectedDB)
+
+			if !db.exists(key) {
+				c.WriteInt(-2)
+				return
+			}
+
+			ttl, ok := db.ttl[key]
+			if !ok {
+				c.WriteInt(-1)
+				return
+			}
+			at := m.effectiveNow().Add(ttl)
+			if unit == time.Second {
+				c.WriteInt(int(at.Unix()))
+				return
+			}
+			c.WriteInt(int(at.UnixMilli()))
+		})
+	}
+}
+
+// PERSIST
+func (m *ShinyRedis) cmdPersist(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if _, ok := db.ttl[key]; !ok {
+			c.WriteInt(0)
+			return
+		}
+		delete(db.ttl, key)
+		db.keyVersion[key]++
+		c.WriteInt(1)
+	})
+}
+
+// DEL and UNLINK
+func (m *ShinyRedis) cmdDel(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		count := 0
+		for _, key := range args {
+			if db.exists(key) {
+				count++
+			}
+			db.del(key, true)
+		}
+		c.WriteInt(count)
+	})
+}
+
+// EXISTS
+func (m *ShinyRedis) cmdExists(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		// a key given twice counts twice
+		found := 0
+		for _, key := range args {
+			if db.exists(key) {
+				found++
+			}
+		}
+		c.WriteInt(found)
+	})
+}
+
+// TOUCH
+func (m *ShinyRedis) cmdTouch(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		count := 0
+		for _, key := range args {
+			if db.exists(key) {
+				count++
+			}
+		}
+		c.WriteInt(count)
+	})
+}
+
+// TYPE
+func (m *ShinyRedis) cmdType(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInline("none")
+			return
+		}
+		c.WriteInline(db.t(key))
+	})
+}
+
+// RENAME
+func (m *ShinyRedis) cmdRename(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	from, to := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(from) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		if from != to {
+			db.del(to, true)
+			db.move(from, db, to)
+		}
+		c.WriteOK()
+	})
+}
+
+// RENAMENX
+func (m *ShinyRedis) cmdRenamenx(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	from, to := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(from) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		if db.exists(to) {
+			c.WriteInt(0)
+			return
+		}
+		db.move(from, db, to)
+		c.WriteInt(1)
+	})
+}
+
+// COPY
+func (m *ShinyRedis) cmdCopy(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		from, to = args[0], args[1]
+		destDB   = -1
+		replace  bool
+	)
+	args = args[2:]
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "DB":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			if !m.validDB(n) {
+				setDirty(c)
+				c.WriteError(msgDBIndexOutOfRange)
+				return
+			}
+			destDB = n
+			args = args[2:]
+		case "REPLACE":
+			replace = true
+			args = args[1:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+		dst := db
+		if destDB >= 0 {
+			dst = m.db(destDB)
+		}
+
+		if dst == db && from == to {
+			c.WriteError("ERR source and destination objects are the same")
+			return
+		}
+		if !db.exists(from) {
+			c.WriteInt(0)
+			return
+		}
+		if dst.exists(to) {
+			if !replace {
+				c.WriteInt(0)
+				return
+			}
+			dst.del(to, true)
+		}
+		db.copy(from, dst, to)
+		c.WriteInt(1)
+	})
+}
+
+// MOVE
+func (m *ShinyRedis) cmdMove(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if !m.validDB(n) {
+		setDirty(c)
+		c.WriteError(msgDBIndexOutOfRange)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		if n == ctx.selectedDB {
+			c.WriteError("ERR source and destination objects are the same")
+			return
+		}
+		db, dst := m.db(ctx.selectedDB), m.db(n)
+
+		if !db.exists(key) || dst.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		db.move(key, dst, key)
+		c.WriteInt(1)
+	})
+}
+
+// KEYS
+func (m *ShinyRedis) cmdKeys(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	pattern := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		keys := matchKeys(db.allKeys(), pattern)
+		c.WriteStrings(keys)
+	})
+}
+
+// RANDOMKEY
+func (m *ShinyRedis) cmdRandomkey(c *server.Peer, cmd string, args []string) {
+	if len(args) != 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		keys := db.allKeys()
+		if len(keys) == 0 {
+			c.WriteNull()
+			return
+		}
+		c.WriteBulk(keys[m.randIntn(len(keys))])
+	})
+}
+
+// SCAN
+func (m *ShinyRedis) cmdScan(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	opts, msg := parseScanOpts(args, true, false)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		next, keys := scanPage(db.allKeys(), opts)
+		if opts.withType {
+			var typed []string
+			for _, k := range keys {
+				if db.t(k) == opts.typ {
+					typed = append(typed, k)
+				}
+			}
+			keys = typed
+		}
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(2)
+			w.WriteBulk(strconv.Itoa(next))
+			w.WriteStrings(keys)
+		})
+	})
+}
+
+// OBJECT
+func (m *ShinyRedis) cmdObject(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	sub := strings.ToUpper(args[0])
+	switch sub {
+	case "ENCODING", "REFCOUNT", "IDLETIME", "FREQ":
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try OBJECT HELP.", args[0]))
+		return
+	}
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("object|" + sub))
+		return
+	}
+	key := args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		switch sub {
+		case "ENCODING":
+			c.WriteBulk(db.encoding(key))
+		case "REFCOUNT":
+			c.WriteInt(1)
+		case "IDLETIME":
+			// access times are not tracked
+			c.WriteInt(0)
+		case "FREQ":
+			// same as redis with the default maxmemory-policy
+			c.WriteError("ERR An LFU maxmemory policy is not selected, access frequency not tracked. Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+		}
+	})
+}
+
+// encoding gives the OBJECT ENCODING of a key, using the redis 7.0 default
+// size limits.
+func (db *RedisDB) encoding(k string) string {
+	switch db.t(k) {
+	case "string":
+		v := db.stringKeys[k]
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil && len(v) <= 20 {
+			return "int"
+		}
+		if len(v) <= 44 {
+			return "embstr"
+		}
+		return "raw"
+	case "hash":
+		h := db.hashKeys[k]
+		if len(h) > 128 {
+			return "hashtable"
+		}
+		for f, v := range h {
+			if len(f) > 64 || len(v) > 64 {
+				return "hashtable"
+			}
+		}
+		return "listpack"
+	case "list":
+		return "quicklist"
+	case "set":
+		s := db.setKeys[k]
+		if len(s) > 512 {
+			return "hashtable"
+		}
+		for e := range s {
+			if _, err := strconv.ParseInt(e, 10, 64); err != nil {
+				return "hashtable"
+			}
+		}
+		return "intset"
+	case "zset":
+		ss := db.sortedsetKeys[k]
+		if ss.card() > 128 {
+			return "skiplist"
+		}
+		for member := range ss.dict {
+			if len(member) > 64 {
+				return "skiplist"
+			}
+		}
+		return "listpack"
+	default:
+		return "stream"
+	}
+}
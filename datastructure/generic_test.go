@@ -0,0 +1,146 @@
+package datastructure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	m, c := start(t)
+	setNow(m, time.Unix(1000, 0))
+	c.must(`+OK`, "SET", "a", "1")
+	c.must(`:-1`, "TTL", "a")
+	c.must(`:-2`, "TTL", "nosuch")
+	c.must(`:-2`, "EXPIRETIME", "nosuch")
+	c.must(`:-1`, "EXPIRETIME", "a")
+	c.must(`:0`, "EXPIRE", "nosuch", "10")
+	c.must(`:0`, "EXPIRE", "a", "10", "XX")
+	c.must(`:1`, "EXPIRE", "a", "10", "NX")
+	c.must(`:0`, "EXPIRE", "a", "20", "NX")
+	c.must(`:0`, "EXPIRE", "a", "5", "GT")
+	c.must(`:1`, "EXPIRE", "a", "20", "GT")
+	c.must(`:1`, "EXPIRE", "a", "15", "LT")
+	c.must(`:15`, "TTL", "a")
+	c.must(`:15000`, "PTTL", "a")
+	c.must(`:1015`, "EXPIRETIME", "a")
+	c.must(`:1015000`, "PEXPIRETIME", "a")
+	c.must(`-ERR NX and XX, GT or LT options at the same time are not compatible`, "EXPIRE", "a", "1", "NX", "XX")
+	c.must(`-ERR GT and LT options at the same time are not compatible`, "EXPIRE", "a", "1", "GT", "LT")
+	c.must(`-ERR Unsupported option foo`, "EXPIRE", "a", "1", "foo")
+	c.must(`-ERR invalid expire time in 'expire' command`, "EXPIRE", "a", "99999999999999999")
+	c.must(`-`+msgInvalidInt, "EXPIRE", "a", "x")
+
+	m.FastForward(10 * time.Second)
+	c.must(`:5`, "TTL", "a")
+	c.must(`:1`, "PERSIST", "a")
+	c.must(`:0`, "PERSIST", "a")
+	c.must(`:-1`, "TTL", "a")
+	c.must(`:1`, "EXPIREAT", "a", "1020")
+	c.must(`:10`, "TTL", "a")
+	c.must(`:1`, "PEXPIREAT", "a", "1021000")
+	c.must(`:11`, "TTL", "a")
+	c.must(`:1`, "PEXPIRE", "a", "1500")
+	c.must(`:1500`, "PTTL", "a")
+	m.FastForward(2 * time.Second)
+	c.must(`nil`, "GET", "a")
+	c.must(`:-2`, "TTL", "a")
+
+	// a time in the past deletes the key
+	c.must(`+OK`, "SET", "b", "1")
+	c.must(`:1`, "EXPIRE", "b", "-1")
+	c.must(`:-2`, "TTL", "b")
+	c.must(`+OK`, "SET", "b", "1")
+	c.must(`:1`, "EXPIREAT", "b", "10")
+	c.must(`:-2`, "TTL", "b")
+
+	// no TTL counts as infinite for GT and LT
+	c.must(`+OK`, "SET", "b", "1")
+	c.must(`:0`, "EXPIRE", "b", "10", "GT")
+	c.must(`:1`, "EXPIRE", "b", "10", "LT")
+}
+
+func TestFastForward(t *testing.T) {
+	m, c := start(t)
+	c.must(`+OK`, "SET", "a", "1", "EX", "10")
+	c.must(`+OK`, "SET", "b", "1", "EX", "20")
+	c.must(`+OK`, "SELECT", "3")
+	c.must(`+OK`, "SET", "c", "1", "EX", "10")
+
+	m.FastForward(10 * time.Second)
+	c.must(`:0`, "EXISTS", "c")
+	c.must(`+OK`, "SELECT", "0")
+	c.must(`:0`, "EXISTS", "a")
+	c.must(`:10`, "TTL", "b")
+}
+
+func TestKeyspace(t *testing.T) {
+	_, c := start(t)
+	c.must(`+OK`, "MSET", "a", "1", "b", "2", "c", "3")
+	c.must(`:1`, "RPUSH", "l", "x")
+	c.must(`:3`, "EXISTS", "a", "a", "l")
+	c.must(`+string`, "TYPE", "a")
+	c.must(`+list`, "TYPE", "l")
+	c.must(`+none`, "TYPE", "nosuch")
+	c.must(`*[$"a" $"b" $"c"]`, "KEYS", "[a-c]")
+	c.must(`*[$"a" $"b" $"c" $"l"]`, "KEYS", "*")
+	c.must(`*[$"2" *[$"a" $"b"]]`, "SCAN", "0", "COUNT", "2")
+	c.must(`*[$"0" *[$"l"]]`, "SCAN", "2", "COUNT", "2", "TYPE", "list")
+	c.must(`*[$"0" *[$"c"]]`, "SCAN", "0", "MATCH", "c", "COUNT", "100")
+	c.must("-"+msgInvalidCursor, "SCAN", "x")
+	c.must(`:2`, "TOUCH", "c", "l", "nosuch")
+	c.must(`$"int"`, "OBJECT", "ENCODING", "c")
+	c.must(`$"quicklist"`, "OBJECT", "ENCODING", "l")
+	c.must(`nil`, "OBJECT", "ENCODING", "nosuch")
+	c.must(`:1`, "OBJECT", "REFCOUNT", "c")
+	c.must(`:2`, "DEL", "c", "l", "nosuch")
+	c.must(`:1`, "UNLINK", "a")
+	c.must(`$"b"`, "RANDOMKEY")
+	c.must(`:1`, "DEL", "b")
+	c.must(`nil`, "RANDOMKEY")
+}
+
+func TestRename(t *testing.T) {
+	m, c := start(t)
+	c.must(`+OK`, "MSET", "a", "1", "b", "2")
+	c.must(`:1`, "EXPIRE", "a", "100")
+	c.must(`+OK`, "RENAME", "a", "aa")
+	c.must(`:100`, "TTL", "aa")
+	c.must(`:0`, "EXISTS", "a")
+	c.must(`-ERR no such key`, "RENAME", "a", "aa")
+	c.must(`:0`, "RENAMENX", "aa", "b")
+	c.must(`:1`, "RENAMENX", "aa", "a")
+	c.must(`$"1"`, "GET", "a")
+
+	// both keys change, for WATCH
+	c2 := dial(t, m)
+	c.must(`+OK`, "WATCH", "w")
+	c2.must(`+OK`, "RENAME", "b", "w")
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "GET", "w")
+	c.must(`nilarr`, "EXEC")
+}
+
+func TestCopyMove(t *testing.T) {
+	_, c := start(t)
+	c.must(`:1`, "RPUSH", "l", "x")
+	c.must(`:1`, "COPY", "l", "l2")
+	c.must(`:0`, "COPY", "l", "l2")
+	c.must(`:2`, "RPUSH", "l2", "y")
+	c.must(`:1`, "LLEN", "l")
+	c.must(`:1`, "COPY", "l", "l2", "REPLACE")
+	c.must(`:1`, "LLEN", "l2")
+	c.must(`:1`, "COPY", "l", "l2", "DB", "1")
+	c.must("-"+msgDBIndexOutOfRange, "COPY", "l", "l2", "DB", "99")
+	c.must(`-ERR source and destination objects are the same`, "COPY", "l", "l")
+	c.must(`:0`, "COPY", "nosuch", "l3")
+
+	c.must(`+OK`, "SET", "b", "1")
+	c.must(`:1`, "MOVE", "b", "2")
+	c.must(`:0`, "EXISTS", "b")
+	c.must(`:0`, "MOVE", "b", "2")
+	c.must(`-ERR source and destination objects are the same`, "MOVE", "l", "0")
+	c.must(`+OK`, "SELECT", "2")
+	c.must(`$"1"`, "GET", "b")
+	c.must(`+OK`, "SELECT", "1")
+	c.must(`*[$"x"]`, "LRANGE", "l2", "0", "-1")
+}
@@ -0,0 +1,643 @@
+package datastructure
+
+import (
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+)
+
+// commandsHash handles all hash value operations.
+func commandsHash(m *ShinyRedis) {
+	m.srv.Register("HDEL", m.cmdHdel, -3, "write fast", 1, 1, 1)
+	m.srv.Register("HEXISTS", m.cmdHexists, 3, "readonly fast", 1, 1, 1)
+	m.srv.Register("HGET", m.cmdHget, 3, "readonly fast", 1, 1, 1)
+	m.srv.Register("HGETALL", m.cmdHgetall, 2, "readonly random", 1, 1, 1)
+	m.srv.Register("HINCRBY", m.cmdHincrby, 4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("HINCRBYFLOAT", m.cmdHincrbyfloat, 4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("HKEYS", m.cmdHkeys, 2, "readonly", 1, 1, 1)
+	m.srv.Register("HLEN", m.cmdHlen, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("HMGET", m.cmdHmget, -3, "readonly fast", 1, 1, 1)
+	m.srv.Register("HMSET", m.cmdHmset, -4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("HRANDFIELD", m.cmdHrandfield, -2, "readonly random", 1, 1, 1)
+	m.srv.Register("HSCAN", m.cmdHscan, -3, "readonly random", 1, 1, 1)
+	m.srv.Register("HSET", m.cmdHset, -4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("HSETNX", m.cmdHsetnx, 4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("HSTRLEN", m.cmdHstrlen, 3, "readonly fast", 1, 1, 1)
+	m.srv.Register("HVALS", m.cmdHvals, 2, "readonly", 1, 1, 1)
+}
+
+// HSET
+func (m *ShinyRedis) cmdHset(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 || len(args)%2 != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, pairs := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		added := 0
+		for i := 0; i < len(pairs); i += 2 {
+			if db.hashSet(key, pairs[i], pairs[i+1]) {
+				added++
+			}
+		}
+		c.WriteInt(added)
+	})
+}
+
+// HMSET
+func (m *ShinyRedis) cmdHmset(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 || len(args)%2 != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, pairs := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		for i := 0; i < len(pairs); i += 2 {
+			db.hashSet(key, pairs[i], pairs[i+1])
+		}
+		c.WriteOK()
+	})
+}
+
+// HSETNX
+func (m *ShinyRedis) cmdHsetnx(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, field, value := args[0], args[1], args[2]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		if _, ok := db.hashKeys[key][field]; ok {
+			c.WriteInt(0)
+			return
+		}
+		db.hashSet(key, field, value)
+		c.WriteInt(1)
+	})
+}
+
+// HGET
+func (m *ShinyRedis) cmdHget(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, field := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if !ok {
+			c.WriteNull()
+			return
+		}
+		if t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		value, ok := db.hashKeys[key][field]
+		if !ok {
+			c.WriteNull()
+			return
+		}
+		c.WriteBulk(value)
+	})
+}
+
+// HMGET
+func (m *ShinyRedis) cmdHmget(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, fields := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		f := db.hashKeys[key]
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(fields))
+			for _, k := range fields {
+				v, ok := f[k]
+				if !ok {
+					w.WriteNull()
+					continue
+				}
+				w.WriteBulk(v)
+			}
+		})
+	})
+}
+
+// HDEL
+func (m *ShinyRedis) cmdHdel(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, fields := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if !ok {
+			// No key is zero deleted
+			c.WriteInt(0)
+			return
+		}
+		if t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		deleted := 0
+		for _, f := range fields {
+			if _, ok := db.hashKeys[key][f]; !ok {
+				continue
+			}
+			delete(db.hashKeys[key], f)
+			deleted++
+		}
+		c.WriteInt(deleted)
+
+		// Nothing left. Remove the whole key.
+		if len(db.hashKeys[key]) == 0 {
+			db.del(key, true)
+		} else if deleted > 0 {
+			db.keyVersion[key]++
+		}
+	})
+}
+
+// HEXISTS
+func (m *ShinyRedis) cmdHexists(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, field := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if !ok {
+			c.WriteInt(0)
+			return
+		}
+		if t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		if _, ok := db.hashKeys[key][field]; !ok {
+			c.WriteInt(0)
+			return
+		}
+		c.WriteInt(1)
+	})
+}
+
+// HLEN
+func (m *ShinyRedis) cmdHlen(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(len(db.hashKeys[key]))
+	})
+}
+
+// HSTRLEN
+func (m *ShinyRedis) cmdHstrlen(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, field := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(len(db.hashKeys[key][field]))
+	})
+}
+
+// HKEYS
+func (m *ShinyRedis) cmdHkeys(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteStrings(db.hashFields(key))
+	})
+}
+
+// HVALS
+func (m *ShinyRedis) cmdHvals(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var vals []string
+		for _, f := range db.hashFields(key) {
+			vals = append(vals, db.hashKeys[key][f])
+		}
+		c.WriteStrings(vals)
+	})
+}
+
+// HGETALL
+func (m *ShinyRedis) cmdHgetall(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		fields := db.hashFields(key)
+		c.Block(func(w *server.Writer) {
+			w.WriteMapLen(len(fields))
+			for _, f := range fields {
+				w.WriteBulk(f)
+				w.WriteBulk(db.hashKeys[key][f])
+			}
+		})
+	})
+}
+
+// HINCRBY
+func (m *ShinyRedis) cmdHincrby(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, field := args[0], args[1]
+	delta, err := strconv.Atoi(args[2])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v, err := db.hashIncr(key, field, delta)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(v)
+	})
+}
+
+// HINCRBYFLOAT
+func (m *ShinyRedis) cmdHincrbyfloat(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, field := args[0], args[1]
+	delta, err := strconv.ParseFloat(args[2], 64)
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidFloat)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v, err := db.hashIncrfloat(key, field, delta)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteBulk(formatIncrFloat(v))
+	})
+}
+
+// HRANDFIELD
+func (m *ShinyRedis) cmdHrandfield(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 || len(args) > 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key        = args[0]
+		withCount  = len(args) > 1
+		count      int
+		withValues bool
+	)
+	if withCount {
+		n, msg := parseRandomCount(args[1])
+		if msg != "" {
+			setDirty(c)
+			c.WriteError(msg)
+			return
+		}
+		count = n
+	}
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHVALUES" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		withValues = true
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		fields := db.hashFields(key)
+		if !withCount {
+			if len(fields) == 0 {
+				c.WriteNull()
+				return
+			}
+			c.WriteBulk(fields[m.randIntn(len(fields))])
+			return
+		}
+
+		picked := m.randomMembers(fields, count)
+		c.Block(func(w *server.Writer) {
+			if !withValues {
+				w.WriteStrings(picked)
+				return
+			}
+			if w.Resp3() {
+				w.WriteLen(len(picked))
+				for _, f := range picked {
+					w.WriteLen(2)
+					w.WriteBulk(f)
+					w.WriteBulk(db.hashKeys[key][f])
+				}
+				return
+			}
+			w.WriteLen(len(picked) * 2)
+			for _, f := range picked {
+				w.WriteBulk(f)
+				w.WriteBulk(db.hashKeys[key][f])
+			}
+		})
+	})
+}
+
+// HSCAN
+func (m *ShinyRedis) cmdHscan(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseScanOpts(args[1:], false, true)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "hash" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		next, fields := scanPage(db.hashFields(key), opts)
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(2)
+			w.WriteBulk(strconv.Itoa(next))
+			if opts.noValues {
+				w.WriteStrings(fields)
+				return
+			}
+			w.WriteLen(len(fields) * 2)
+			for _, f := range fields {
+				w.WriteBulk(f)
+				w.WriteBulk(db.hashKeys[key][f])
+			}
+		})
+	})
+}
@@ -0,0 +1,94 @@
+package datastructure
+
+import "testing"
+
+func TestHrandfieldCountOverflow(t *testing.T) {
+	_, c := start(t)
+	c.must(":1", "HSET", "h", "f", "v")
+	c.must("-"+msgValueOutOfRange, "HRANDFIELD", "h", "-9223372036854775808")
+	c.must(`*[$"f" $"f"]`, "HRANDFIELD", "h", "-2")
+	c.must(":1", "SADD", "s", "a")
+	c.must("-"+msgValueOutOfRange, "SRANDMEMBER", "s", "-9223372036854775808")
+	c.must(`*[$"a" $"a"]`, "SRANDMEMBER", "s", "-2")
+	c.must("+PONG", "PING")
+}
+
+func TestHash(t *testing.T) {
+	_, c := start(t)
+	c.must(":2", "HSET", "h", "a", "1", "b", "2")
+	c.must(":0", "HSET", "h", "a", "3")
+	c.must("-ERR wrong number of arguments for 'hset' command", "HSET", "h", "a", "3", "b")
+	c.must("+OK", "HMSET", "h", "c", "x")
+	c.must(":0", "HSETNX", "h", "c", "y")
+	c.must(":1", "HSETNX", "h2", "c", "y")
+	c.must(`$"3"`, "HGET", "h", "a")
+	c.must(`nil`, "HGET", "h", "zz")
+	c.must(`nil`, "HGET", "nosuch", "zz")
+	c.must(`*[$"3" nil]`, "HMGET", "h", "a", "zz")
+	c.must(`:3`, "HLEN", "h")
+	c.must(`:1`, "HSTRLEN", "h", "a")
+	c.must(`:1`, "HEXISTS", "h", "a")
+	c.must(`:0`, "HEXISTS", "h", "zz")
+	c.must(`*[$"a" $"b" $"c"]`, "HKEYS", "h")
+	c.must(`*[$"3" $"2" $"x"]`, "HVALS", "h")
+	c.must(`*[$"a" $"3" $"b" $"2" $"c" $"x"]`, "HGETALL", "h")
+	c.must(`:1`, "HDEL", "h", "c", "zz")
+	c.must(`:1`, "HDEL", "h2", "c")
+	c.must(`:0`, "EXISTS", "h2")
+
+	c.must("+OK", "SET", "str", "x")
+	c.must("-"+msgWrongType, "HSET", "str", "a", "1")
+	c.must("-"+msgWrongType, "HGET", "str", "a")
+
+	c.do("HELLO", "3")
+	c.must(`%[$"a" $"3" $"b" $"2"]`, "HGETALL", "h")
+	c.must(`%[]`, "HGETALL", "nosuch")
+}
+
+func TestHincrby(t *testing.T) {
+	_, c := start(t)
+	c.must(`:10`, "HINCRBY", "h", "a", "10")
+	c.must(`:7`, "HINCRBY", "h", "a", "-3")
+	c.must(`-`+msgInvalidInt, "HINCRBY", "h", "a", "x")
+	c.must(`:1`, "HSET", "h", "c", "x")
+	c.must(`-ERR hash value is not an integer`, "HINCRBY", "h", "c", "1")
+	c.must(`:1`, "HSET", "h", "m", "9223372036854775807")
+	c.must(`-`+msgIncrOverflow, "HINCRBY", "h", "m", "1")
+	c.must(`$"7.5"`, "HINCRBYFLOAT", "h", "a", "0.5")
+	c.must(`$"0.1"`, "HINCRBYFLOAT", "h", "f", "0.1")
+	c.must(`-ERR hash value is not a float`, "HINCRBYFLOAT", "h", "c", "0.5")
+}
+
+func TestHrandfield(t *testing.T) {
+	m, c := start(t)
+	m.Seed(1)
+	c.must(`nil`, "HRANDFIELD", "nosuch")
+	c.must(`*[]`, "HRANDFIELD", "nosuch", "3")
+	c.must(":1", "HSET", "h", "a", "1")
+	c.must(`$"a"`, "HRANDFIELD", "h")
+	c.must(`*[$"a"]`, "HRANDFIELD", "h", "10")
+	c.must(`*[$"a" $"a" $"a"]`, "HRANDFIELD", "h", "-3")
+	c.must(`*[$"a" $"1"]`, "HRANDFIELD", "h", "5", "WITHVALUES")
+	c.must(`*[]`, "HRANDFIELD", "h", "0")
+	c.must("-"+msgSyntaxError, "HRANDFIELD", "h", "1", "FOO")
+	c3 := dial(t, m)
+	c3.do("HELLO", "3")
+	c3.must(`*[*[$"a" $"1"] *[$"a" $"1"]]`, "HRANDFIELD", "h", "-2", "WITHVALUES")
+
+	c.must(":1", "HSET", "h", "b", "2")
+	switch got := c.do("HRANDFIELD", "h", "2"); got {
+	case `*[$"a" $"b"]`, `*[$"b" $"a"]`:
+	default:
+		t.Errorf("HRANDFIELD: got %q", got)
+	}
+}
+
+func TestHscan(t *testing.T) {
+	_, c := start(t)
+	c.must(`:4`, "HSET", "h", "a", "1", "b", "2", "c", "x", "d", "e")
+	c.must(`*[$"0" *[$"a" $"1" $"b" $"2" $"c" $"x" $"d" $"e"]]`, "HSCAN", "h", "0")
+	c.must(`*[$"2" *[$"a" $"1"]]`, "HSCAN", "h", "0", "COUNT", "2", "MATCH", "[a]")
+	c.must(`*[$"0" *[$"c" $"d"]]`, "HSCAN", "h", "2", "COUNT", "2", "NOVALUES")
+	c.must(`-ERR invalid cursor`, "HSCAN", "h", "x")
+	c.must(`*[$"0" *[]]`, "HSCAN", "nosuch", "0")
+}
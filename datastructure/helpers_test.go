@@ -0,0 +1,163 @@
+package datastructure
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testConn is a plain connection to a ShinyRedis. Replies are read back as a
+// compact string, so they are easy to compare:
+//
+//	+OK             simple string
+//	-ERR ...        error
+//	:12             integer
+//	$"foo"          bulk string
+//	nil             null bulk string
+//	nilarr          null array
+//	*[:1 $"a"]      array, and the same with %, ~ and > for map, set and push
+//
+// RESP3 scalars (_ , # ( ...) come back as the raw line.
+type testConn struct {
+	t  *testing.T
+	c  net.Conn
+	rd *bufio.Reader
+}
+
+// start runs a ShinyRedis for the duration of the test, with one connection
+// to it.
+func start(t *testing.T) (*ShinyRedis, *testConn) {
+	t.Helper()
+	m, err := Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m, dial(t, m)
+}
+
+// dial makes another connection to m.
+func dial(t *testing.T, m *ShinyRedis) *testConn {
+	t.Helper()
+	c, err := net.Dial("tcp", m.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return &testConn{t: t, c: c, rd: bufio.NewReader(c)}
+}
+
+// send writes a command without waiting for its reply.
+func (c *testConn) send(args ...string) {
+	c.t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := c.c.Write([]byte(b.String())); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+// read reads the next reply.
+func (c *testConn) read() string {
+	c.t.Helper()
+	c.c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	s, err := readReply(c.rd)
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return s
+}
+
+// do sends a command and reads its reply.
+func (c *testConn) do(args ...string) string {
+	c.t.Helper()
+	c.send(args...)
+	return c.read()
+}
+
+// must sends a command and compares its reply.
+func (c *testConn) must(want string, args ...string) {
+	c.t.Helper()
+	if got := c.do(args...); got != want {
+		c.t.Errorf("%q: got %q, want %q", args, got, want)
+	}
+}
+
+// readReply reads a reply in the format described at testConn.
+func readReply(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty line")
+	}
+	switch line[0] {
+	case '$', '=':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", err
+		}
+		if n < 0 {
+			return "nil", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return "", err
+		}
+		return line[:1] + strconv.Quote(string(buf[:n])), nil
+	case '*', '%', '~', '>':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", err
+		}
+		if n < 0 {
+			return "nilarr", nil
+		}
+		if line[0] == '%' {
+			n *= 2
+		}
+		parts := []string{}
+		for i := 0; i < n; i++ {
+			p, err := readReply(rd)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, p)
+		}
+		return line[:1] + "[" + strings.Join(parts, " ") + "]", nil
+	default:
+		return line, nil
+	}
+}
+
+// waitBlocked waits until n clients are blocked.
+func waitBlocked(t *testing.T, m *ShinyRedis, n int) {
+	t.Helper()
+	for i := 0; i < 300; i++ {
+		m.Lock()
+		got := len(m.blockedPeers())
+		m.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("waiting for %d blocked clients", n)
+}
+
+// setNow sets m.Now, under the lock.
+func setNow(m *ShinyRedis, now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	m.Now = now
+}
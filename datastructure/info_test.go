@@ -0,0 +1,130 @@
+package datastructure
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// info runs INFO and gives the fields, and the section headers in order.
+func info(t *testing.T, c *testConn, args ...string) (map[string]string, []string) {
+	t.Helper()
+	fields := map[string]string{}
+	var headers []string
+	for _, line := range strings.Split(unquote(t, c.do(append([]string{"INFO"}, args...)...)), "\r\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# "):
+			headers = append(headers, line[2:])
+		default:
+			k, v, ok := strings.Cut(line, ":")
+			if !ok {
+				t.Fatalf("INFO line %q", line)
+			}
+			fields[k] = v
+		}
+	}
+	return fields, headers
+}
+
+func TestInfoSections(t *testing.T) {
+	_, c := start(t)
+	for args, want := range map[string]string{
+		"":                     "Server Clients Memory Stats Errorstats Keyspace",
+		"default":              "Server Clients Memory Stats Errorstats Keyspace",
+		"all":                  "Server Clients Memory Stats Commandstats Errorstats Keyspace",
+		"everything":           "Server Clients Memory Stats Commandstats Errorstats Keyspace",
+		"keyspace":             "Keyspace",
+		"KEYSPACE server":      "Server Keyspace",
+		"commandstats nosuch":  "Commandstats",
+		"nosuch":               "",
+		"default commandstats": "Server Clients Memory Stats Commandstats Errorstats Keyspace",
+	} {
+		_, headers := info(t, c, strings.Fields(args)...)
+		if got := strings.Join(headers, " "); got != want {
+			t.Errorf("INFO %s: got %q, want %q", args, got, want)
+		}
+	}
+
+	// RESP3 gives a verbatim string
+	c.do("HELLO", "3")
+	if got, want := c.do("INFO", "keyspace"), "="+strconv.Quote("txt:# Keyspace\r\n"); got != want {
+		t.Errorf("INFO in RESP3: got %q", got)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+	c2.must(`*[$"subscribe" $"ch" :1]`, "SUBSCRIBE", "ch")
+	c.must(`+OK`, "MSET", "a", "1", "b", "2")
+	c.must(`+OK`, "SET", "e", "1", "EX", "10")
+	c.must(`+OK`, "SET", "f", "1", "EX", "20")
+	c.must(`+OK`, "SELECT", "3")
+	c.must(`+OK`, "SET", "x", "1")
+	c.must("-"+msgWrongType, "LPUSH", "x", "1")
+	c.must(`-ERR wrong number of arguments for 'get' command`, "GET")
+
+	fields, _ := info(t, c, "all")
+	for k, want := range map[string]string{
+		"redis_version":              redisVersion,
+		"tcp_port":                   m.Port(),
+		"connected_clients":          "2",
+		"blocked_clients":            "0",
+		"pubsub_clients":             "1",
+		"pubsub_channels":            "1",
+		"db0":                        "keys=4,expires=2,avg_ttl=15000",
+		"db3":                        "keys=1,expires=0,avg_ttl=0",
+		"errorstat_ERR":              "count=1",
+		"errorstat_WRONGTYPE":        "count=1",
+		"total_error_replies":        "2",
+		"cmdstat_mset":               "calls=1,",
+		"cmdstat_get":                "calls=0,",
+		"total_connections_received": "2",
+	} {
+		got, ok := fields[k]
+		if !ok {
+			t.Errorf("INFO: no %s", k)
+			continue
+		}
+		if !strings.HasPrefix(got, want) {
+			t.Errorf("INFO %s: got %q, want %q", k, got, want)
+		}
+	}
+	if _, ok := fields["db1"]; ok {
+		t.Error("INFO lists an empty db")
+	}
+	if !strings.Contains(fields["cmdstat_get"], ",rejected_calls=1,failed_calls=0") {
+		t.Errorf("cmdstat_get: got %q", fields["cmdstat_get"])
+	}
+	if !strings.Contains(fields["cmdstat_lpush"], ",rejected_calls=0,failed_calls=1") {
+		t.Errorf("cmdstat_lpush: got %q", fields["cmdstat_lpush"])
+	}
+
+	// expired keys are counted
+	m.FastForward(15 * time.Second)
+	fields, _ = info(t, c, "stats")
+	if got := fields["expired_keys"]; got != "1" {
+		t.Errorf("expired_keys: got %q", got)
+	}
+
+	c3 := dial(t, m)
+	c3.send("BLPOP", "q", "0")
+	waitBlocked(t, m, 1)
+	fields, _ = info(t, c, "clients")
+	if got := fields["blocked_clients"]; got != "1" {
+		t.Errorf("blocked_clients: got %q", got)
+	}
+}
+
+func TestInfoAvgTTL(t *testing.T) {
+	_, c := start(t)
+	// 200 years each, the sum doesn't fit in a time.Duration
+	c.must(`+OK`, "SET", "a", "1", "EX", "6307200000")
+	c.must(`+OK`, "SET", "b", "1", "EX", "6307200000")
+	fields, _ := info(t, c, "keyspace")
+	if got, want := fields["db0"], "keys=2,expires=2,avg_ttl=6307200000000"; got != want {
+		t.Errorf("db0: got %q, want %q", got, want)
+	}
+}
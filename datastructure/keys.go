@@ -0,0 +1,165 @@
+package datastructure
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// patternRE compiles a redis glob pattern to a regexp. Returns nil if the
+// pattern will never match anything. Supports *, ?, [abc], [^abc], [a-z] and
+// \ escapes, the same as redis' stringmatchlen().
+func patternRE(p string) *regexp.Regexp {
+	var re strings.Builder
+	re.WriteString(`(?s)^`)
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			re.WriteString(`.*`)
+		case '?':
+			re.WriteString(`.`)
+		case '\\':
+			if i < len(p)-1 {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		case '[':
+			i++
+			not := i < len(p) && p[i] == '^'
+			if not {
+				i++
+			}
+			var class strings.Builder
+			for ; i < len(p) && p[i] != ']'; i++ {
+				switch {
+				case p[i] == '\\' && i < len(p)-1:
+					i++
+					class.WriteString(classChar(p[i]))
+				case i+2 < len(p) && p[i+1] == '-':
+					start, end := p[i], p[i+2]
+					if start > end {
+						start, end = end, start
+					}
+					class.WriteString(classChar(start) + "-" + classChar(end))
+					i += 2
+				default:
+					class.WriteString(classChar(p[i]))
+				}
+			}
+			switch {
+			case class.Len() > 0 && not:
+				re.WriteString("[^" + class.String() + "]")
+			case class.Len() > 0:
+				re.WriteString("[" + class.String() + "]")
+			case not:
+				// '[^]' matches any single char
+				re.WriteString(`.`)
+			default:
+				// '[]' is valid, but matches nothing
+				return nil
+			}
+		default:
+			re.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	re.WriteString(`$`)
+	r, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil
+	}
+	return r
+}
+
+// classChar escapes a byte for use in a regexp character class.
+func classChar(c byte) string {
+	return `\x{` + strconv.FormatUint(uint64(c), 16) + `}`
+}
+
+// matchKeys filters keys on a glob pattern.
+func matchKeys(keys []string, pattern string) []string {
+	re := patternRE(pattern)
+	if re == nil {
+		return nil
+	}
+	var res []string
+	for _, k := range keys {
+		if re.MatchString(k) {
+			res = append(res, k)
+		}
+	}
+	return res
+}
+
+// scanOpts are the options of the SCAN family.
+type scanOpts struct {
+	cursor    int
+	withMatch bool
+	match     string
+	count     int
+	withType  bool
+	typ       string
+	noValues  bool
+}
+
+// parseScanOpts parses "cursor [MATCH pattern] [COUNT count]". withType
+// allows the TYPE option of SCAN, withNoValues the NOVALUES flag of HSCAN.
+// Returns an error message on invalid input.
+func parseScanOpts(args []string, withType, withNoValues bool) (scanOpts, string) {
+	opts := scanOpts{count: 10}
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return opts, msgInvalidCursor
+	}
+	opts.cursor = cursor
+	args = args[1:]
+
+	for len(args) > 0 {
+		switch opt := strings.ToUpper(args[0]); {
+		case opt == "NOVALUES" && withNoValues:
+			opts.noValues = true
+			args = args[1:]
+			continue
+		case len(args) < 2:
+			return opts, msgSyntaxError
+		case opt == "MATCH":
+			opts.withMatch, opts.match = true, args[1]
+		case opt == "COUNT":
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			if n <= 0 {
+				return opts, msgSyntaxError
+			}
+			opts.count = n
+		case opt == "TYPE" && withType:
+			opts.withType, opts.typ = true, strings.ToLower(args[1])
+		default:
+			return opts, msgSyntaxError
+		}
+		args = args[2:]
+	}
+	return opts, ""
+}
+
+// scanPage gives a page of items for a SCAN-like command. items need a
+// stable order, the cursor is an offset in it. The returned cursor is 0 when
+// everything has been seen. MATCH is applied after the COUNT items are
+// picked, same as redis does.
+func scanPage(items []string, opts scanOpts) (int, []string) {
+	if opts.cursor >= len(items) {
+		return 0, nil
+	}
+	end := opts.cursor + opts.count
+	if end > len(items) {
+		end = len(items)
+	}
+	page := items[opts.cursor:end]
+	if opts.withMatch {
+		page = matchKeys(page, opts.match)
+	}
+	if end == len(items) {
+		end = 0
+	}
+	return end, page
+}
@@ -0,0 +1,31 @@
+package datastructure
+
+import "testing"
+
+func TestPatternRE(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"h?llo", "hello", true},
+		{"h*llo", "heeeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[b-a]llo", "hbllo", true},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hallo", false},
+		{"a.b", "axb", false},
+		{"a+b", "a+b", true},
+		{"[]", "a", false},
+		{"x[^]", "xz", true},
+		{`foo\`, `foo\`, true},
+		{`[\]]`, "]", true},
+		{"*", "a\nb", true},
+	} {
+		re := patternRE(tc.pattern)
+		if got := re != nil && re.MatchString(tc.s); got != tc.match {
+			t.Errorf("%q on %q: got %t", tc.pattern, tc.s, got)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"shiny_redis/server"
 	"strconv"
 	"strings"
-	"time"
 )
 
 type leftright int
@@ -17,23 +16,26 @@ const (
 // commandsList handles list commands (mostly L*)
 func CommandsList(m *ShinyRedis) {
 	//Time complexity: O(1)
-	m.srv.Register("BLPOP", m.cmdBlpop)
-	m.srv.Register("BRPOP", m.cmdBrpop)
-	m.srv.Register("BRPOPLPUSH", m.cmdBrpoplpush)
-	m.srv.Register("LINDEX", m.cmdLindex)
-	m.srv.Register("LINSERT", m.cmdLinsert)
-	m.srv.Register("LLEN", m.cmdLlen)
-	//m.srv.Register("LPOP", m.cmdLpop)
-	//m.srv.Register("LPUSH", m.cmdLpush)
-	//m.srv.Register("LPUSHX", m.cmdLpushx)
-	//m.srv.Register("LRANGE", m.cmdLrange)
-	//m.srv.Register("LREM", m.cmdLrem)
-	//m.srv.Register("LSET", m.cmdLset)
-	//m.srv.Register("LTRIM", m.cmdLtrim)
-	//m.srv.Register("RPOP", m.cmdRpop)
-	//m.srv.Register("RPOPLPUSH", m.cmdRpoplpush)
-	//m.srv.Register("RPUSH", m.cmdRpush)
-	//m.srv.Register("RPUSHX", m.cmdRpushx)
+	m.srv.Register("BLPOP", m.cmdBlpop, -3, "write noscript blocking", 1, -2, 1)
+	m.srv.Register("BRPOP", m.cmdBrpop, -3, "write noscript blocking", 1, -2, 1)
+	m.srv.Register("BRPOPLPUSH", m.cmdBrpoplpush, 4, "write denyoom noscript blocking", 1, 2, 1)
+	m.srv.Register("LINDEX", m.cmdLindex, 3, "readonly", 1, 1, 1)
+	m.srv.Register("LINSERT", m.cmdLinsert, 5, "write denyoom", 1, 1, 1)
+	m.srv.Register("LLEN", m.cmdLlen, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("LPOP", m.cmdLpop, -2, "write fast", 1, 1, 1)
+	m.srv.Register("LPOS", m.cmdLpos, -3, "readonly", 1, 1, 1)
+	m.srv.Register("LPUSH", m.cmdLpush, -3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("LPUSHX", m.cmdLpushx, -3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("LRANGE", m.cmdLrange, 4, "readonly", 1, 1, 1)
+	m.srv.Register("LREM", m.cmdLrem, 4, "write", 1, 1, 1)
+	m.srv.Register("LSET", m.cmdLset, 4, "write denyoom", 1, 1, 1)
+	m.srv.Register("LTRIM", m.cmdLtrim, 4, "write", 1, 1, 1)
+	m.srv.Register("RPOP", m.cmdRpop, -2, "write fast", 1, 1, 1)
+	m.srv.Register("RPOPLPUSH", m.cmdRpoplpush, 3, "write denyoom", 1, 2, 1)
+	m.srv.Register("RPUSH", m.cmdRpush, -3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("RPUSHX", m.cmdRpushx, -3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("LMOVE", m.cmdLmove, 5, "write denyoom", 1, 2, 1)
+	m.srv.Register("BLMOVE", m.cmdBlmove, 6, "write denyoom noscript blocking", 1, 2, 1)
 }
 func (m *ShinyRedis) cmdBlpop(c *server.Peer, cmd string, args []string) {
 	m.cmdBXpop(c, cmd, args, left)
@@ -45,33 +47,32 @@ func (m *ShinyRedis) cmdBrpop(c *server.Peer, cmd string, args []string) {
 
 func (m *ShinyRedis) cmdBXpop(c *server.Peer, cmd string, args []string, lr leftright) {
 	if len(args) < 2 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//todo
-	//handleAuth
-	//checkpub
 
 	timeoutS := args[len(args)-1]
 	keys := args[:len(args)-1]
 
-	timeout, err := strconv.Atoi(timeoutS)
-	if err != nil {
-		//setDirty(c)
-		//c.WriteError(msgInvalidTimeout)
-		return
-	}
-	if timeout < 0 {
-		//setDirty(c)
-		//c.WriteError(msgNegTimeout)
+	timeout, msg := parseBlockTimeout(timeoutS)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
 		return
 	}
 
 	blocking(
 		m,
 		c,
-		time.Duration(timeout)*time.Second,
+		keys,
+		timeout,
 		func(c *server.Peer, ctx *connCtx) bool {
 			db := m.db(ctx.selectedDB)
 			for _, key := range keys {
@@ -79,63 +80,58 @@ func (m *ShinyRedis) cmdBXpop(c *server.Peer, cmd string, args []string, lr left
 					continue
 				}
 				if db.t(key) != "list" {
-					c.WriteError("msgWrongType")
+					c.WriteError(msgWrongType)
 					return true
 				}
 
 				if len(db.listKeys[key]) == 0 {
 					continue
 				}
-				//c.WriteLen(2)
-				//c.WriteBulk(key)
-				var v string
-				switch lr {
-				case left:
-					v = db.listLpop(key)
-				case right:
-					v = db.listPop(key)
-				}
-				c.WriteBulk(v)
+				v := db.listXpop(key, lr)
+				c.Block(func(w *server.Writer) {
+					w.WriteLen(2)
+					w.WriteBulk(key)
+					w.WriteBulk(v)
+				})
 				return true
 			}
 			return false
 		},
 		func(c *server.Peer) {
 			// timeout
-			c.WriteBulk("timeout")
+			c.WriteNullArray()
 		},
 	)
 }
 
 func (m *ShinyRedis) cmdBrpoplpush(c *server.Peer, cmd string, args []string) {
 	if len(args) != 3 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//todo
-	//handleAuth
-	//checkpub
 
 	src := args[0]
 	dst := args[1]
 
-	timeout, err := strconv.Atoi(args[2])
-	if err != nil {
-		//setDirty(c)
-		//c.WriteError(msgInvalidTimeout)
-		return
-	}
-	if timeout < 0 {
-		//setDirty(c)
-		//c.WriteError(msgNegTimeout)
+	timeout, msg := parseBlockTimeout(args[2])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
 		return
 	}
 
 	blocking(
 		m,
 		c,
-		time.Duration(timeout)*time.Second,
+		[]string{src},
+		timeout,
 		func(c *server.Peer, ctx *connCtx) bool {
 			db := m.db(ctx.selectedDB)
 
@@ -143,20 +139,19 @@ func (m *ShinyRedis) cmdBrpoplpush(c *server.Peer, cmd string, args []string) {
 				return false
 			}
 			if db.t(src) != "list" || (db.exists(dst) && db.t(dst) != "list") {
-				c.WriteError("msgWrongType")
+				c.WriteError(msgWrongType)
 				return true
 			}
 			if len(db.listKeys[src]) == 0 {
 				return false
 			}
-			elem := db.listPop(src)
-			db.listLpush(dst, elem)
+			elem := db.listMove(src, dst, right, left)
 			c.WriteBulk(elem)
 			return true
 		},
 		func(c *server.Peer) {
 			// timeout
-			c.WriteBulk("timeout")
+			c.WriteNull()
 		},
 	)
 }
@@ -164,19 +159,23 @@ func (m *ShinyRedis) cmdBrpoplpush(c *server.Peer, cmd string, args []string) {
 // LINDEX
 func (m *ShinyRedis) cmdLindex(c *server.Peer, cmd string, args []string) {
 	if len(args) != 2 {
-		//	setDirty(c)
-		c.WriteError("errWrongNumber")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkpub
 
 	key, offsets := args[0], args[1]
 
 	offset, err := strconv.Atoi(offsets)
 	if err != nil || offsets == "-0" {
-		//setDirty(c)
-		c.WriteError("msgInvalidInt")
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
 		return
 	}
 
@@ -186,11 +185,11 @@ func (m *ShinyRedis) cmdLindex(c *server.Peer, cmd string, args []string) {
 		t, ok := db.keys[key]
 		if !ok {
 			// No such key
-			//c.WriteNull()
+			c.WriteNull()
 			return
 		}
 		if t != "list" {
-			c.WriteError("msgWrongType")
+			c.WriteError(msgWrongType)
 			return
 		}
 
@@ -199,7 +198,7 @@ func (m *ShinyRedis) cmdLindex(c *server.Peer, cmd string, args []string) {
 			offset = len(l) + offset
 		}
 		if offset < 0 || offset > len(l)-1 {
-			//c.WriteNull()
+			c.WriteNull()
 			return
 		}
 		c.WriteBulk(l[offset])
@@ -209,12 +208,16 @@ func (m *ShinyRedis) cmdLindex(c *server.Peer, cmd string, args []string) {
 // LINSERT
 func (m *ShinyRedis) cmdLinsert(c *server.Peer, cmd string, args []string) {
 	if len(args) != 4 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkpub
 
 	key := args[0]
 	where := 0
@@ -224,8 +227,8 @@ func (m *ShinyRedis) cmdLinsert(c *server.Peer, cmd string, args []string) {
 	case "after":
 		where = +1
 	default:
-		//setDirty(c)
-		c.WriteError("msgSyntaxError")
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
 		return
 	}
 	pivot := args[2]
@@ -237,11 +240,11 @@ func (m *ShinyRedis) cmdLinsert(c *server.Peer, cmd string, args []string) {
 		t, ok := db.keys[key]
 		if !ok {
 			// No such key
-			///c.WriteInt(0)
+			c.WriteInt(0)
 			return
 		}
 		if t != "list" {
-			c.WriteError("msgWrongType")
+			c.WriteError(msgWrongType)
 			return
 		}
 
@@ -262,22 +265,26 @@ func (m *ShinyRedis) cmdLinsert(c *server.Peer, cmd string, args []string) {
 			}
 			db.listKeys[key] = l
 			db.keyVersion[key]++
-			//c.WriteInt(len(l))
+			c.WriteInt(len(l))
 			return
 		}
-		//c.WriteInt(-1)
+		c.WriteInt(-1)
 	})
 }
 
 // LLEN
 func (m *ShinyRedis) cmdLlen(c *server.Peer, cmd string, args []string) {
 	if len(args) != 1 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkpub
 	key := args[0]
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
@@ -286,14 +293,647 @@ func (m *ShinyRedis) cmdLlen(c *server.Peer, cmd string, args []string) {
 		t, ok := db.keys[key]
 		if !ok {
 			// No such key. That's zero length.
-			//c.WriteInt(0)
+			c.WriteInt(0)
 			return
 		}
 		if t != "list" {
-			c.WriteError("msgWrongType")
+			c.WriteError(msgWrongType)
 			return
 		}
 
 		c.WriteInt(len(db.listKeys[key]))
 	})
 }
+
+// LPOP
+func (m *ShinyRedis) cmdLpop(c *server.Peer, cmd string, args []string) {
+	m.cmdXpop(c, cmd, args, left)
+}
+
+// RPOP
+func (m *ShinyRedis) cmdRpop(c *server.Peer, cmd string, args []string) {
+	m.cmdXpop(c, cmd, args, right)
+}
+
+func (m *ShinyRedis) cmdXpop(c *server.Peer, cmd string, args []string, lr leftright) {
+	if len(args) < 1 || len(args) > 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			setDirty(c)
+			c.WriteError(msgNotPositive)
+			return
+		}
+		count = n
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			// non-existing key is fine
+			if withCount {
+				c.WriteNullArray()
+				return
+			}
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		if !withCount {
+			c.WriteBulk(db.listXpop(key, lr))
+			return
+		}
+		var res []string
+		for i := 0; i < count && db.exists(key); i++ {
+			res = append(res, db.listXpop(key, lr))
+		}
+		c.WriteStrings(res)
+	})
+}
+
+// LPUSH
+func (m *ShinyRedis) cmdLpush(c *server.Peer, cmd string, args []string) {
+	m.cmdXpush(c, cmd, args, left)
+}
+
+// RPUSH
+func (m *ShinyRedis) cmdRpush(c *server.Peer, cmd string, args []string) {
+	m.cmdXpush(c, cmd, args, right)
+}
+
+func (m *ShinyRedis) cmdXpush(c *server.Peer, cmd string, args []string, lr leftright) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, values := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var newLen int
+		for _, value := range values {
+			newLen = db.listXpush(key, value, lr)
+		}
+		c.WriteInt(newLen)
+	})
+}
+
+// LPUSHX
+func (m *ShinyRedis) cmdLpushx(c *server.Peer, cmd string, args []string) {
+	m.cmdXpushx(c, cmd, args, left)
+}
+
+// RPUSHX
+func (m *ShinyRedis) cmdRpushx(c *server.Peer, cmd string, args []string) {
+	m.cmdXpushx(c, cmd, args, right)
+}
+
+func (m *ShinyRedis) cmdXpushx(c *server.Peer, cmd string, args []string, lr leftright) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, values := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var newLen int
+		for _, value := range values {
+			newLen = db.listXpush(key, value, lr)
+		}
+		c.WriteInt(newLen)
+	})
+}
+
+// LRANGE
+func (m *ShinyRedis) cmdLrange(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	end, err := strconv.Atoi(args[2])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		l := db.listKeys[key]
+		rs, re := redisRange(len(l), start, end, false)
+		c.WriteStrings(l[rs:re])
+	})
+}
+
+// LREM
+func (m *ShinyRedis) cmdLrem(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[2]
+	count, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		l := db.listKeys[key]
+		if count < 0 {
+			reverseSlice(l)
+		}
+		var (
+			deleted int
+			newL    listKey
+			toDo    = len(l)
+		)
+		if count < 0 {
+			toDo = -count
+		}
+		if count > 0 {
+			toDo = count
+		}
+		for i, el := range l {
+			if el == value && toDo > 0 {
+				deleted++
+				toDo--
+				continue
+			}
+			if toDo == 0 {
+				newL = append(newL, l[i:]...)
+				break
+			}
+			newL = append(newL, el)
+		}
+		if count < 0 {
+			reverseSlice(newL)
+		}
+		if len(newL) == 0 {
+			db.del(key, true)
+		} else {
+			db.listKeys[key] = newL
+			db.keyVersion[key]++
+		}
+
+		c.WriteInt(deleted)
+	})
+}
+
+// LSET
+func (m *ShinyRedis) cmdLset(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[2]
+	index, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		if db.t(key) != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		l := db.listKeys[key]
+		if index < 0 {
+			index = len(l) + index
+		}
+		if index < 0 || index > len(l)-1 {
+			c.WriteError(msgOutOfRange)
+			return
+		}
+		l[index] = value
+		db.keyVersion[key]++
+
+		c.WriteOK()
+	})
+}
+
+// LTRIM
+func (m *ShinyRedis) cmdLtrim(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	end, err := strconv.Atoi(args[2])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		t, ok := db.keys[key]
+		if !ok {
+			c.WriteOK()
+			return
+		}
+		if t != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		l := db.listKeys[key]
+		rs, re := redisRange(len(l), start, end, false)
+		l = l[rs:re]
+		if len(l) == 0 {
+			db.del(key, true)
+		} else {
+			db.listKeys[key] = l
+			db.keyVersion[key]++
+		}
+		c.WriteOK()
+	})
+}
+
+// RPOPLPUSH
+func (m *ShinyRedis) cmdRpoplpush(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	src, dst := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(src) {
+			c.WriteNull()
+			return
+		}
+		if db.t(src) != "list" || (db.exists(dst) && db.t(dst) != "list") {
+			c.WriteError(msgWrongType)
+			return
+		}
+		elem := db.listMove(src, dst, right, left)
+		c.WriteBulk(elem)
+	})
+}
+
+// LMOVE
+func (m *ShinyRedis) cmdLmove(c *server.Peer, cmd string, args []string) {
+	if len(args) != 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	src, dst := args[0], args[1]
+	from, ok := parseLeftRight(args[2])
+	if !ok {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	to, ok := parseLeftRight(args[3])
+	if !ok {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(src) {
+			c.WriteNull()
+			return
+		}
+		if db.t(src) != "list" || (db.exists(dst) && db.t(dst) != "list") {
+			c.WriteError(msgWrongType)
+			return
+		}
+		elem := db.listMove(src, dst, from, to)
+		c.WriteBulk(elem)
+	})
+}
+
+// BLMOVE
+func (m *ShinyRedis) cmdBlmove(c *server.Peer, cmd string, args []string) {
+	if len(args) != 5 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	src, dst := args[0], args[1]
+	from, ok := parseLeftRight(args[2])
+	if !ok {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	to, ok := parseLeftRight(args[3])
+	if !ok {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	timeout, msg := parseBlockTimeout(args[4])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	blocking(
+		m,
+		c,
+		[]string{src},
+		timeout,
+		func(c *server.Peer, ctx *connCtx) bool {
+			db := m.db(ctx.selectedDB)
+
+			if !db.exists(src) {
+				return false
+			}
+			if db.t(src) != "list" || (db.exists(dst) && db.t(dst) != "list") {
+				c.WriteError(msgWrongType)
+				return true
+			}
+			elem := db.listMove(src, dst, from, to)
+			c.WriteBulk(elem)
+			return true
+		},
+		func(c *server.Peer) {
+			// timeout
+			c.WriteNull()
+		},
+	)
+}
+
+// LPOS
+func (m *ShinyRedis) cmdLpos(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, element := args[0], args[1]
+	var (
+		rank      = 1
+		count     = 1
+		withCount bool
+		maxlen    int
+	)
+	for opts := args[2:]; len(opts) > 0; opts = opts[2:] {
+		if len(opts) < 2 {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		n, err := strconv.Atoi(opts[1])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		switch strings.ToUpper(opts[0]) {
+		case "RANK":
+			if n == 0 {
+				setDirty(c)
+				c.WriteError(msgRankIsZero)
+				return
+			}
+			rank = n
+		case "COUNT":
+			if n < 0 {
+				setDirty(c)
+				c.WriteError(msgCountIsNegative)
+				return
+			}
+			count, withCount = n, true
+		case "MAXLEN":
+			if n < 0 {
+				setDirty(c)
+				c.WriteError(msgMaxLengthIsNegative)
+				return
+			}
+			maxlen = n
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "list" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var (
+			l       = db.listKeys[key]
+			matches []int
+			skip    = rank - 1
+			step    = 1
+			i       = 0
+		)
+		if rank < 0 {
+			skip, step, i = -rank-1, -1, len(l)-1
+		}
+		for seen := 0; i >= 0 && i < len(l); i, seen = i+step, seen+1 {
+			if maxlen > 0 && seen >= maxlen {
+				break
+			}
+			if l[i] != element {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			matches = append(matches, i)
+			if count > 0 && len(matches) >= count {
+				break
+			}
+		}
+
+		if !withCount {
+			if len(matches) == 0 {
+				c.WriteNull()
+				return
+			}
+			c.WriteInt(matches[0])
+			return
+		}
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(matches))
+			for _, i := range matches {
+				w.WriteInt(i)
+			}
+		})
+	})
+}
+
+// parseLeftRight parses the LEFT/RIGHT argument of LMOVE and BLMOVE.
+func parseLeftRight(s string) (leftright, bool) {
+	switch strings.ToUpper(s) {
+	case "LEFT":
+		return left, true
+	case "RIGHT":
+		return right, true
+	default:
+		return left, false
+	}
+}
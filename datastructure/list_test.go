@@ -0,0 +1,135 @@
+package datastructure
+
+import "testing"
+
+func TestListRangeOverflow(t *testing.T) {
+	_, c := start(t)
+	const max = "9223372036854775807"
+	const min = "-9223372036854775808"
+
+	c.must(":3", "RPUSH", "l", "a", "b", "c")
+	c.must(`*[$"a" $"b" $"c"]`, "LRANGE", "l", "0", max)
+	c.must(`*[$"a" $"b" $"c"]`, "LRANGE", "l", min, max)
+	c.must(`*[]`, "LRANGE", "l", max, max)
+	c.must(`*[]`, "LRANGE", "l", "0", min)
+	c.must("+OK", "LTRIM", "l", "0", max)
+	c.must(`*[$"a" $"b" $"c"]`, "LRANGE", "l", "0", "-1")
+	c.must("+OK", "LTRIM", "l", "1", max)
+	c.must(`*[$"b" $"c"]`, "LRANGE", "l", "0", "-1")
+
+	c.must("+OK", "SET", "s", "hello")
+	c.must(`$"hello"`, "GETRANGE", "s", "0", max)
+	c.must(`$"llo"`, "GETRANGE", "s", "2", max)
+	c.must(`$""`, "GETRANGE", "s", max, max)
+
+	c.must(":2", "ZADD", "z", "1", "a", "2", "b")
+	c.must(`*[$"a" $"b"]`, "ZRANGE", "z", "0", max)
+	c.must(`*[$"b" $"a"]`, "ZRANGE", "z", "0", max, "REV")
+}
+
+func TestListMoveSameKey(t *testing.T) {
+	m, c := start(t)
+	c.must(":1", "RPUSH", "l", "a")
+	c.must(":1", "EXPIRE", "l", "100")
+	c.must(`$"a"`, "RPOPLPUSH", "l", "l")
+	c.must(`$"a"`, "LMOVE", "l", "l", "LEFT", "RIGHT")
+	c.must(`*[$"a"]`, "LRANGE", "l", "0", "-1")
+	c.must(":100", "TTL", "l")
+
+	c.must(":3", "RPUSH", "l", "b", "c")
+	c.must(`$"c"`, "RPOPLPUSH", "l", "l")
+	c.must(`*[$"c" $"a" $"b"]`, "LRANGE", "l", "0", "-1")
+	c.must(`$"c"`, "LMOVE", "l", "l", "LEFT", "RIGHT")
+	c.must(`*[$"a" $"b" $"c"]`, "LRANGE", "l", "0", "-1")
+	c.must(`$"a"`, "LMOVE", "l", "l", "LEFT", "LEFT")
+	c.must(`*[$"a" $"b" $"c"]`, "LRANGE", "l", "0", "-1")
+	c.must(`$"c"`, "BLMOVE", "l", "l", "RIGHT", "LEFT", "0")
+	c.must(`$"b"`, "BRPOPLPUSH", "l", "l", "0")
+	c.must(`*[$"b" $"c" $"a"]`, "LRANGE", "l", "0", "-1")
+	c.must(":100", "TTL", "l")
+
+	// a rotate is still a change, for WATCH
+	c2 := dial(t, m)
+	c.must("+OK", "WATCH", "l")
+	c2.must(`$"a"`, "RPOPLPUSH", "l", "l")
+	c.must("+OK", "MULTI")
+	c.must("+QUEUED", "LLEN", "l")
+	c.must("nilarr", "EXEC")
+}
+
+func TestList(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "RPUSH", "l", "a", "b", "c")
+	c.must(":5", "LPUSH", "l", "y", "x")
+	c.must(`*[$"x" $"y" $"a" $"b" $"c"]`, "LRANGE", "l", "0", "-1")
+	c.must(`*[$"y" $"a"]`, "LRANGE", "l", "1", "-3")
+	c.must(`$"x"`, "LPOP", "l")
+	c.must(`*[$"c" $"b"]`, "RPOP", "l", "2")
+	c.must(`*[]`, "RPOP", "l", "0")
+	c.must(`nilarr`, "RPOP", "nosuch", "2")
+	c.must(`nil`, "RPOP", "nosuch")
+	c.must("-ERR value is out of range, must be positive", "RPOP", "l", "-1")
+	c.must(":0", "LPUSHX", "nosuch", "a")
+	c.must(":0", "EXISTS", "nosuch")
+	c.must(":4", "RPUSHX", "l", "a", "a")
+	c.must(`*[$"y" $"a" $"a" $"a"]`, "LRANGE", "l", "0", "-1")
+	c.must(":2", "LREM", "l", "-2", "a")
+	c.must(`*[$"y" $"a"]`, "LRANGE", "l", "0", "-1")
+	c.must(":0", "LREM", "l", "0", "nosuch")
+	c.must("+OK", "LSET", "l", "-1", "z")
+	c.must("-ERR index out of range", "LSET", "l", "5", "z")
+	c.must("-ERR no such key", "LSET", "nosuch", "5", "z")
+	c.must(`$"z"`, "LINDEX", "l", "1")
+	c.must(`nil`, "LINDEX", "l", "2")
+	c.must(":3", "LINSERT", "l", "BEFORE", "z", "q")
+	c.must(":-1", "LINSERT", "l", "AFTER", "nosuch", "q")
+	c.must(`*[$"y" $"q" $"z"]`, "LRANGE", "l", "0", "-1")
+	c.must("+OK", "LTRIM", "l", "2", "2")
+	c.must(`*[$"z"]`, "LRANGE", "l", "0", "-1")
+	c.must(`$"z"`, "RPOPLPUSH", "l", "l2")
+	c.must(":0", "LLEN", "l")
+	c.must(":0", "EXISTS", "l")
+	c.must(":1", "LLEN", "l2")
+
+	c.must("+OK", "SET", "str", "x")
+	c.must("-"+msgWrongType, "LPUSH", "str", "a")
+	c.must("-"+msgWrongType, "LRANGE", "str", "0", "-1")
+	c.must("-"+msgWrongType, "RPOPLPUSH", "l2", "str")
+	c.must(":1", "LLEN", "l2")
+	c.must("-ERR wrong number of arguments for 'lpush' command", "LPUSH", "l")
+}
+
+func TestLpos(t *testing.T) {
+	_, c := start(t)
+	c.must(":8", "RPUSH", "p", "a", "b", "c", "1", "2", "3", "c", "c")
+	c.must(":2", "LPOS", "p", "c")
+	c.must(":6", "LPOS", "p", "c", "RANK", "2")
+	c.must(":7", "LPOS", "p", "c", "RANK", "-1")
+	c.must(`*[:2 :6 :7]`, "LPOS", "p", "c", "COUNT", "0")
+	c.must(`*[:7 :6]`, "LPOS", "p", "c", "COUNT", "2", "RANK", "-1")
+	c.must(`*[:2]`, "LPOS", "p", "c", "COUNT", "0", "MAXLEN", "3")
+	c.must(`nil`, "LPOS", "p", "x")
+	c.must(`*[]`, "LPOS", "p", "x", "COUNT", "0")
+	c.must("-"+msgRankIsZero, "LPOS", "p", "x", "RANK", "0")
+	c.must("-ERR syntax error", "LPOS", "p", "x", "FOO")
+}
+
+func TestLmove(t *testing.T) {
+	m, c := start(t)
+	c.must(":3", "RPUSH", "p", "a", "b", "c")
+	c.must(`$"c"`, "LMOVE", "p", "q", "RIGHT", "LEFT")
+	c.must(`$"a"`, "LMOVE", "p", "q", "LEFT", "RIGHT")
+	c.must(`*[$"c" $"a"]`, "LRANGE", "q", "0", "-1")
+	c.must(`nil`, "LMOVE", "nosuch", "q", "LEFT", "RIGHT")
+	c.must(`-ERR syntax error`, "LMOVE", "p", "q", "UP", "RIGHT")
+	c.must(`nil`, "BLMOVE", "nosuch", "p", "LEFT", "RIGHT", "0.01")
+
+	c2 := dial(t, m)
+	c2.send("BLMOVE", "w", "p", "LEFT", "RIGHT", "0")
+	waitBlocked(t, m, 1)
+	c.must(":1", "LPUSH", "w", "hi")
+	if got := c2.read(); got != `$"hi"` {
+		t.Errorf("BLMOVE: got %q", got)
+	}
+	c.must(`*[$"b" $"hi"]`, "LRANGE", "p", "0", "-1")
+}
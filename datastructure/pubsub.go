@@ -0,0 +1,536 @@
+package datastructure
+
+import (
+	"fmt"
+	"shiny_redis/server"
+	"sort"
+	"strings"
+)
+
+// commandsPubsub handles all PUB/SUB operations.
+func commandsPubsub(m *ShinyRedis) {
+	m.srv.Register("PSUBSCRIBE", m.cmdPsubscribe, -2, "pubsub noscript loading stale", 0, 0, 0)
+	m.srv.Register("PUBLISH", m.cmdPublish, 3, "pubsub loading stale fast", 0, 0, 0)
+	m.srv.Register("PUBSUB", m.cmdPubSub, -2, "pubsub random loading stale", 0, 0, 0)
+	m.srv.Register("PUNSUBSCRIBE", m.cmdPunsubscribe, -1, "pubsub noscript loading stale", 0, 0, 0)
+	m.srv.Register("SPUBLISH", m.cmdSpublish, 3, "pubsub loading stale fast", 1, 1, 1)
+	m.srv.Register("SSUBSCRIBE", m.cmdSsubscribe, -2, "pubsub noscript loading stale", 1, -1, 1)
+	m.srv.Register("SUBSCRIBE", m.cmdSubscribe, -2, "pubsub noscript loading stale", 0, 0, 0)
+	m.srv.Register("SUNSUBSCRIBE", m.cmdSunsubscribe, -1, "pubsub noscript loading stale", 1, -1, 1)
+	m.srv.Register("UNSUBSCRIBE", m.cmdUnsubscribe, -1, "pubsub noscript loading stale", 0, 0, 0)
+}
+
+// SUBSCRIBE
+func (m *ShinyRedis) cmdSubscribe(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := m.subscribedState(c)
+		for _, channel := range args {
+			n := sub.Subscribe(channel)
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(3)
+				w.WriteBulk("subscribe")
+				w.WriteBulk(channel)
+				w.WriteInt(n)
+			})
+		}
+	})
+}
+
+// UNSUBSCRIBE
+func (m *ShinyRedis) cmdUnsubscribe(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	channels := args
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := ctx.subscriber
+		if sub == nil {
+			// not subscribed to anything, but redis replies anyway
+			if len(channels) == 0 {
+				writeUnsubscribe(c, "unsubscribe", "", false, 0)
+			}
+			for _, channel := range channels {
+				writeUnsubscribe(c, "unsubscribe", channel, true, 0)
+			}
+			return
+		}
+
+		if len(channels) == 0 {
+			channels = sub.Channels()
+		}
+		if len(channels) == 0 {
+			writeUnsubscribe(c, "unsubscribe", "", false, len(sub.Patterns()))
+		}
+		for _, channel := range channels {
+			n := sub.Unsubscribe(channel)
+			writeUnsubscribe(c, "unsubscribe", channel, true, n)
+		}
+		if sub.Count() == 0 {
+			m.endSubscriber(ctx)
+		}
+	})
+}
+
+// PSUBSCRIBE
+func (m *ShinyRedis) cmdPsubscribe(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := m.subscribedState(c)
+		for _, pat := range args {
+			n := sub.Psubscribe(pat)
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(3)
+				w.WriteBulk("psubscribe")
+				w.WriteBulk(pat)
+				w.WriteInt(n)
+			})
+		}
+	})
+}
+
+// PUNSUBSCRIBE
+func (m *ShinyRedis) cmdPunsubscribe(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	patterns := args
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := ctx.subscriber
+		if sub == nil {
+			// not subscribed to anything, but redis replies anyway
+			if len(patterns) == 0 {
+				writeUnsubscribe(c, "punsubscribe", "", false, 0)
+			}
+			for _, pat := range patterns {
+				writeUnsubscribe(c, "punsubscribe", pat, true, 0)
+			}
+			return
+		}
+
+		if len(patterns) == 0 {
+			patterns = sub.Patterns()
+		}
+		if len(patterns) == 0 {
+			writeUnsubscribe(c, "punsubscribe", "", false, len(sub.Channels()))
+		}
+		for _, pat := range patterns {
+			n := sub.Punsubscribe(pat)
+			writeUnsubscribe(c, "punsubscribe", pat, true, n)
+		}
+		if sub.Count() == 0 {
+			m.endSubscriber(ctx)
+		}
+	})
+}
+
+// SSUBSCRIBE
+func (m *ShinyRedis) cmdSsubscribe(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := m.subscribedState(c)
+		for _, channel := range args {
+			n := sub.Ssubscribe(channel)
+			c.Block(func(w *server.Writer) {
+				w.WritePushLen(3)
+				w.WriteBulk("ssubscribe")
+				w.WriteBulk(channel)
+				w.WriteInt(n)
+			})
+		}
+	})
+}
+
+// SUNSUBSCRIBE
+func (m *ShinyRedis) cmdSunsubscribe(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if getCtx(c).nested {
+		c.WriteError(msgNotFromScripts)
+		return
+	}
+
+	channels := args
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		sub := ctx.subscriber
+		if sub == nil {
+			if len(channels) == 0 {
+				writeUnsubscribe(c, "sunsubscribe", "", false, 0)
+			}
+			for _, channel := range channels {
+				writeUnsubscribe(c, "sunsubscribe", channel, true, 0)
+			}
+			return
+		}
+
+		if len(channels) == 0 {
+			channels = sub.ShardChannels()
+		}
+		if len(channels) == 0 {
+			writeUnsubscribe(c, "sunsubscribe", "", false, 0)
+		}
+		for _, channel := range channels {
+			n := sub.Sunsubscribe(channel)
+			writeUnsubscribe(c, "sunsubscribe", channel, true, n)
+		}
+		if sub.Count() == 0 {
+			m.endSubscriber(ctx)
+		}
+	})
+}
+
+// writeUnsubscribe writes a single (p)unsubscribe confirmation. Without a
+// name the name is nil.
+func writeUnsubscribe(c *server.Peer, kind, name string, withName bool, count int) {
+	c.Block(func(w *server.Writer) {
+		w.WritePushLen(3)
+		w.WriteBulk(kind)
+		if withName {
+			w.WriteBulk(name)
+		} else {
+			w.WriteNull()
+		}
+		w.WriteInt(count)
+	})
+}
+
+// PUBLISH
+func (m *ShinyRedis) cmdPublish(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	channel, mesg := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.WriteInt(m.publish(channel, mesg))
+	})
+}
+
+// SPUBLISH
+func (m *ShinyRedis) cmdSpublish(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	channel, mesg := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		c.WriteInt(m.spublish(channel, mesg))
+	})
+}
+
+// PUBSUB
+func (m *ShinyRedis) cmdPubSub(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	subcommand := strings.ToUpper(args[0])
+	subargs := args[1:]
+	var argsOk bool
+	switch subcommand {
+	case "CHANNELS":
+		argsOk = len(subargs) < 2
+	case "NUMSUB":
+		argsOk = true
+	case "NUMPAT":
+		argsOk = len(subargs) == 0
+	case "SHARDCHANNELS":
+		argsOk = len(subargs) < 2
+	case "SHARDNUMSUB":
+		argsOk = true
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try PUBSUB HELP.", args[0]))
+		return
+	}
+	if !argsOk {
+		setDirty(c)
+		c.WriteError(errWrongNumber("pubsub|" + subcommand))
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		switch subcommand {
+		case "CHANNELS":
+			pat := "*"
+			if len(subargs) == 1 {
+				pat = subargs[0]
+			}
+			c.WriteStrings(matchKeys(m.activeChannels(), pat))
+		case "NUMSUB":
+			c.Block(func(w *server.Writer) {
+				w.WriteLen(len(subargs) * 2)
+				for _, channel := range subargs {
+					w.WriteBulk(channel)
+					w.WriteInt(m.countSubs(channel))
+				}
+			})
+		case "NUMPAT":
+			c.WriteInt(m.countPsubs())
+		case "SHARDCHANNELS":
+			pat := "*"
+			if len(subargs) == 1 {
+				pat = subargs[0]
+			}
+			c.WriteStrings(matchKeys(m.activeShardChannels(), pat))
+		case "SHARDNUMSUB":
+			c.Block(func(w *server.Writer) {
+				w.WriteLen(len(subargs) * 2)
+				for _, channel := range subargs {
+					w.WriteBulk(channel)
+					w.WriteInt(m.countShardSubs(channel))
+				}
+			})
+		}
+	})
+}
+
+// checkPubsub returns true if the client is in subscribed mode and the
+// command is not allowed there. The error is written. RESP3 clients can run
+// any command while subscribed.
+func (m *ShinyRedis) checkPubsub(c *server.Peer, cmd string) bool {
+	if getCtx(c).subscriber == nil || c.Resp3() {
+		return false
+	}
+
+	switch cmd {
+	case "PING", "PSUBSCRIBE", "PUNSUBSCRIBE", "QUIT", "RESET", "SSUBSCRIBE", "SUBSCRIBE", "SUNSUBSCRIBE", "UNSUBSCRIBE":
+		return false
+	default:
+		c.WriteError(fmt.Sprintf("ERR Can't execute '%s': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context", strings.ToLower(cmd)))
+		return true
+	}
+}
+
+// subscribedState gives the subscriber of a peer, and puts the peer in
+// subscribed mode if it wasn't already. Messages are delivered by their own
+// goroutine, so a slow client doesn't hold up PUBLISH. Needs the lock.
+func (m *ShinyRedis) subscribedState(c *server.Peer) *Subscriber {
+	ctx := getCtx(c)
+	if sub := ctx.subscriber; sub != nil {
+		return sub
+	}
+
+	sub := newSubscriber()
+	sub.onFull = c.Kill
+	m.Subscribers[sub] = struct{}{}
+	ctx.subscriber = sub
+
+	go monitorPublish(c, sub.messages)
+
+	c.DisconnCB = append(c.DisconnCB, func() {
+		m.Lock()
+		defer m.Unlock()
+		m.endSubscriber(ctx)
+	})
+	return sub
+}
+
+// endSubscriber takes a peer out of subscribed mode. Needs the lock.
+func (m *ShinyRedis) endSubscriber(ctx *connCtx) {
+	sub := ctx.subscriber
+	if sub == nil {
+		return
+	}
+	delete(m.Subscribers, sub)
+	sub.Close()
+	ctx.subscriber = nil
+}
+
+// publish sends a message to all subscribers. Returns the number of
+// receivers. Needs the lock.
+func (m *ShinyRedis) publish(channel, mesg string) int {
+	found := 0
+	for sub := range m.Subscribers {
+		found += sub.Publish(channel, mesg)
+	}
+	return found
+}
+
+// spublish sends a message to all shard subscribers. Returns the number of
+// receivers. Needs the lock.
+func (m *ShinyRedis) spublish(channel, mesg string) int {
+	found := 0
+	for sub := range m.Subscribers {
+		found += sub.Spublish(channel, mesg)
+	}
+	return found
+}
+
+// activeChannels gives all channels with at least one subscriber, sorted.
+// Needs the lock.
+func (m *ShinyRedis) activeChannels() []string {
+	channels := map[string]struct{}{}
+	for sub := range m.Subscribers {
+		for _, channel := range sub.Channels() {
+			channels[channel] = struct{}{}
+		}
+	}
+
+	var res []string
+	for channel := range channels {
+		res = append(res, channel)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// countSubs gives the number of subscribers of a channel. Needs the lock.
+func (m *ShinyRedis) countSubs(channel string) int {
+	n := 0
+	for sub := range m.Subscribers {
+		for _, c := range sub.Channels() {
+			if c == channel {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// activeShardChannels gives all shard channels with at least one subscriber,
+// sorted. Needs the lock.
+func (m *ShinyRedis) activeShardChannels() []string {
+	channels := map[string]struct{}{}
+	for sub := range m.Subscribers {
+		for _, channel := range sub.ShardChannels() {
+			channels[channel] = struct{}{}
+		}
+	}
+
+	var res []string
+	for channel := range channels {
+		res = append(res, channel)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// countShardSubs gives the number of subscribers of a shard channel. Needs
+// the lock.
+func (m *ShinyRedis) countShardSubs(channel string) int {
+	n := 0
+	for sub := range m.Subscribers {
+		for _, c := range sub.ShardChannels() {
+			if c == channel {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// countPsubs gives the number of unique patterns. Needs the lock.
+func (m *ShinyRedis) countPsubs() int {
+	patterns := map[string]struct{}{}
+	for sub := range m.Subscribers {
+		for _, pat := range sub.Patterns() {
+			patterns[pat] = struct{}{}
+		}
+	}
+	return len(patterns)
+}
+
+// monitorPublish writes the messages to the peer, until the subscriber is
+// closed. They are pushes, so they never end up inside a reply, such as the
+// array of an EXEC.
+func monitorPublish(c *server.Peer, msgs <-chan interface{}) {
+	for msg := range msgs {
+		c.Push(func(w *server.Writer) {
+			switch msg := msg.(type) {
+			case PubsubMessage:
+				w.WritePushLen(3)
+				w.WriteBulk("message")
+				w.WriteBulk(msg.Channel)
+				w.WriteBulk(msg.Message)
+			case PubsubPmessage:
+				w.WritePushLen(4)
+				w.WriteBulk("pmessage")
+				w.WriteBulk(msg.Pattern)
+				w.WriteBulk(msg.Channel)
+				w.WriteBulk(msg.Message)
+			case PubsubSmessage:
+				w.WritePushLen(3)
+				w.WriteBulk("smessage")
+				w.WriteBulk(msg.Channel)
+				w.WriteBulk(msg.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package datastructure
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscriberFull(t *testing.T) {
+	s := newSubscriber()
+	full := 0
+	s.onFull = func() { full++ }
+	s.Subscribe("ch")
+	s.Psubscribe("c*")
+
+	// nobody reads the messages, PUBLISH still doesn't wait
+	for i := 0; i < subscriberBuffer; i++ {
+		if n := s.Publish("ch", "m"); n != 2 {
+			t.Fatalf("Publish: got %d", n)
+		}
+	}
+	if full != subscriberBuffer {
+		t.Errorf("onFull: got %d calls, want %d", full, subscriberBuffer)
+	}
+	if len(s.messages) != subscriberBuffer {
+		t.Errorf("queued: got %d", len(s.messages))
+	}
+}
+
+func TestShardSubscriberFull(t *testing.T) {
+	s := newSubscriber()
+	full := 0
+	s.onFull = func() { full++ }
+	s.Ssubscribe("ch")
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		if n := s.Spublish("ch", "m"); n != 1 {
+			t.Fatalf("Spublish: got %d", n)
+		}
+	}
+	if full != 10 {
+		t.Errorf("onFull: got %d calls, want 10", full)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	m, c := start(t)
+	c.must(`*[$"subscribe" $"ch" :1]`, "SUBSCRIBE", "ch")
+
+	// c doesn't read, so the socket fills up, and then the buffer
+	big := strings.Repeat("x", 64*1024)
+	done := make(chan struct{})
+	go func() {
+		m.Lock()
+		defer m.Unlock()
+		for i := 0; i < 4*subscriberBuffer; i++ {
+			m.publish("ch", big)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("PUBLISH waits for a slow subscriber")
+	}
+
+	// and the subscriber is disconnected
+	c.c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.Copy(io.Discard, c.c); err != nil {
+		t.Fatalf("subscriber wasn't disconnected: %v", err)
+	}
+	o := dial(t, m)
+	for i := 0; o.do("PUBLISH", "ch", "hi") != ":0"; i++ {
+		if i == 100 {
+			t.Fatal("subscriber is still subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPubsub(t *testing.T) {
+	m, c := start(t)
+	p := dial(t, m)
+
+	c.must(`*[$"subscribe" $"ch" :1]`, "SUBSCRIBE", "ch")
+	c.send("PSUBSCRIBE", "c*", "x*")
+	for _, want := range []string{`*[$"psubscribe" $"c*" :2]`, `*[$"psubscribe" $"x*" :3]`} {
+		if got := c.read(); got != want {
+			t.Errorf("PSUBSCRIBE: got %q, want %q", got, want)
+		}
+	}
+
+	p.must(`:2`, "PUBLISH", "ch", "hello")
+	p.must(`:0`, "PUBLISH", "other", "hello")
+	for _, want := range []string{
+		`*[$"message" $"ch" $"hello"]`,
+		`*[$"pmessage" $"c*" $"ch" $"hello"]`,
+	} {
+		if got := c.read(); got != want {
+			t.Errorf("message: got %q, want %q", got, want)
+		}
+	}
+
+	p.must(`*[$"ch"]`, "PUBSUB", "CHANNELS")
+	p.must(`*[]`, "PUBSUB", "CHANNELS", "x*")
+	p.must(`*[$"ch" :1 $"nosuch" :0]`, "PUBSUB", "NUMSUB", "ch", "nosuch")
+	p.must(`:2`, "PUBSUB", "NUMPAT")
+	p.must(`-ERR unknown subcommand 'foo'. Try PUBSUB HELP.`, "PUBSUB", "foo")
+	p.must(`-ERR wrong number of arguments for 'pubsub|numpat' command`, "PUBSUB", "NUMPAT", "x")
+
+	// RESP2 only allows a few commands while subscribed
+	c.must(`-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context`, "GET", "a")
+	c.must(`*[$"pong" $""]`, "PING")
+
+	c.must(`*[$"unsubscribe" $"ch" :2]`, "UNSUBSCRIBE")
+	c.send("PUNSUBSCRIBE")
+	for _, want := range []string{`*[$"punsubscribe" $"c*" :1]`, `*[$"punsubscribe" $"x*" :0]`} {
+		if got := c.read(); got != want {
+			t.Errorf("PUNSUBSCRIBE: got %q, want %q", got, want)
+		}
+	}
+
+	// not subscribed anymore
+	c.must(`nil`, "GET", "a")
+	c.must(`+PONG`, "PING")
+	c.must(`*[$"unsubscribe" nil :0]`, "UNSUBSCRIBE")
+	p.must(`:0`, "PUBLISH", "ch", "hello")
+	p.must(`:0`, "PUBSUB", "NUMPAT")
+}
+
+func TestPubsubResp3(t *testing.T) {
+	m, c := start(t)
+	p := dial(t, m)
+	c.do("HELLO", "3")
+
+	c.must(`>[$"subscribe" $"ch" :1]`, "SUBSCRIBE", "ch")
+	// any command can run
+	c.must(`_`, "GET", "a")
+	p.must(`:1`, "PUBLISH", "ch", "hello")
+	if got := c.read(); got != `>[$"message" $"ch" $"hello"]` {
+		t.Errorf("message: got %q", got)
+	}
+
+	// messages don't end up inside a multi-part reply
+	c.must(`+OK`, "MULTI")
+	for i := 0; i < 20; i++ {
+		c.must(`+QUEUED`, "SET", "k", "v")
+	}
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 20; i++ {
+			p.do("PUBLISH", "ch", "x")
+		}
+		close(done)
+	}()
+	c.send("EXEC")
+	<-done
+	exec, msgs := false, 0
+	for !exec || msgs < 20 {
+		switch got := c.read(); got {
+		case `>[$"message" $"ch" $"x"]`:
+			msgs++
+		case "*[" + strings.TrimSpace(strings.Repeat("+OK ", 20)) + "]":
+			exec = true
+		default:
+			t.Fatalf("unexpected reply %q", got)
+		}
+	}
+}
+
+func TestShardPubsub(t *testing.T) {
+	m, c := start(t)
+	p := dial(t, m)
+
+	c.must(`*[$"ssubscribe" $"ch" :1]`, "SSUBSCRIBE", "ch")
+	c.send("PSUBSCRIBE", "c*")
+	if got := c.read(); got != `*[$"psubscribe" $"c*" :1]` {
+		t.Errorf("PSUBSCRIBE: got %q", got)
+	}
+
+	// shard channels and classic channels are apart, patterns don't match
+	p.must(`:1`, "PUBLISH", "ch", "classic")
+	p.must(`:1`, "SPUBLISH", "ch", "shard")
+	for _, want := range []string{
+		`*[$"pmessage" $"c*" $"ch" $"classic"]`,
+		`*[$"smessage" $"ch" $"shard"]`,
+	} {
+		if got := c.read(); got != want {
+			t.Errorf("message: got %q, want %q", got, want)
+		}
+	}
+	p.must(`*[]`, "PUBSUB", "CHANNELS")
+	p.must(`*[$"ch"]`, "PUBSUB", "SHARDCHANNELS")
+	p.must(`*[$"ch" :1 $"x" :0]`, "PUBSUB", "SHARDNUMSUB", "ch", "x")
+
+	c.must(`*[$"sunsubscribe" $"ch" :0]`, "SUNSUBSCRIBE")
+	p.must(`:0`, "SPUBLISH", "ch", "shard")
+	p.must(`*[]`, "PUBSUB", "SHARDCHANNELS")
+	p.must(`:1`, "PUBLISH", "ch", "classic")
+	if got := c.read(); got != `*[$"pmessage" $"c*" $"ch" $"classic"]` {
+		t.Errorf("message: got %q", got)
+	}
+}
@@ -2,23 +2,422 @@ package datastructure
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
 	"math/rand"
 	"shiny_redis/server"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// Error messages. These match the redis texts, client libraries compare
+// against them.
+const (
+	msgWrongType           = "WRONGTYPE Operation against a key holding the wrong kind of value"
+	msgInvalidInt          = "ERR value is not an integer or out of range"
+	msgInvalidFloat        = "ERR value is not a valid float"
+	msgInvalidMinMax       = "ERR min or max is not a float"
+	msgInvalidRangeItem    = "ERR min or max not valid string range item"
+	msgInvalidTimeout      = "ERR timeout is not a float or out of range"
+	msgNegTimeout          = "ERR timeout is negative"
+	msgTimeoutOutOfRange   = "ERR timeout is out of range"
+	msgSyntaxError         = "ERR syntax error"
+	msgKeyNotFound         = "ERR no such key"
+	msgOutOfRange          = "ERR index out of range"
+	msgNotPositive         = "ERR value is out of range, must be positive"
+	msgValueOutOfRange     = "ERR value is out of range"
+	msgStringTooLong       = "ERR string exceeds maximum allowed size (proto-max-bulk-len)"
+	msgInvalidCursor       = "ERR invalid cursor"
+	msgXXandNX             = "ERR XX and NX options at the same time are not compatible"
+	msgIncrOverflow        = "ERR increment or decrement would overflow"
+	msgNegativeKeysNumber  = "ERR Number of keys can't be negative"
+	msgInvalidKeysNumber   = "ERR Number of keys can't be greater than number of args"
+	msgDBIndexOutOfRange   = "ERR DB index is out of range"
+	msgNotFromScripts      = "ERR This Redis command is not allowed from script"
+	msgRankIsZero          = "ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list"
+	msgCountIsNegative     = "ERR COUNT can't be negative"
+	msgMaxLengthIsNegative = "ERR MAXLEN can't be negative"
+	msgLimitIsNegative     = "ERR LIMIT can't be negative"
+	msgGTLTandNX           = "ERR GT, LT, and/or NX options at the same time are not compatible"
+	msgSingleElementPair   = "ERR INCR option supports a single increment-element pair"
+	msgScoreNaN            = "ERR resulting score is not a number (NaN)"
+	msgLimitCombination    = "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX"
+	msgInvalidStreamID     = "ERR Invalid stream ID specified as stream command argument"
+	msgStreamIDTooSmall    = "ERR The ID specified in XADD is equal or smaller than the target stream top item"
+	msgStreamIDZero        = "ERR The ID specified in XADD must be greater than 0-0"
+	msgStreamExhausted     = "ERR The stream has exhausted the last possible ID, unable to add more items"
+	msgXgroupKeyNotFound   = "ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically."
+)
+
+// errWrongNumber is the error for a command called with the wrong number of
+// arguments.
+func errWrongNumber(cmd string) string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
+}
+
+// errInvalidExpire is the error for a bad expire time in SET and friends.
+func errInvalidExpire(cmd string) string {
+	return fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(cmd))
+}
+
+// redisVersion is the redis version we report in HELLO and INFO.
+const redisVersion = "7.0.0"
+
 type ShinyRedis struct {
 	sync.Mutex
 	srv         *server.Server
-	Port        int
+	port        int
 	Passwords   map[string]string // username password
 	Dbs         map[int]*RedisDB
-	Scripts     map[string]string // sha1 -> lua src
-	signal      *sync.Cond
-	Now         time.Time // time.Now() if not set.
+	Databases   int                  // number of databases, for SELECT &c. 16 by default.
+	selectedDB  int                  // DB id used in the direct Get(), Set() &c.
+	Scripts     map[string]string    // sha1 -> lua src
+	blocked     map[dbKey]*waitQueue // blocked clients, per key
+	pause       *clientPause         // CLIENT PAUSE, or nil
+	expiredKeys int                  // for INFO stats
+	Now         time.Time            // time.Now() if not set.
 	Subscribers map[*Subscriber]struct{}
 	Rand        *rand.Rand
 	Ctx         context.Context
 	CtxCancel   context.CancelFunc
+	expireWg    sync.WaitGroup // the background active expire goroutine
+	expireTick  time.Duration  // active expire interval, 0 if it's not running
+	expireReset chan struct{}  // wakes the active expire loop for a new tick
+}
+
+// NewShinyRedis makes a new, non-started, ShinyRedis object.
+func NewShinyRedis() *ShinyRedis {
+	m := ShinyRedis{
+		Passwords:   map[string]string{},
+		Dbs:         map[int]*RedisDB{},
+		Databases:   16,
+		Scripts:     map[string]string{},
+		Subscribers: map[*Subscriber]struct{}{},
+		blocked:     map[dbKey]*waitQueue{},
+	}
+	return &m
+}
+
+// Run creates and Start()s a ShinyRedis.
+func Run() (*ShinyRedis, error) {
+	m := NewShinyRedis()
+	return m, m.Start()
+}
+
+// Start starts a server. It listens on a random port on localhost. See also
+// Addr().
+func (m *ShinyRedis) Start() error {
+	return m.StartAddr("127.0.0.1:0")
+}
+
+// StartAddr runs ShinyRedis with a given addr. Examples: "127.0.0.1:6379",
+// ":6379", or "127.0.0.1:0"
+func (m *ShinyRedis) StartAddr(addr string) error {
+	s, err := server.NewServer(addr)
+	if err != nil {
+		return err
+	}
+	return m.start(s)
+}
+
+// StartTLS starts a server with a given address and TLS config.
+func (m *ShinyRedis) StartTLS(addr string, cfg *tls.Config) error {
+	s, err := server.NewServerTLS(addr, cfg)
+	if err != nil {
+		return err
+	}
+	return m.start(s)
+}
+
+func (m *ShinyRedis) start(s *server.Server) error {
+	m.Lock()
+	defer m.Unlock()
+	m.srv = s
+	m.port = s.Addr().Port
+	m.Ctx, m.CtxCancel = context.WithCancel(context.Background())
+	s.SetPreHook(m.preHook(s))
+
+	commandsCommand(m)
+	commandsConnection(m)
+	commandsClient(m)
+	commandsGeneric(m)
+	commandsServer(m)
+	commandsPubsub(m)
+	CommandsList(m)
+	commandsString(m)
+	commandsHash(m)
+	commandsSet(m)
+	commandsSortedSet(m)
+	commandsStream(m)
+	commandsTransaction(m)
+	return nil
+}
+
+// preHook gives the hook which runs before every command.
+func (m *ShinyRedis) preHook(s *server.Server) server.Callback {
+	dirty := dirtyOnReject(s)
+	return func(c *server.Peer, cmd string, args ...string) bool {
+		// The connection state is created under the lock, since CLIENT LIST
+		// reads it from other connections.
+		m.Lock()
+		getCtx(c)
+		m.Unlock()
+
+		m.waitPause(s, cmd)
+		return dirty(c, cmd, args...)
+	}
+}
+
+// effectiveNow returns m.Now if set, or the current time.
+func (m *ShinyRedis) effectiveNow() time.Time {
+	if !m.Now.IsZero() {
+		return m.Now
+	}
+	return time.Now().UTC()
+}
+
+// Close shuts down a ShinyRedis. Blocked clients are released and all
+// connections are closed.
+func (m *ShinyRedis) Close() {
+	m.Lock()
+	if m.srv == nil {
+		m.Unlock()
+		return
+	}
+	srv := m.srv
+	m.srv = nil
+	m.CtxCancel()
+	m.Unlock()
+
+	// the disconnect callbacks can lock m, so close the server outside the
+	// lock.
+	srv.Close()
+	m.expireWg.Wait()
+
+	m.Lock()
+	m.expireTick = 0
+	m.Unlock()
+}
+
+// Addr returns '127.0.0.1:12345'. Can be given to a Dial(). See also Host()
+// and Port(), which return the same things. Addr returns "" when the server
+// isn't running.
+func (m *ShinyRedis) Addr() string {
+	m.Lock()
+	defer m.Unlock()
+	if m.srv == nil {
+		return ""
+	}
+	return m.srv.Addr().String()
+}
+
+// Host returns the host part of Addr(), or "" when the server isn't running.
+func (m *ShinyRedis) Host() string {
+	m.Lock()
+	defer m.Unlock()
+	if m.srv == nil {
+		return ""
+	}
+	return m.srv.Addr().IP.String()
+}
+
+// Port returns the (random) port part of Addr().
+func (m *ShinyRedis) Port() string {
+	m.Lock()
+	defer m.Unlock()
+	return strconv.Itoa(m.port)
+}
+
+// redisRange gives Go offsets for something l long with start/end in
+// Redis semantics. Both start and end can be negative.
+// Used for string range and list range things.
+// The results can be used as: v[start:end]
+// Note that GETRANGE (on a string key) never returns an empty string when end
+// is a large negative number.
+func redisRange(l, start, end int, stringSymantics bool) (int, int) {
+	if start < 0 {
+		start = l + start
+		if start < 0 {
+			start = 0
+		}
+	}
+	if start > l {
+		start = l
+	}
+
+	if end < 0 {
+		end = l + end
+		if end < 0 {
+			end = -1
+			if stringSymantics {
+				end = 0
+			}
+		}
+	}
+	if end >= l {
+		end = l - 1 // clamp first, so end++ can't overflow
+	}
+	end++ // end argument is inclusive in Redis.
+
+	if end < start {
+		return 0, 0
+	}
+	return start, end
+}
+
+// reverseSlice reverses a string slice in place.
+func reverseSlice(l []string) {
+	for i, j := 0, len(l)-1; i < j; i, j = i+1, j-1 {
+		l[i], l[j] = l[j], l[i]
+	}
+}
+
+// formatIncrFloat formats the result of INCRBYFLOAT and HINCRBYFLOAT the way
+// redis stores it: no exponent, no trailing zeros. Scores and doubles use
+// server.FormatFloat instead.
+func formatIncrFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// FastForward decreases all TTLs by the given duration, and removes the keys
+// which expired. If Now is set it moves forward as well, so EXPIREAT and
+// friends stay consistent.
+func (m *ShinyRedis) FastForward(d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	if !m.Now.IsZero() {
+		m.Now = m.Now.Add(d)
+	}
+	for _, db := range m.Dbs {
+		m.expiredKeys += db.fastForward(d)
+	}
+	m.serveBlocked()
+}
+
+// ActiveExpire starts a background goroutine which expires keys by itself,
+// like the active expire cycle in redis. Every tick all TTLs are decreased by
+// the wall clock time passed since the previous tick, and the expired keys
+// are removed. It runs until the server is closed. Call it after Start().
+// Calling it again only changes the tick. Mixing it with FastForward() is
+// fine, but Now is not changed.
+func (m *ShinyRedis) ActiveExpire(tick time.Duration) error {
+	if tick <= 0 {
+		return fmt.Errorf("invalid active expire tick: %s", tick)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if m.srv == nil {
+		return errors.New("server not running")
+	}
+	if m.expireTick > 0 {
+		m.expireTick = tick
+		select {
+		case m.expireReset <- struct{}{}:
+		default:
+			// a reset is pending already
+		}
+		return nil
+	}
+	m.expireTick = tick
+	m.expireReset = make(chan struct{}, 1)
+	m.expireWg.Add(1)
+	go m.activeExpire(m.Ctx, tick)
+	return nil
+}
+
+func (m *ShinyRedis) activeExpire(ctx context.Context, tick time.Duration) {
+	defer m.expireWg.Done()
+
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.expireReset:
+			m.Lock()
+			t.Reset(m.expireTick)
+			m.Unlock()
+		case now := <-t.C:
+			m.Lock()
+			expired := 0
+			for _, db := range m.Dbs {
+				expired += db.fastForward(now.Sub(last))
+			}
+			m.expiredKeys += expired
+			if expired > 0 {
+				// blocked commands might be waiting on these keys
+				m.serveBlocked()
+			}
+			m.Unlock()
+			last = now
+		}
+	}
+}
+
+// validDB tells whether i is a valid database index.
+func (m *ShinyRedis) validDB(i int) bool {
+	return i >= 0 && i < m.Databases
+}
+
+// Seed sets a fixed seed for the random source used by SPOP, SRANDMEMBER,
+// HRANDFIELD, and friends, so tests get reproducible results.
+func (m *ShinyRedis) Seed(seed int64) {
+	m.Lock()
+	defer m.Unlock()
+	m.Rand = rand.New(rand.NewSource(seed))
+}
+
+// randIntn is rand.Intn(), using m.Rand if it's set. Needs the lock.
+func (m *ShinyRedis) randIntn(n int) int {
+	if m.Rand == nil {
+		return rand.Intn(n)
+	}
+	return m.Rand.Intn(n)
+}
+
+// parseRandomCount parses the count argument of SRANDMEMBER and HRANDFIELD.
+// It gives an error message if it's not valid.
+func parseRandomCount(s string) (int, string) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, msgInvalidInt
+	}
+	if n == math.MinInt64 {
+		// can't be negated
+		return 0, msgValueOutOfRange
+	}
+	return n, ""
+}
+
+// randomMembers picks count random elements, the same way redis does for
+// SRANDMEMBER and HRANDFIELD: a positive count gives distinct elements, a
+// negative count allows the same element more than once. The count can't be
+// math.MinInt64, see parseRandomCount(). Needs the lock.
+func (m *ShinyRedis) randomMembers(l []string, count int) []string {
+	if len(l) == 0 {
+		return nil
+	}
+	if count < 0 {
+		var res []string
+		for i := 0; i < -count; i++ {
+			res = append(res, l[m.randIntn(len(l))])
+		}
+		return res
+	}
+	res := append([]string{}, l...)
+	for i := len(res) - 1; i > 0; i-- {
+		j := m.randIntn(i + 1)
+		res[i], res[j] = res[j], res[i]
+	}
+	if count < len(res) {
+		res = res[:count]
+	}
+	return res
 }
@@ -1,8 +1,11 @@
 package datastructure
 
 import (
+	"errors"
+	"math"
 	"shiny_redis/server"
-	"sync"
+	"sort"
+	"strconv"
 	"time"
 )
 
@@ -11,17 +14,29 @@ type listKey []string
 type setKey map[string]struct{}
 
 type RedisDB struct {
-	master     *ShinyRedis              // pointer to the lock in Miniredis
-	id         int                      // db id
-	keys       map[string]string        // Master map of keys with their type
-	stringKeys map[string]string        // GET/SET &c. keys
-	hashKeys   map[string]hashKey       // MGET/MSET &c. keys
-	listKeys   map[string]listKey       // LPUSH &c. keys
-	setKeys    map[string]setKey        // SADD &c. keys
-	ttl        map[string]time.Duration // effective TTL values
-	keyVersion map[string]uint          // used to watch values
+	master        *ShinyRedis              // pointer to the lock in Miniredis
+	id            int                      // db id
+	keys          map[string]string        // Master map of keys with their type
+	stringKeys    map[string]string        // GET/SET &c. keys
+	hashKeys      map[string]hashKey       // MGET/MSET &c. keys
+	listKeys      map[string]listKey       // LPUSH &c. keys
+	setKeys       map[string]setKey        // SADD &c. keys
+	sortedsetKeys map[string]*sortedSet    // ZADD &c. keys
+	streamKeys    map[string]*streamKey    // XADD &c. keys
+	ttl           map[string]time.Duration // effective TTL values
+	keyVersion    map[string]uint          // used to watch values
 }
 
+var (
+	errWrongType   = errors.New(msgWrongType)
+	errIntValue    = errors.New(msgInvalidInt)
+	errIntOverflow = errors.New(msgIncrOverflow)
+	errFloatValue  = errors.New(msgInvalidFloat)
+	errFloatNaNInf = errors.New("ERR increment would produce NaN or Infinity")
+	errHashInt     = errors.New("ERR hash value is not an integer")
+	errHashFloat   = errors.New("ERR hash value is not a float")
+)
+
 type dbKey struct {
 	db  int
 	key string
@@ -33,10 +48,12 @@ type txCmd func(*server.Peer, *connCtx)
 type connCtx struct {
 	selectedDB       int            // selected DB
 	authenticated    bool           // auth enabled and a valid AUTH seen
+	user             string         // set by AUTH and HELLO, DefaultUsername if empty
 	transaction      []txCmd        // transaction callbacks. Or nil.
 	dirtyTransaction bool           // any error during QUEUEing
 	watch            map[dbKey]uint // WATCHed keys
 	subscriber       *Subscriber    // client is in PUBSUB mode if not nil
+	noEvict          bool           // CLIENT NO-EVICT
 	nested           bool           // this is called via Lua
 }
 
@@ -52,15 +69,17 @@ func (m *ShinyRedis) db(i int) *RedisDB {
 
 func newRedisDB(id int, m *ShinyRedis) RedisDB {
 	return RedisDB{
-		id:         id,
-		master:     m,
-		keys:       map[string]string{},
-		stringKeys: map[string]string{},
-		hashKeys:   map[string]hashKey{},
-		listKeys:   map[string]listKey{},
-		setKeys:    map[string]setKey{},
-		ttl:        map[string]time.Duration{},
-		keyVersion: map[string]uint{},
+		id:            id,
+		master:        m,
+		keys:          map[string]string{},
+		stringKeys:    map[string]string{},
+		hashKeys:      map[string]hashKey{},
+		listKeys:      map[string]listKey{},
+		setKeys:       map[string]setKey{},
+		sortedsetKeys: map[string]*sortedSet{},
+		streamKeys:    map[string]*streamKey{},
+		ttl:           map[string]time.Duration{},
+		keyVersion:    map[string]uint{},
 	}
 }
 
@@ -84,30 +103,37 @@ func withTx(
 	ctx := getCtx(c)
 
 	if ctx.nested {
-		// this is a call via Lua's .call(). It's already locked.
+		// this is a call via Lua's .call(). It's already locked. The calling
+		// command serves the blocked clients.
 		fn(c, ctx)
-		m.signal.Broadcast()
 		return
 	}
 
 	if inTx(ctx) {
+		m.Lock()
 		addTxCmd(ctx, fn)
+		m.Unlock()
 		c.WriteInline("QUEUED")
 		return
 	}
 	m.Lock()
 	fn(c, ctx)
-	// done, wake up anyone who waits on anything.
-	m.signal.Broadcast()
+	// done, serve the clients waiting on the keys we changed.
+	m.serveBlocked()
 	m.Unlock()
 }
 
 // blockCmd is executed returns whether it is done
 type blockCmd func(*server.Peer, *connCtx) bool
 
+// blocking runs fn, and if it's not done waits until one of the keys changes
+// and tries again. Clients waiting on the same key are served in the order
+// they started waiting, see serveBlocked(). onTimeout is called when the
+// timeout passes first. A 0 timeout waits forever.
 func blocking(
 	m *ShinyRedis,
 	c *server.Peer,
+	keys []string,
 	timeout time.Duration,
 	fn blockCmd,
 	onTimeout func(*server.Peer),
@@ -118,54 +144,78 @@ func blocking(
 		dlc <-chan time.Time
 	)
 
+	if ctx.nested {
+		// this is a call via Lua's .call(). It's already locked, and it
+		// doesn't block. The calling command serves the blocked clients.
+		if !fn(c, ctx) {
+			onTimeout(c)
+		}
+		return
+	}
+
 	if inTx(ctx) {
+		// in a transaction blocking commands don't block, they time out
+		// right away.
+		m.Lock()
 		addTxCmd(ctx, func(c *server.Peer, ctx *connCtx) {
 			if !fn(c, ctx) {
 				onTimeout(c)
 			}
-			c.WriteInline("QUEUED")
-			return
 		})
+		m.Unlock()
+		c.WriteInline("QUEUED")
+		return
 	}
 	if timeout != 0 {
 		dl = time.NewTimer(timeout)
 		defer dl.Stop()
 		dlc = dl.C
 	}
+
 	m.Lock()
-	defer m.Unlock()
-	for {
-		done := fn(c, ctx)
-		if done {
-			return
-		}
-		// there is no cond.WaitTimeout(), so hence the the goroutine to wait
-		// for a timeout
-		var (
-			wg     sync.WaitGroup
-			wakeup = make(chan struct{}, 1)
-		)
-		wg.Add(1)
-		go func() {
-			m.signal.Wait()
-			wakeup <- struct{}{}
-			wg.Done()
-		}()
-		select {
-		case <-wakeup:
-		case <-dlc:
+	if fn(c, ctx) {
+		m.serveBlocked()
+		m.Unlock()
+		return
+	}
+	b := m.block(c, ctx, keys, fn, onTimeout)
+	m.Unlock()
+
+	select {
+	case <-b.done:
+		// served by serveBlocked() or CLIENT UNBLOCK, the reply is written.
+	case <-dlc:
+		m.Lock()
+		if m.unblock(b) {
 			onTimeout(c)
-			m.signal.Broadcast() // to kill the wakeup go routine
-			wg.Wait()
-			return
-		case <-m.Ctx.Done():
-			m.signal.Broadcast() // to kill the wakeup go routine
-			wg.Wait()
-			return
 		}
-		wg.Wait()
+		m.Unlock()
+	case <-c.Gone():
+		// the client disconnected, the next push is for someone else.
+		m.Lock()
+		m.unblock(b)
+		m.Unlock()
+	case <-m.Ctx.Done():
+		m.Lock()
+		m.unblock(b)
+		m.Unlock()
 	}
+}
 
+// parseBlockTimeout parses the timeout of a blocking command: seconds, as a
+// float. 0 is no timeout. On error the error message is returned.
+func parseBlockTimeout(s string) (time.Duration, string) {
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) {
+		return 0, msgInvalidTimeout
+	}
+	if secs < 0 {
+		return 0, msgNegTimeout
+	}
+	if secs > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, msgTimeoutOutOfRange
+	}
+	return time.Duration(secs * float64(time.Second)), ""
 }
 
 func addTxCmd(ctx *connCtx, cb txCmd) {
@@ -205,6 +255,61 @@ func (db *RedisDB) listLpush(k, v string) int {
 	return len(l)
 }
 
+// listPush is 'right push', aka append. Returns the new length.
+func (db *RedisDB) listPush(k, v string) int {
+	l, ok := db.listKeys[k]
+	if !ok {
+		db.keys[k] = "list"
+	}
+	l = append(l, v)
+	db.listKeys[k] = l
+	db.keyVersion[k]++
+	return len(l)
+}
+
+// listXpush pushes on either side. Returns the new length.
+func (db *RedisDB) listXpush(k, v string, lr leftright) int {
+	if lr == left {
+		return db.listLpush(k, v)
+	}
+	return db.listPush(k, v)
+}
+
+// listXpop pops from either side.
+func (db *RedisDB) listXpop(k string, lr leftright) string {
+	if lr == left {
+		return db.listLpop(k)
+	}
+	return db.listPop(k)
+}
+
+// listMove pops from src and pushes the element on dst. With src and dst the
+// same key the list is rotated in place, so the key and its TTL stay, even
+// with a single element.
+func (db *RedisDB) listMove(src, dst string, from, to leftright) string {
+	if src != dst {
+		elem := db.listXpop(src, from)
+		db.listXpush(dst, elem, to)
+		return elem
+	}
+
+	l := db.listKeys[src]
+	var elem string
+	if from == left {
+		elem, l = l[0], l[1:]
+	} else {
+		elem, l = l[len(l)-1], l[:len(l)-1]
+	}
+	if to == left {
+		l = append([]string{elem}, l...)
+	} else {
+		l = append(l, elem)
+	}
+	db.listKeys[src] = l
+	db.keyVersion[src]++
+	return elem
+}
+
 func (db *RedisDB) listPop(k string) string {
 	l := db.listKeys[k]
 	el := l[len(l)-1]
@@ -218,6 +323,450 @@ func (db *RedisDB) listPop(k string) string {
 	return el
 }
 
+// stringGet gives the value of a string key. No type checks.
+func (db *RedisDB) stringGet(k string) string {
+	return db.stringKeys[k]
+}
+
+// stringSet force sets a string key. The TTL is kept.
+func (db *RedisDB) stringSet(k, v string) {
+	db.del(k, false)
+	db.keys[k] = "string"
+	db.stringKeys[k] = v
+	db.keyVersion[k]++
+}
+
+// stringIncr changes an int key value. A missing key counts as 0.
+func (db *RedisDB) stringIncr(k string, delta int) (int, error) {
+	v := 0
+	if sv, ok := db.stringKeys[k]; ok {
+		var err error
+		v, err = strconv.Atoi(sv)
+		if err != nil {
+			return 0, errIntValue
+		}
+	}
+	if (delta > 0 && v > math.MaxInt64-delta) || (delta < 0 && v < math.MinInt64-delta) {
+		return 0, errIntOverflow
+	}
+	v += delta
+	db.stringSet(k, strconv.Itoa(v))
+	return v, nil
+}
+
+// stringIncrfloat changes a float key value. A missing key counts as 0.
+func (db *RedisDB) stringIncrfloat(k string, delta float64) (float64, error) {
+	v := 0.0
+	if sv, ok := db.stringKeys[k]; ok {
+		var err error
+		v, err = strconv.ParseFloat(sv, 64)
+		if err != nil {
+			return 0, errFloatValue
+		}
+	}
+	v += delta
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errFloatNaNInf
+	}
+	db.stringSet(k, formatIncrFloat(v))
+	return v, nil
+}
+
+// hashFields gives the sorted fields of a hash key.
+func (db *RedisDB) hashFields(k string) []string {
+	v := db.hashKeys[k]
+	var r []string
+	for k := range v {
+		r = append(r, k)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// hashSet sets a hash field. Creates the key if needed. Returns whether the
+// field is new.
+func (db *RedisDB) hashSet(k, f, v string) bool {
+	if t, ok := db.keys[k]; ok && t != "hash" {
+		db.del(k, true)
+	}
+	db.keys[k] = "hash"
+	if _, ok := db.hashKeys[k]; !ok {
+		db.hashKeys[k] = map[string]string{}
+	}
+	_, found := db.hashKeys[k][f]
+	db.hashKeys[k][f] = v
+	db.keyVersion[k]++
+	return !found
+}
+
+// hashIncr changes an int hash field. A missing field counts as 0.
+func (db *RedisDB) hashIncr(k, f string, delta int) (int, error) {
+	v := 0
+	if h, ok := db.hashKeys[k]; ok {
+		if sv, ok := h[f]; ok {
+			var err error
+			v, err = strconv.Atoi(sv)
+			if err != nil {
+				return 0, errHashInt
+			}
+		}
+	}
+	if (delta > 0 && v > math.MaxInt64-delta) || (delta < 0 && v < math.MinInt64-delta) {
+		return 0, errIntOverflow
+	}
+	v += delta
+	db.hashSet(k, f, strconv.Itoa(v))
+	return v, nil
+}
+
+// hashIncrfloat changes a float hash field. A missing field counts as 0.
+func (db *RedisDB) hashIncrfloat(k, f string, delta float64) (float64, error) {
+	v := 0.0
+	if h, ok := db.hashKeys[k]; ok {
+		if sv, ok := h[f]; ok {
+			var err error
+			v, err = strconv.ParseFloat(sv, 64)
+			if err != nil {
+				return 0, errHashFloat
+			}
+		}
+	}
+	v += delta
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errFloatNaNInf
+	}
+	db.hashSet(k, f, formatIncrFloat(v))
+	return v, nil
+}
+
+// setKeyMembers gives the sorted members of a set.
+func setKeyMembers(s setKey) []string {
+	var r []string
+	for e := range s {
+		r = append(r, e)
+	}
+	sort.Strings(r)
+	return r
+}
+
+// setMembers gives the sorted members of a set key.
+func (db *RedisDB) setMembers(k string) []string {
+	return setKeyMembers(db.setKeys[k])
+}
+
+// setIsMember tells whether e is a member of set key k.
+func (db *RedisDB) setIsMember(k, e string) bool {
+	_, ok := db.setKeys[k][e]
+	return ok
+}
+
+// setSet replaces a whole set. An empty set deletes the key.
+func (db *RedisDB) setSet(k string, set setKey) {
+	if len(set) == 0 {
+		db.del(k, true)
+		return
+	}
+	db.keys[k] = "set"
+	db.setKeys[k] = set
+	db.keyVersion[k]++
+}
+
+// setAdd adds members to a set. Returns the number of new members.
+func (db *RedisDB) setAdd(k string, elems ...string) int {
+	s, ok := db.setKeys[k]
+	if !ok {
+		s = setKey{}
+		db.keys[k] = "set"
+		db.setKeys[k] = s
+	}
+	added := 0
+	for _, e := range elems {
+		if _, ok := s[e]; !ok {
+			added++
+		}
+		s[e] = struct{}{}
+	}
+	db.keyVersion[k]++
+	return added
+}
+
+// setRem removes members from a set. Returns the number of removed members.
+// An empty set deletes the key.
+func (db *RedisDB) setRem(k string, elems ...string) int {
+	s := db.setKeys[k]
+	removed := 0
+	for _, e := range elems {
+		if _, ok := s[e]; ok {
+			removed++
+			delete(s, e)
+		}
+	}
+	if len(s) == 0 {
+		db.del(k, true)
+	} else if removed > 0 {
+		db.keyVersion[k]++
+	}
+	return removed
+}
+
+// setDiff gives the members of the first set which are in none of the
+// others. Missing keys are empty sets.
+func (db *RedisDB) setDiff(keys []string) (setKey, error) {
+	for _, k := range keys {
+		if db.exists(k) && db.t(k) != "set" {
+			return nil, errWrongType
+		}
+	}
+	res := setKey{}
+	for e := range db.setKeys[keys[0]] {
+		res[e] = struct{}{}
+	}
+	for _, k := range keys[1:] {
+		for e := range db.setKeys[k] {
+			delete(res, e)
+		}
+	}
+	return res, nil
+}
+
+// setInter gives the members which are in all sets. Missing keys are empty
+// sets.
+func (db *RedisDB) setInter(keys []string) (setKey, error) {
+	for _, k := range keys {
+		if db.exists(k) && db.t(k) != "set" {
+			return nil, errWrongType
+		}
+	}
+	res := setKey{}
+	for e := range db.setKeys[keys[0]] {
+		res[e] = struct{}{}
+	}
+	for _, k := range keys[1:] {
+		for e := range res {
+			if !db.setIsMember(k, e) {
+				delete(res, e)
+			}
+		}
+	}
+	return res, nil
+}
+
+// setUnion gives the members which are in any of the sets.
+func (db *RedisDB) setUnion(keys []string) (setKey, error) {
+	for _, k := range keys {
+		if db.exists(k) && db.t(k) != "set" {
+			return nil, errWrongType
+		}
+	}
+	res := setKey{}
+	for _, k := range keys {
+		for e := range db.setKeys[k] {
+			res[e] = struct{}{}
+		}
+	}
+	return res, nil
+}
+
+// ssetCard gives the number of members of a sorted set key.
+func (db *RedisDB) ssetCard(k string) int {
+	ss, ok := db.sortedsetKeys[k]
+	if !ok {
+		return 0
+	}
+	return ss.card()
+}
+
+// ssetScore gives the score of a member of a sorted set key.
+func (db *RedisDB) ssetScore(k, member string) (float64, bool) {
+	ss, ok := db.sortedsetKeys[k]
+	if !ok {
+		return 0, false
+	}
+	return ss.get(member)
+}
+
+// ssetAdd adds or updates a member of a sorted set key, creating the key if
+// needed. Returns whether the member is new.
+func (db *RedisDB) ssetAdd(k string, score float64, member string) bool {
+	ss, ok := db.sortedsetKeys[k]
+	if !ok {
+		ss = newSortedSet()
+		db.keys[k] = "zset"
+		db.sortedsetKeys[k] = ss
+	}
+	db.keyVersion[k]++
+	return ss.set(score, member)
+}
+
+// ssetSet replaces a whole sorted set. An empty set deletes the key.
+func (db *RedisDB) ssetSet(k string, ss *sortedSet) {
+	if ss.card() == 0 {
+		db.del(k, true)
+		return
+	}
+	db.keys[k] = "zset"
+	db.sortedsetKeys[k] = ss
+	db.keyVersion[k]++
+}
+
+// ssetRem removes members from a sorted set key. Returns the number of
+// removed members. An empty set deletes the key.
+func (db *RedisDB) ssetRem(k string, members ...string) int {
+	ss := db.sortedsetKeys[k]
+	removed := 0
+	for _, member := range members {
+		if ss.remove(member) {
+			removed++
+		}
+	}
+	if ss.card() == 0 {
+		db.del(k, true)
+	} else if removed > 0 {
+		db.keyVersion[k]++
+	}
+	return removed
+}
+
+// ssetOrSetElems gives the elements of a zset key, or of a set key with
+// every member at score 1, as used by ZUNIONSTORE and ZINTERSTORE.
+func (db *RedisDB) ssetOrSetElems(k string) []ssElem {
+	if ss, ok := db.sortedsetKeys[k]; ok {
+		return ss.elems()
+	}
+	var res []ssElem
+	for _, member := range db.setMembers(k) {
+		res = append(res, ssElem{member: member, score: 1})
+	}
+	return res
+}
+
+// streamCreate stores a new stream.
+func (db *RedisDB) streamCreate(k string, s *streamKey) {
+	db.keys[k] = "stream"
+	db.streamKeys[k] = s
+	db.keyVersion[k]++
+}
+
+// fastForward decreases all TTLs, and removes the expired keys. Returns the
+// number of removed keys.
+func (db *RedisDB) fastForward(d time.Duration) int {
+	n := 0
+	for k, ttl := range db.ttl {
+		db.ttl[k] = ttl - d
+		if db.checkTTL(k) {
+			n++
+		}
+	}
+	return n
+}
+
+// checkTTL removes the key if its TTL ran out. Returns whether it did.
+func (db *RedisDB) checkTTL(k string) bool {
+	if ttl, ok := db.ttl[k]; ok && ttl <= 0 {
+		db.del(k, true)
+		return true
+	}
+	return false
+}
+
+// allKeys gives all keys, sorted.
+func (db *RedisDB) allKeys() []string {
+	res := make([]string, 0, len(db.keys))
+	for k := range db.keys {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// copy duplicates key src, with its TTL, into key dst of db to. dst must not
+// exist.
+func (db *RedisDB) copy(src string, to *RedisDB, dst string) {
+	t := db.t(src)
+	switch t {
+	case "string":
+		to.stringKeys[dst] = db.stringKeys[src]
+	case "hash":
+		h := hashKey{}
+		for f, v := range db.hashKeys[src] {
+			h[f] = v
+		}
+		to.hashKeys[dst] = h
+	case "list":
+		to.listKeys[dst] = append(listKey(nil), db.listKeys[src]...)
+	case "set":
+		s := setKey{}
+		for e := range db.setKeys[src] {
+			s[e] = struct{}{}
+		}
+		to.setKeys[dst] = s
+	case "zset":
+		to.sortedsetKeys[dst] = db.sortedsetKeys[src].copy()
+	case "stream":
+		to.streamKeys[dst] = db.streamKeys[src].copy()
+	default:
+		panic("Unknown key type: " + t)
+	}
+	to.keys[dst] = t
+	to.keyVersion[dst]++
+	if ttl, ok := db.ttl[src]; ok {
+		to.ttl[dst] = ttl
+	}
+}
+
+// move moves key src, with its TTL, to key dst of db to. dst must not exist.
+func (db *RedisDB) move(src string, to *RedisDB, dst string) {
+	t := db.t(src)
+	switch t {
+	case "string":
+		to.stringKeys[dst] = db.stringKeys[src]
+	case "hash":
+		to.hashKeys[dst] = db.hashKeys[src]
+	case "list":
+		to.listKeys[dst] = db.listKeys[src]
+	case "set":
+		to.setKeys[dst] = db.setKeys[src]
+	case "zset":
+		to.sortedsetKeys[dst] = db.sortedsetKeys[src]
+	case "stream":
+		to.streamKeys[dst] = db.streamKeys[src]
+	default:
+		panic("Unknown key type: " + t)
+	}
+	to.keys[dst] = t
+	to.keyVersion[dst]++
+	if ttl, ok := db.ttl[src]; ok {
+		to.ttl[dst] = ttl
+	}
+	db.del(src, true)
+}
+
+// flush removes all keys.
+func (db *RedisDB) flush() {
+	for k := range db.keys {
+		db.del(k, true)
+	}
+}
+
+// swapDB swaps the contents of two databases. Every key which exists in
+// either of them changes, as far as WATCH is concerned.
+func (m *ShinyRedis) swapDB(i, j int) {
+	if i == j {
+		return
+	}
+	a, b := m.db(i), m.db(j)
+	*a, *b = *b, *a
+	a.id, b.id = i, j
+	// the versions stay with the database, so they keep going up
+	a.keyVersion, b.keyVersion = b.keyVersion, a.keyVersion
+	for _, db := range []*RedisDB{a, b} {
+		for k := range db.keys {
+			a.keyVersion[k]++
+			b.keyVersion[k]++
+		}
+	}
+}
+
 func (db *RedisDB) del(k string, delTTL bool) {
 	if !db.exists(k) {
 		return
@@ -238,9 +787,9 @@ func (db *RedisDB) del(k string, delTTL bool) {
 	case "set":
 		delete(db.setKeys, k)
 	case "zset":
-		//delete(db.sortedsetKeys, k)
+		delete(db.sortedsetKeys, k)
 	case "stream":
-		//delete(db.streamKeys, k)
+		delete(db.streamKeys, k)
 	default:
 		panic("Unknown key type: " + t)
 	}
@@ -0,0 +1,136 @@
+package datastructure
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	m := NewShinyRedis()
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Addr(), net.JoinHostPort(m.Host(), m.Port()); got != want {
+		t.Errorf("Addr: got %q, want %q", got, want)
+	}
+	if m.Host() != "127.0.0.1" {
+		t.Errorf("Host: got %q", m.Host())
+	}
+
+	c := dial(t, m)
+	c.must("-ERR EXEC without MULTI", "EXEC")
+
+	// the address is taken now
+	other := NewShinyRedis()
+	if err := other.StartAddr(m.Addr()); err == nil {
+		other.Close()
+		t.Error("StartAddr on a used address: no error")
+	}
+
+	addr := m.Addr()
+	m.Close()
+	m.Close() // twice is fine
+
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("could connect after Close")
+	}
+	if got := m.Addr(); got != "" {
+		t.Errorf("Addr after Close: got %q", got)
+	}
+	if got := m.Host(); got != "" {
+		t.Errorf("Host after Close: got %q", got)
+	}
+}
+
+func TestAddrNotStarted(t *testing.T) {
+	m := NewShinyRedis()
+	if got := m.Addr(); got != "" {
+		t.Errorf("Addr: got %q", got)
+	}
+	if got := m.Host(); got != "" {
+		t.Errorf("Host: got %q", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	c := dial(t, m)
+	c.must("-ERR EXEC without MULTI", "EXEC")
+}
+
+func TestCloseReleasesBlocked(t *testing.T) {
+	m, c := start(t)
+	c.send("BLPOP", "q", "0")
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		m.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Close hangs on a blocked client")
+	}
+}
+
+func TestActiveExpire(t *testing.T) {
+	if err := NewShinyRedis().ActiveExpire(time.Millisecond); err == nil {
+		t.Error("ActiveExpire before Start: no error")
+	}
+
+	m, c := start(t)
+	for _, tick := range []time.Duration{0, -time.Second} {
+		if err := m.ActiveExpire(tick); err == nil {
+			t.Errorf("ActiveExpire(%s): no error", tick)
+		}
+	}
+
+	// calling it again only changes the tick, TTLs don't go any faster
+	for i := 0; i < 5; i++ {
+		if err := m.ActiveExpire(10 * time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.must(`+OK`, "SET", "a", "1", "PX", "300")
+	c.must(`+OK`, "SET", "b", "1")
+	time.Sleep(150 * time.Millisecond)
+	c.must(`$"1"`, "GET", "a")
+	time.Sleep(300 * time.Millisecond)
+	c.must(`nil`, "GET", "a")
+	c.must(`$"1"`, "GET", "b")
+}
+
+func TestActiveExpireTick(t *testing.T) {
+	m, c := start(t)
+	if err := m.ActiveExpire(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	c.must(`+OK`, "SET", "a", "1", "PX", "50")
+	if err := m.ActiveExpire(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	m.Lock()
+	_, ok := m.db(0).keys["a"]
+	m.Unlock()
+	if ok {
+		t.Error("key didn't expire with the new tick")
+	}
+
+	// Close() stops the loop, also with a blocked client
+	c.send("BLPOP", "q", "0")
+	time.Sleep(50 * time.Millisecond)
+	m.Close()
+	if err := m.ActiveExpire(time.Millisecond); err == nil {
+		t.Error("ActiveExpire after Close: no error")
+	}
+}
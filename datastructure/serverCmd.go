@@ -0,0 +1,311 @@
+package datastructure
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"shiny_redis/server"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandsServer handles the server commands: DBSIZE, FLUSHALL, &c.
+func commandsServer(m *ShinyRedis) {
+	m.srv.Register("DBSIZE", m.cmdDbsize, 1, "readonly fast", 0, 0, 0)
+	m.srv.Register("FLUSHALL", m.cmdFlushall, -1, "write", 0, 0, 0)
+	m.srv.Register("FLUSHDB", m.cmdFlushdb, -1, "write", 0, 0, 0)
+	m.srv.Register("INFO", m.cmdInfo, -1, "random loading stale", 0, 0, 0)
+	m.srv.Register("SWAPDB", m.cmdSwapdb, 3, "write fast", 0, 0, 0)
+}
+
+// DBSIZE
+func (m *ShinyRedis) cmdDbsize(c *server.Peer, cmd string, args []string) {
+	if len(args) != 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		c.WriteInt(len(db.keys))
+	})
+}
+
+// parseFlushOpt checks the optional ASYNC or SYNC argument of FLUSHDB and
+// FLUSHALL. Both flush right away.
+func parseFlushOpt(args []string) bool {
+	switch {
+	case len(args) == 0:
+		return true
+	case len(args) == 1:
+		opt := strings.ToUpper(args[0])
+		return opt == "ASYNC" || opt == "SYNC"
+	default:
+		return false
+	}
+}
+
+// FLUSHDB
+func (m *ShinyRedis) cmdFlushdb(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	if !parseFlushOpt(args) {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		m.db(ctx.selectedDB).flush()
+		c.WriteOK()
+	})
+}
+
+// FLUSHALL
+func (m *ShinyRedis) cmdFlushall(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	if !parseFlushOpt(args) {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		for _, db := range m.Dbs {
+			db.flush()
+		}
+		c.WriteOK()
+	})
+}
+
+// SWAPDB
+func (m *ShinyRedis) cmdSwapdb(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	id1, err := strconv.Atoi(args[0])
+	if err != nil {
+		setDirty(c)
+		c.WriteError("ERR invalid first DB index")
+		return
+	}
+	id2, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError("ERR invalid second DB index")
+		return
+	}
+	if !m.validDB(id1) || !m.validDB(id2) {
+		setDirty(c)
+		c.WriteError(msgDBIndexOutOfRange)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		m.swapDB(id1, id2)
+		c.WriteOK()
+	})
+}
+
+// infoSections are all INFO sections, in order. The bool tells whether the
+// section is in the default set.
+var infoSections = []struct {
+	name       string
+	defaultSet bool
+}{
+	{"server", true},
+	{"clients", true},
+	{"memory", true},
+	{"stats", true},
+	{"commandstats", false},
+	{"errorstats", true},
+	{"keyspace", true},
+}
+
+// INFO
+func (m *ShinyRedis) cmdInfo(c *server.Peer, cmd string, args []string) {
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	want := map[string]bool{}
+	for _, a := range args {
+		want[strings.ToLower(a)] = true
+	}
+	all := want["all"] || want["everything"]
+	def := len(args) == 0 || want["default"]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		var sections []string
+		for _, s := range infoSections {
+			if !all && !want[s.name] && !(def && s.defaultSet) {
+				continue
+			}
+			sections = append(sections, m.infoSection(s.name))
+		}
+		info := strings.Join(sections, "\r\n")
+		if c.Resp3() {
+			c.WriteVerbatim("txt", info)
+			return
+		}
+		c.WriteBulk(info)
+	})
+}
+
+// infoSection gives a single INFO section, with its header. Needs the lock.
+func (m *ShinyRedis) infoSection(name string) string {
+	var lines []string
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+
+	switch name {
+	case "server":
+		add("# Server")
+		uptime := time.Since(m.srv.Started())
+		add("redis_version:%s", redisVersion)
+		add("redis_mode:standalone")
+		add("os:%s %s", runtime.GOOS, runtime.GOARCH)
+		add("arch_bits:%d", strconv.IntSize)
+		add("go_version:%s", runtime.Version())
+		add("process_id:%d", os.Getpid())
+		add("tcp_port:%d", m.port)
+		add("uptime_in_seconds:%d", int(uptime.Seconds()))
+		add("uptime_in_days:%d", int(uptime.Hours()/24))
+	case "clients":
+		var pubsub int
+		peers := m.srv.Peers()
+		for _, p := range peers {
+			if clientType(p) == "pubsub" {
+				pubsub++
+			}
+		}
+		add("# Clients")
+		add("connected_clients:%d", len(peers))
+		add("blocked_clients:%d", len(m.blockedPeers()))
+		add("pubsub_clients:%d", pubsub)
+	case "memory":
+		// this is the memory of the whole Go process, which is as close as
+		// we get.
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		add("# Memory")
+		add("used_memory:%d", ms.HeapAlloc)
+		add("used_memory_human:%s", humanBytes(ms.HeapAlloc))
+		add("used_memory_rss:%d", ms.Sys)
+		add("used_memory_rss_human:%s", humanBytes(ms.Sys))
+	case "stats":
+		errs := 0
+		for _, n := range m.srv.ErrorStats() {
+			errs += n
+		}
+		add("# Stats")
+		add("total_connections_received:%d", m.srv.TotalConnections())
+		add("total_commands_processed:%d", m.srv.TotalCommands())
+		add("expired_keys:%d", m.expiredKeys)
+		add("pubsub_channels:%d", len(m.activeChannels()))
+		add("pubsub_patterns:%d", m.countPsubs())
+		add("pubsub_shardchannels:%d", len(m.activeShardChannels()))
+		add("total_error_replies:%d", errs)
+	case "commandstats":
+		stats := m.srv.CommandStats()
+		names := make([]string, 0, len(stats))
+		for name := range stats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		add("# Commandstats")
+		for _, name := range names {
+			st := stats[name]
+			perCall := 0.0
+			if st.Calls > 0 {
+				perCall = float64(st.Usec) / float64(st.Calls)
+			}
+			add("cmdstat_%s:calls=%d,usec=%d,usec_per_call=%.2f,rejected_calls=%d,failed_calls=%d",
+				name, st.Calls, st.Usec, perCall, st.RejectedCalls, st.FailedCalls)
+		}
+	case "errorstats":
+		stats := m.srv.ErrorStats()
+		prefixes := make([]string, 0, len(stats))
+		for p := range stats {
+			prefixes = append(prefixes, p)
+		}
+		sort.Strings(prefixes)
+		add("# Errorstats")
+		for _, p := range prefixes {
+			add("errorstat_%s:count=%d", p, stats[p])
+		}
+	case "keyspace":
+		ids := make([]int, 0, len(m.Dbs))
+		for id := range m.Dbs {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		add("# Keyspace")
+		for _, id := range ids {
+			db := m.Dbs[id]
+			if len(db.keys) == 0 {
+				continue
+			}
+			// in ms, a sum of a few long TTLs overflows a Duration
+			var total float64
+			for _, ttl := range db.ttl {
+				total += float64(ttl.Milliseconds())
+			}
+			avg := 0
+			if len(db.ttl) > 0 {
+				avg = int(total / float64(len(db.ttl)))
+			}
+			add("db%d:keys=%d,expires=%d,avg_ttl=%d", id, len(db.keys), len(db.ttl), avg)
+		}
+	}
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+// humanBytes formats a number of bytes the way INFO does: "1.50M".
+func humanBytes(n uint64) string {
+	f := float64(n)
+	for _, unit := range []string{"B", "K", "M", "G", "T"} {
+		if f < 1024 || unit == "T" {
+			if unit == "B" {
+				return fmt.Sprintf("%dB", n)
+			}
+			return fmt.Sprintf("%.2f%s", f, unit)
+		}
+		f /= 1024
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package datastructure
+
+import "testing"
+
+func TestSelect(t *testing.T) {
+	m, c := start(t)
+	c.must(`+OK`, "SET", "a", "0")
+	c.must(`+OK`, "SELECT", "3")
+	c.must(`nil`, "GET", "a")
+	c.must(`+OK`, "SET", "a", "3")
+	c.must(`+OK`, "SET", "b", "3")
+	c.must(`:2`, "DBSIZE")
+	c.must("-"+msgDBIndexOutOfRange, "SELECT", "16")
+	c.must("-"+msgDBIndexOutOfRange, "SELECT", "-1")
+	c.must("-"+msgInvalidInt, "SELECT", "x")
+
+	// SELECT is per connection
+	c2 := dial(t, m)
+	c2.must(`$"0"`, "GET", "a")
+
+	m.Lock()
+	m.Databases = 20
+	m.Unlock()
+	c2.must(`+OK`, "SELECT", "19")
+}
+
+func TestSwapdb(t *testing.T) {
+	_, c := start(t)
+	c.must(`+OK`, "SET", "a", "0")
+	c.must(`+OK`, "SELECT", "3")
+	c.must(`+OK`, "MSET", "a", "3", "b", "3")
+	c.must(`+OK`, "SWAPDB", "0", "3")
+	c.must(`:1`, "DBSIZE")
+	c.must(`$"0"`, "GET", "a")
+	c.must(`+OK`, "SELECT", "0")
+	c.must(`$"3"`, "GET", "a")
+	c.must(`+OK`, "SWAPDB", "0", "0")
+	c.must(`$"3"`, "GET", "a")
+	c.must(`-ERR invalid first DB index`, "SWAPDB", "x", "3")
+	c.must(`-ERR invalid second DB index`, "SWAPDB", "0", "x")
+	c.must("-"+msgDBIndexOutOfRange, "SWAPDB", "0", "30")
+}
+
+func TestFlush(t *testing.T) {
+	_, c := start(t)
+	c.must(`+OK`, "SET", "a", "0")
+	c.must(`+OK`, "SELECT", "3")
+	c.must(`+OK`, "SET", "a", "3")
+	c.must(`+OK`, "FLUSHDB", "ASYNC")
+	c.must(`:0`, "DBSIZE")
+	c.must(`-ERR syntax error`, "FLUSHDB", "FOO")
+	c.must(`+OK`, "SELECT", "0")
+	c.must(`:1`, "DBSIZE")
+	c.must(`+OK`, "FLUSHALL", "SYNC")
+	c.must(`:0`, "DBSIZE")
+}
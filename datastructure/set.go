@@ -0,0 +1,579 @@
+package datastructure
+
+import (
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+)
+
+// commandsSet handles all set value operations.
+func commandsSet(m *ShinyRedis) {
+	m.srv.Register("SADD", m.cmdSadd, -3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("SCARD", m.cmdScard, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("SDIFF", m.cmdSdiff, -2, "readonly", 1, -1, 1)
+	m.srv.Register("SDIFFSTORE", m.cmdSdiffstore, -3, "write denyoom", 1, -1, 1)
+	m.srv.Register("SINTER", m.cmdSinter, -2, "readonly", 1, -1, 1)
+	m.srv.Register("SINTERCARD", m.cmdSintercard, -3, "readonly movablekeys", 0, 0, 0)
+	m.srv.Register("SINTERSTORE", m.cmdSinterstore, -3, "write denyoom", 1, -1, 1)
+	m.srv.Register("SISMEMBER", m.cmdSismember, 3, "readonly fast", 1, 1, 1)
+	m.srv.Register("SMEMBERS", m.cmdSmembers, 2, "readonly", 1, 1, 1)
+	m.srv.Register("SMISMEMBER", m.cmdSmismember, -3, "readonly fast", 1, 1, 1)
+	m.srv.Register("SMOVE", m.cmdSmove, 4, "write fast", 1, 2, 1)
+	m.srv.Register("SPOP", m.cmdSpop, -2, "write random fast", 1, 1, 1)
+	m.srv.Register("SRANDMEMBER", m.cmdSrandmember, -2, "readonly random", 1, 1, 1)
+	m.srv.Register("SREM", m.cmdSrem, -3, "write fast", 1, 1, 1)
+	m.srv.Register("SSCAN", m.cmdSscan, -3, "readonly random", 1, 1, 1)
+	m.srv.Register("SUNION", m.cmdSunion, -2, "readonly", 1, -1, 1)
+	m.srv.Register("SUNIONSTORE", m.cmdSunionstore, -3, "write denyoom", 1, -1, 1)
+}
+
+// SADD
+func (m *ShinyRedis) cmdSadd(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, elems := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		added := db.setAdd(key, elems...)
+		c.WriteInt(added)
+	})
+}
+
+// SCARD
+func (m *ShinyRedis) cmdScard(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(len(db.setKeys[key]))
+	})
+}
+
+// SDIFF
+func (m *ShinyRedis) cmdSdiff(c *server.Peer, cmd string, args []string) {
+	m.cmdSetOp(c, cmd, args, (*RedisDB).setDiff)
+}
+
+// SINTER
+func (m *ShinyRedis) cmdSinter(c *server.Peer, cmd string, args []string) {
+	m.cmdSetOp(c, cmd, args, (*RedisDB).setInter)
+}
+
+// SUNION
+func (m *ShinyRedis) cmdSunion(c *server.Peer, cmd string, args []string) {
+	m.cmdSetOp(c, cmd, args, (*RedisDB).setUnion)
+}
+
+// setOp is one of RedisDB.setDiff, setInter, and setUnion.
+type setOp func(*RedisDB, []string) (setKey, error)
+
+// cmdSetOp handles SDIFF, SINTER, and SUNION.
+func (m *ShinyRedis) cmdSetOp(c *server.Peer, cmd string, args []string, op setOp) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		set, err := op(db, args)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		members := setKeyMembers(set)
+		c.Block(func(w *server.Writer) {
+			w.WriteSetLen(len(members))
+			for _, e := range members {
+				w.WriteBulk(e)
+			}
+		})
+	})
+}
+
+// SDIFFSTORE
+func (m *ShinyRedis) cmdSdiffstore(c *server.Peer, cmd string, args []string) {
+	m.cmdSetOpStore(c, cmd, args, (*RedisDB).setDiff)
+}
+
+// SINTERSTORE
+func (m *ShinyRedis) cmdSinterstore(c *server.Peer, cmd string, args []string) {
+	m.cmdSetOpStore(c, cmd, args, (*RedisDB).setInter)
+}
+
+// SUNIONSTORE
+func (m *ShinyRedis) cmdSunionstore(c *server.Peer, cmd string, args []string) {
+	m.cmdSetOpStore(c, cmd, args, (*RedisDB).setUnion)
+}
+
+// cmdSetOpStore handles SDIFFSTORE, SINTERSTORE, and SUNIONSTORE.
+func (m *ShinyRedis) cmdSetOpStore(c *server.Peer, cmd string, args []string, op setOp) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	dest, keys := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		set, err := op(db, keys)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		db.del(dest, true)
+		db.setSet(dest, set)
+		c.WriteInt(len(set))
+	})
+}
+
+// SINTERCARD
+func (m *ShinyRedis) cmdSintercard(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if numKeys <= 0 {
+		setDirty(c)
+		c.WriteError("ERR numkeys should be greater than 0")
+		return
+	}
+	args = args[1:]
+	if numKeys > len(args) {
+		setDirty(c)
+		c.WriteError(msgInvalidKeysNumber)
+		return
+	}
+	keys, args := args[:numKeys], args[numKeys:]
+
+	limit := 0
+	for len(args) > 0 {
+		if len(args) < 2 || strings.ToUpper(args[0]) != "LIMIT" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		if n < 0 {
+			setDirty(c)
+			c.WriteError(msgLimitIsNegative)
+			return
+		}
+		limit = n
+		args = args[2:]
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		set, err := db.setInter(keys)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		n := len(set)
+		if limit > 0 && n > limit {
+			n = limit
+		}
+		c.WriteInt(n)
+	})
+}
+
+// SISMEMBER
+func (m *ShinyRedis) cmdSismember(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		if db.setIsMember(key, value) {
+			c.WriteInt(1)
+			return
+		}
+		c.WriteInt(0)
+	})
+}
+
+// SMISMEMBER
+func (m *ShinyRedis) cmdSmismember(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, values := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(values))
+			for _, v := range values {
+				if db.setIsMember(key, v) {
+					w.WriteInt(1)
+				} else {
+					w.WriteInt(0)
+				}
+			}
+		})
+	})
+}
+
+// SMEMBERS
+func (m *ShinyRedis) cmdSmembers(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		members := db.setMembers(key)
+		c.Block(func(w *server.Writer) {
+			w.WriteSetLen(len(members))
+			for _, e := range members {
+				w.WriteBulk(e)
+			}
+		})
+	})
+}
+
+// SMOVE
+func (m *ShinyRedis) cmdSmove(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	src, dst, member := args[0], args[1], args[2]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(src) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(src) != "set" || (db.exists(dst) && db.t(dst) != "set") {
+			c.WriteError(msgWrongType)
+			return
+		}
+		if !db.setIsMember(src, member) {
+			c.WriteInt(0)
+			return
+		}
+		if src == dst {
+			// nothing moves, and the key is left alone
+			c.WriteInt(1)
+			return
+		}
+		db.setRem(src, member)
+		db.setAdd(dst, member)
+		c.WriteInt(1)
+	})
+}
+
+// SPOP
+func (m *ShinyRedis) cmdSpop(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			setDirty(c)
+			c.WriteError(msgNotPositive)
+			return
+		}
+		count = n
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			if withCount {
+				c.WriteSetLen(0)
+				return
+			}
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		popped := m.randomMembers(db.setMembers(key), count)
+		for _, e := range popped {
+			db.setRem(key, e)
+		}
+		if !withCount {
+			c.WriteBulk(popped[0])
+			return
+		}
+		c.Block(func(w *server.Writer) {
+			w.WriteSetLen(len(popped))
+			for _, e := range popped {
+				w.WriteBulk(e)
+			}
+		})
+	})
+}
+
+// SRANDMEMBER
+func (m *ShinyRedis) cmdSrandmember(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	withCount := len(args) == 2
+	count := 0
+	if withCount {
+		n, msg := parseRandomCount(args[1])
+		if msg != "" {
+			setDirty(c)
+			c.WriteError(msg)
+			return
+		}
+		count = n
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			if withCount {
+				c.WriteLen(0)
+				return
+			}
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		members := db.setMembers(key)
+		if !withCount {
+			c.WriteBulk(members[m.randIntn(len(members))])
+			return
+		}
+		c.WriteStrings(m.randomMembers(members, count))
+	})
+}
+
+// SREM
+func (m *ShinyRedis) cmdSrem(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, fields := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(db.setRem(key, fields...))
+	})
+}
+
+// SSCAN
+func (m *ShinyRedis) cmdSscan(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseScanOpts(args[1:], false, false)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "set" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		next, members := scanPage(db.setMembers(key), opts)
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(2)
+			w.WriteBulk(strconv.Itoa(next))
+			w.WriteStrings(members)
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package datastructure
+
+import "testing"
+
+func TestSmoveSameKey(t *testing.T) {
+	_, c := start(t)
+	c.must(":1", "SADD", "s", "a")
+	c.must(":1", "EXPIRE", "s", "100")
+	c.must(":1", "SMOVE", "s", "s", "a")
+	c.must(":0", "SMOVE", "s", "s", "b")
+	c.must(`*[$"a"]`, "SMEMBERS", "s")
+	c.must(":100", "TTL", "s")
+}
+
+func TestSetCommands(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "SADD", "s", "a", "b", "c")
+	c.must(":1", "SADD", "s", "a", "d")
+	c.must(":4", "SCARD", "s")
+	c.must(":0", "SCARD", "nosuch")
+	c.must(`*[$"a" $"b" $"c" $"d"]`, "SMEMBERS", "s")
+	c.must(`:1`, "SISMEMBER", "s", "a")
+	c.must(`:0`, "SISMEMBER", "s", "x")
+	c.must(`*[:1 :0]`, "SMISMEMBER", "s", "a", "x")
+	c.must(`:1`, "SREM", "s", "d", "x")
+	c.must(`:1`, "SMOVE", "s", "t", "a")
+	c.must(`:0`, "SMOVE", "s", "t", "a")
+	c.must(`*[$"b" $"c"]`, "SMEMBERS", "s")
+	c.must(`*[$"a"]`, "SMEMBERS", "t")
+	c.must(`:2`, "SREM", "s", "b", "c")
+	c.must(`:0`, "EXISTS", "s")
+
+	c.must("+OK", "SET", "str", "x")
+	c.must("-"+msgWrongType, "SADD", "str", "a")
+	c.must("-"+msgWrongType, "SMOVE", "t", "str", "a")
+
+	c.do("HELLO", "3")
+	c.must(`~[$"a"]`, "SMEMBERS", "t")
+}
+
+func TestSetAlgebra(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "SADD", "s", "a", "b", "c")
+	c.must(":2", "SADD", "t", "b", "z")
+	c.must(`*[$"b"]`, "SINTER", "s", "t")
+	c.must(`*[]`, "SINTER", "s", "t", "nosuch")
+	c.must(`*[$"a" $"c"]`, "SDIFF", "s", "t")
+	c.must(`*[$"a" $"b" $"c"]`, "SDIFF", "s", "nosuch")
+	c.must(`*[$"a" $"b" $"c" $"z"]`, "SUNION", "s", "t")
+	c.must(`:4`, "SUNIONSTORE", "u", "s", "t")
+	c.must(`:2`, "SDIFFSTORE", "u", "s", "t")
+	c.must(`*[$"a" $"c"]`, "SMEMBERS", "u")
+	c.must(`:0`, "SINTERSTORE", "u", "s", "nosuch")
+	c.must(`:0`, "EXISTS", "u")
+	c.must(`:1`, "SINTERCARD", "2", "s", "t")
+	c.must(`:2`, "SINTERCARD", "1", "s", "LIMIT", "2")
+	c.must(`-ERR numkeys should be greater than 0`, "SINTERCARD", "0", "s")
+	c.must(`-`+msgInvalidKeysNumber, "SINTERCARD", "3", "s")
+}
+
+func TestSetRandom(t *testing.T) {
+	m, c := start(t)
+	m.Seed(42)
+	c.must(`*[]`, "SPOP", "nosuch", "2")
+	c.must(`nil`, "SPOP", "nosuch")
+	c.must(`nil`, "SRANDMEMBER", "nosuch")
+	c.must(`*[]`, "SRANDMEMBER", "nosuch", "2")
+	c.must(":1", "SADD", "s", "a")
+	c.must(`$"a"`, "SRANDMEMBER", "s")
+	c.must(`*[$"a"]`, "SRANDMEMBER", "s", "5")
+	c.must(`*[$"a" $"a"]`, "SRANDMEMBER", "s", "-2")
+	c.must(`-`+msgNotPositive, "SPOP", "s", "-1")
+	c.must(`$"a"`, "SPOP", "s")
+	c.must(`:0`, "EXISTS", "s")
+	c.must(":2", "SADD", "s", "a", "b")
+	switch got := c.do("SPOP", "s", "5"); got {
+	case `*[$"a" $"b"]`, `*[$"b" $"a"]`:
+	default:
+		t.Errorf("SPOP: got %q", got)
+	}
+	c.must(`:0`, "EXISTS", "s")
+}
+
+func TestSscan(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "SADD", "s", "a", "b", "c")
+	c.must(`*[$"0" *[$"a" $"b" $"c"]]`, "SSCAN", "s", "0")
+	c.must(`*[$"2" *[$"a" $"b"]]`, "SSCAN", "s", "0", "COUNT", "2")
+	c.must(`*[$"0" *[$"c"]]`, "SSCAN", "s", "0", "MATCH", "c")
+	c.must(`*[$"0" *[]]`, "SSCAN", "nosuch", "0")
+}
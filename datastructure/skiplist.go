@@ -0,0 +1,427 @@
+package datastructure
+
+import (
+	"math/rand"
+)
+
+// The sorted set is the same as in redis: a map from member to score, and a
+// skiplist ordered by (score, member). The skiplist keeps the span of every
+// link, so ranks are O(log(N)) as well.
+
+const (
+	zslMaxLevel = 32
+	zslP        = 0.25
+)
+
+type zslLevel struct {
+	forward *zslNode
+	span    int // number of nodes the forward link skips
+}
+
+type zslNode struct {
+	member   string
+	score    float64
+	backward *zslNode
+	level    []zslLevel
+}
+
+type skiplist struct {
+	header *zslNode
+	tail   *zslNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		header: &zslNode{level: make([]zslLevel, zslMaxLevel)},
+		level:  1,
+	}
+}
+
+// zslRandomLevel gives a level with a power law distribution. The skiplist
+// shape doesn't change any reply, so it uses the global random source.
+func zslRandomLevel() int {
+	level := 1
+	for level < zslMaxLevel && rand.Float64() < zslP {
+		level++
+	}
+	return level
+}
+
+// before tells whether the node sorts before (score, member).
+func (n *zslNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+// insert adds a new node. The member must not be in the list already.
+func (zsl *skiplist) insert(score float64, member string) {
+	var (
+		update [zslMaxLevel]*zslNode
+		rank   [zslMaxLevel]int
+		x      = zsl.header
+	)
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i < zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := zslRandomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = &zslNode{
+		member: member,
+		score:  score,
+		level:  make([]zslLevel, level),
+	}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+}
+
+// delete removes the node with the given score and member. Returns whether it
+// was found.
+func (zsl *skiplist) delete(score float64, member string) bool {
+	var (
+		update [zslMaxLevel]*zslNode
+		x      = zsl.header
+	)
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+	return true
+}
+
+// rank gives the 1-based rank of a node, or 0 if it's not in the list.
+func (zsl *skiplist) rank(score float64, member string) int {
+	var (
+		rank = 0
+		x    = zsl.header
+	)
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.before(score, member) ||
+				(x.level[i].forward.score == score && x.level[i].forward.member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.score == score && x.member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// byRank gives the node with the 1-based rank, or nil.
+func (zsl *skiplist) byRank(rank int) *zslNode {
+	var (
+		traversed = 0
+		x         = zsl.header
+	)
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != zsl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+// first gives the first node for which below() is false. below() must be true
+// for a prefix of the list only.
+func (zsl *skiplist) first(below func(*zslNode) bool) *zslNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && below(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	return x.level[0].forward
+}
+
+// last gives the last node for which notAbove() is true, or nil. notAbove()
+// must be true for a prefix of the list only.
+func (zsl *skiplist) last(notAbove func(*zslNode) bool) *zslNode {
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && notAbove(x.level[i].forward) {
+			x = x.level[i].forward
+		}
+	}
+	if x == zsl.header {
+		return nil
+	}
+	return x
+}
+
+// ssElem is a member with its score.
+type ssElem struct {
+	member string
+	score  float64
+}
+
+// sortedSet is the value of a "zset" key.
+type sortedSet struct {
+	dict map[string]float64
+	zsl  *skiplist
+}
+
+func newSortedSet() *sortedSet {
+	return &sortedSet{
+		dict: map[string]float64{},
+		zsl:  newSkiplist(),
+	}
+}
+
+// copy gives an independent copy.
+func (ss *sortedSet) copy() *sortedSet {
+	res := newSortedSet()
+	for _, e := range ss.elems() {
+		res.set(e.score, e.member)
+	}
+	return res
+}
+
+func (ss *sortedSet) card() int {
+	return len(ss.dict)
+}
+
+// set adds or updates a member. Returns whether the member is new.
+func (ss *sortedSet) set(score float64, member string) bool {
+	old, ok := ss.dict[member]
+	if ok {
+		if old == score {
+			return false
+		}
+		ss.zsl.delete(old, member)
+	}
+	ss.dict[member] = score
+	ss.zsl.insert(score, member)
+	return !ok
+}
+
+func (ss *sortedSet) get(member string) (float64, bool) {
+	score, ok := ss.dict[member]
+	return score, ok
+}
+
+// remove deletes a member. Returns whether it was there.
+func (ss *sortedSet) remove(member string) bool {
+	score, ok := ss.dict[member]
+	if !ok {
+		return false
+	}
+	delete(ss.dict, member)
+	ss.zsl.delete(score, member)
+	return true
+}
+
+// rank gives the 0-based rank of a member, counted from the end if reverse
+// is set.
+func (ss *sortedSet) rank(member string, reverse bool) (int, bool) {
+	score, ok := ss.dict[member]
+	if !ok {
+		return 0, false
+	}
+	r := ss.zsl.rank(score, member) - 1
+	if reverse {
+		r = len(ss.dict) - 1 - r
+	}
+	return r, true
+}
+
+// elems gives all elements, ordered by score.
+func (ss *sortedSet) elems() []ssElem {
+	res := make([]ssElem, 0, len(ss.dict))
+	for x := ss.zsl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		res = append(res, ssElem{x.member, x.score})
+	}
+	return res
+}
+
+// rangeByRank gives the elements with 0-based ranks start...end inclusive.
+// Both must be valid, non-negative, ranks. With reverse the ranks count from
+// the end, and the result is in reverse order.
+func (ss *sortedSet) rangeByRank(start, end int, reverse bool) []ssElem {
+	var res []ssElem
+	if start > end {
+		return res
+	}
+	n := ss.zsl.length
+	if reverse {
+		x := ss.zsl.byRank(n - start)
+		for i := start; i <= end && x != nil; i, x = i+1, x.backward {
+			res = append(res, ssElem{x.member, x.score})
+		}
+		return res
+	}
+	x := ss.zsl.byRank(start + 1)
+	for i := start; i <= end && x != nil; i, x = i+1, x.level[0].forward {
+		res = append(res, ssElem{x.member, x.score})
+	}
+	return res
+}
+
+// zrangeBound is either a score or a lex range bound.
+type zrangeBound interface {
+	gteMin(*zslNode) bool // the node is not below this as the min bound
+	lteMax(*zslNode) bool // the node is not above this as the max bound
+}
+
+// scoreBound is a ZRANGEBYSCORE style bound: "1.5", "(1.5", "-inf", "+inf"
+type scoreBound struct {
+	v    float64
+	excl bool
+}
+
+func (b scoreBound) gteMin(n *zslNode) bool {
+	if b.excl {
+		return n.score > b.v
+	}
+	return n.score >= b.v
+}
+
+func (b scoreBound) lteMax(n *zslNode) bool {
+	if b.excl {
+		return n.score < b.v
+	}
+	return n.score <= b.v
+}
+
+// lexBound is a ZRANGEBYLEX style bound: "[a", "(a", "-", "+"
+type lexBound struct {
+	v    string
+	excl bool
+	inf  int // -1 for "-", 1 for "+"
+}
+
+func (b lexBound) gteMin(n *zslNode) bool {
+	switch {
+	case b.inf < 0:
+		return true
+	case b.inf > 0:
+		return false
+	case b.excl:
+		return n.member > b.v
+	default:
+		return n.member >= b.v
+	}
+}
+
+func (b lexBound) lteMax(n *zslNode) bool {
+	switch {
+	case b.inf > 0:
+		return true
+	case b.inf < 0:
+		return false
+	case b.excl:
+		return n.member < b.v
+	default:
+		return n.member <= b.v
+	}
+}
+
+// rangeByBounds gives the elements between min and max. offset elements are
+// skipped, and at most count are returned, if count is not negative. With
+// reverse it walks from max to min.
+func (ss *sortedSet) rangeByBounds(min, max zrangeBound, reverse bool, offset, count int) []ssElem {
+	var res []ssElem
+	if offset < 0 {
+		return res
+	}
+	var (
+		x    *zslNode
+		next func(*zslNode) *zslNode
+		in   func(*zslNode) bool
+	)
+	if reverse {
+		x = ss.zsl.last(max.lteMax)
+		next = func(n *zslNode) *zslNode { return n.backward }
+		in = min.gteMin
+	} else {
+		x = ss.zsl.first(func(n *zslNode) bool { return !min.gteMin(n) })
+		next = func(n *zslNode) *zslNode { return n.level[0].forward }
+		in = max.lteMax
+	}
+	for ; x != nil && in(x); x = next(x) {
+		if offset > 0 {
+			offset--
+			continue
+		}
+		if count >= 0 && len(res) >= count {
+			break
+		}
+		res = append(res, ssElem{x.member, x.score})
+	}
+	return res
+}
+
+// countByBounds counts the elements between min and max.
+func (ss *sortedSet) countByBounds(min, max zrangeBound) int {
+	first := ss.zsl.first(func(n *zslNode) bool { return !min.gteMin(n) })
+	if first == nil || !max.lteMax(first) {
+		return 0
+	}
+	last := ss.zsl.last(max.lteMax)
+	if last == nil {
+		return 0
+	}
+	return ss.zsl.rank(last.score, last.member) - ss.zsl.rank(first.score, first.member) + 1
+}
@@ -0,0 +1,76 @@
+package datastructure
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestSortedSet(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	ss := newSortedSet()
+	ref := map[string]float64{}
+	for i := 0; i < 5000; i++ {
+		member := strconv.Itoa(r.Intn(300))
+		if r.Intn(3) == 0 {
+			ss.remove(member)
+			delete(ref, member)
+			continue
+		}
+		score := float64(r.Intn(50))
+		ss.set(score, member)
+		ref[member] = score
+	}
+
+	var want []ssElem
+	for member, score := range ref {
+		want = append(want, ssElem{member, score})
+	}
+	sort.Slice(want, func(i, j int) bool {
+		if want[i].score != want[j].score {
+			return want[i].score < want[j].score
+		}
+		return want[i].member < want[j].member
+	})
+
+	if got := ss.elems(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("elems: got %v, want %v", got, want)
+	}
+	if ss.card() != len(want) {
+		t.Errorf("card: got %d, want %d", ss.card(), len(want))
+	}
+	for i, e := range want {
+		if r, ok := ss.rank(e.member, false); !ok || r != i {
+			t.Fatalf("rank of %q: got %d, want %d", e.member, r, i)
+		}
+		if r, _ := ss.rank(e.member, true); r != len(want)-1-i {
+			t.Fatalf("reverse rank of %q: got %d", e.member, r)
+		}
+		if n := ss.zsl.byRank(i + 1); n.member != e.member {
+			t.Fatalf("byRank(%d): got %q, want %q", i+1, n.member, e.member)
+		}
+	}
+
+	if got := ss.rangeByRank(3, 10, false); !reflect.DeepEqual(got, want[3:11]) {
+		t.Errorf("rangeByRank: got %v", got)
+	}
+	var rev []ssElem
+	for i := len(want) - 1; i >= 0; i-- {
+		rev = append(rev, want[i])
+	}
+	if got := ss.rangeByRank(3, 10, true); !reflect.DeepEqual(got, rev[3:11]) {
+		t.Errorf("reverse rangeByRank: got %v", got)
+	}
+
+	n := 0
+	for _, e := range want {
+		if e.score >= 10 && e.score < 20 {
+			n++
+		}
+	}
+	if got := ss.countByBounds(scoreBound{v: 10}, scoreBound{v: 20, excl: true}); got != n {
+		t.Errorf("countByBounds: got %d, want %d", got, n)
+	}
+}
@@ -0,0 +1,1173 @@
+package datastructure
+
+import (
+	"fmt"
+	"math"
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+)
+
+// commandsSortedSet handles all sorted set operations.
+func commandsSortedSet(m *ShinyRedis) {
+	m.srv.Register("BZPOPMAX", m.cmdBzpopmax, -3, "write noscript blocking fast", 1, -2, 1)
+	m.srv.Register("BZPOPMIN", m.cmdBzpopmin, -3, "write noscript blocking fast", 1, -2, 1)
+	m.srv.Register("ZADD", m.cmdZadd, -4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("ZCARD", m.cmdZcard, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZCOUNT", m.cmdZcount, 4, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZINCRBY", m.cmdZincrby, 4, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("ZINTERSTORE", m.cmdZinterstore, -4, "write denyoom movablekeys", 1, 1, 1)
+	m.srv.Register("ZLEXCOUNT", m.cmdZlexcount, 4, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZMSCORE", m.cmdZmscore, -3, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZPOPMAX", m.cmdZpopmax, -2, "write fast", 1, 1, 1)
+	m.srv.Register("ZPOPMIN", m.cmdZpopmin, -2, "write fast", 1, 1, 1)
+	m.srv.Register("ZRANGE", m.cmdZrange, -4, "readonly", 1, 1, 1)
+	m.srv.Register("ZRANGEBYLEX", m.cmdZrangebylex, -4, "readonly", 1, 1, 1)
+	m.srv.Register("ZRANGEBYSCORE", m.cmdZrangebyscore, -4, "readonly", 1, 1, 1)
+	m.srv.Register("ZRANGESTORE", m.cmdZrangestore, -5, "write denyoom", 1, 2, 1)
+	m.srv.Register("ZRANK", m.cmdZrank, -3, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZREM", m.cmdZrem, -3, "write fast", 1, 1, 1)
+	m.srv.Register("ZREMRANGEBYLEX", m.cmdZremrangebylex, 4, "write", 1, 1, 1)
+	m.srv.Register("ZREMRANGEBYRANK", m.cmdZremrangebyrank, 4, "write", 1, 1, 1)
+	m.srv.Register("ZREMRANGEBYSCORE", m.cmdZremrangebyscore, 4, "write", 1, 1, 1)
+	m.srv.Register("ZREVRANGE", m.cmdZrevrange, -4, "readonly", 1, 1, 1)
+	m.srv.Register("ZREVRANGEBYLEX", m.cmdZrevrangebylex, -4, "readonly", 1, 1, 1)
+	m.srv.Register("ZREVRANGEBYSCORE", m.cmdZrevrangebyscore, -4, "readonly", 1, 1, 1)
+	m.srv.Register("ZREVRANK", m.cmdZrevrank, -3, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZSCORE", m.cmdZscore, 3, "readonly fast", 1, 1, 1)
+	m.srv.Register("ZUNIONSTORE", m.cmdZunionstore, -4, "write denyoom movablekeys", 1, 1, 1)
+}
+
+// ZADD
+func (m *ShinyRedis) cmdZadd(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key                      = args[0]
+		nx, xx, gt, lt, ch, incr bool
+	)
+	args = args[1:]
+outer:
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GT":
+			gt = true
+		case "LT":
+			lt = true
+		case "CH":
+			ch = true
+		case "INCR":
+			incr = true
+		default:
+			break outer
+		}
+		args = args[1:]
+	}
+	if nx && xx {
+		setDirty(c)
+		c.WriteError(msgXXandNX)
+		return
+	}
+	if (gt && lt) || (gt && nx) || (lt && nx) {
+		setDirty(c)
+		c.WriteError(msgGTLTandNX)
+		return
+	}
+	if len(args) == 0 || len(args)%2 != 0 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	if incr && len(args) > 2 {
+		setDirty(c)
+		c.WriteError(msgSingleElementPair)
+		return
+	}
+	var elems []ssElem
+	for i := 0; i < len(args); i += 2 {
+		score, err := parseScore(args[i])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidFloat)
+			return
+		}
+		elems = append(elems, ssElem{member: args[i+1], score: score})
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		if incr {
+			e := elems[0]
+			old, ok := db.ssetScore(key, e.member)
+			if (nx && ok) || (xx && !ok) {
+				c.WriteNull()
+				return
+			}
+			score := old + e.score
+			if math.IsNaN(score) {
+				c.WriteError(msgScoreNaN)
+				return
+			}
+			if ok && ((gt && score <= old) || (lt && score >= old)) {
+				c.WriteNull()
+				return
+			}
+			db.ssetAdd(key, score, e.member)
+			c.WriteFloat(score)
+			return
+		}
+
+		res := 0
+		for _, e := range elems {
+			old, ok := db.ssetScore(key, e.member)
+			if (nx && ok) || (xx && !ok) {
+				continue
+			}
+			if ok && ((gt && e.score <= old) || (lt && e.score >= old)) {
+				continue
+			}
+			if db.ssetAdd(key, e.score, e.member) {
+				res++
+			} else if ch && old != e.score {
+				res++
+			}
+		}
+		c.WriteInt(res)
+	})
+}
+
+// ZCARD
+func (m *ShinyRedis) cmdZcard(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(db.ssetCard(key))
+	})
+}
+
+// ZCOUNT
+func (m *ShinyRedis) cmdZcount(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	min, err := parseScoreBound(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidMinMax)
+		return
+	}
+	max, err := parseScoreBound(args[2])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidMinMax)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(db.sortedsetKeys[key].countByBounds(min, max))
+	})
+}
+
+// ZLEXCOUNT
+func (m *ShinyRedis) cmdZlexcount(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	min, ok := parseLexBound(args[1])
+	if !ok {
+		setDirty(c)
+		c.WriteError(msgInvalidRangeItem)
+		return
+	}
+	max, ok := parseLexBound(args[2])
+	if !ok {
+		setDirty(c)
+		c.WriteError(msgInvalidRangeItem)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(db.sortedsetKeys[key].countByBounds(min, max))
+	})
+}
+
+// ZINCRBY
+func (m *ShinyRedis) cmdZincrby(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, member := args[0], args[2]
+	delta, err := parseScore(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidFloat)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		old, _ := db.ssetScore(key, member)
+		score := old + delta
+		if math.IsNaN(score) {
+			c.WriteError(msgScoreNaN)
+			return
+		}
+		db.ssetAdd(key, score, member)
+		c.WriteFloat(score)
+	})
+}
+
+// ZSCORE
+func (m *ShinyRedis) cmdZscore(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, member := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		score, ok := db.ssetScore(key, member)
+		if !ok {
+			c.WriteNull()
+			return
+		}
+		c.WriteFloat(score)
+	})
+}
+
+// ZMSCORE
+func (m *ShinyRedis) cmdZmscore(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, members := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(members))
+			for _, member := range members {
+				score, ok := db.ssetScore(key, member)
+				if !ok {
+					w.WriteNull()
+					continue
+				}
+				w.WriteFloat(score)
+			}
+		})
+	})
+}
+
+// ZRANK
+func (m *ShinyRedis) cmdZrank(c *server.Peer, cmd string, args []string) {
+	m.cmdZrankGeneric(c, cmd, args, false)
+}
+
+// ZREVRANK
+func (m *ShinyRedis) cmdZrevrank(c *server.Peer, cmd string, args []string) {
+	m.cmdZrankGeneric(c, cmd, args, true)
+}
+
+func (m *ShinyRedis) cmdZrankGeneric(c *server.Peer, cmd string, args []string, reverse bool) {
+	if len(args) < 2 || len(args) > 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, member := args[0], args[1]
+	withScore := false
+	if len(args) == 3 {
+		if strings.ToUpper(args[2]) != "WITHSCORE" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		withScore = true
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var (
+			rank int
+			ok   bool
+		)
+		if ss, found := db.sortedsetKeys[key]; found {
+			rank, ok = ss.rank(member, reverse)
+		}
+		if !ok {
+			if withScore {
+				c.WriteNullArray()
+				return
+			}
+			c.WriteNull()
+			return
+		}
+		if !withScore {
+			c.WriteInt(rank)
+			return
+		}
+		score, _ := db.ssetScore(key, member)
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(2)
+			w.WriteInt(rank)
+			w.WriteFloat(score)
+		})
+	})
+}
+
+// ZREM
+func (m *ShinyRedis) cmdZrem(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, members := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(db.ssetRem(key, members...))
+	})
+}
+
+// zrangeOpts are the parsed arguments of ZRANGE and friends.
+type zrangeOpts struct {
+	by         string // "", "BYSCORE", or "BYLEX"
+	rev        bool
+	withLimit  bool
+	offset     int
+	count      int
+	withScores bool
+	start, end int         // by rank
+	min, max   zrangeBound // BYSCORE and BYLEX
+}
+
+// parseZrange parses the range and the options of ZRANGE, ZRANGESTORE, and
+// the older ZRANGEBYSCORE style commands. opts has the defaults for the
+// command, allowBy is only set for ZRANGE and ZRANGESTORE, which take
+// BYSCORE, BYLEX, and REV. store disallows WITHSCORES. Returns an error
+// message on invalid input.
+func parseZrange(opts zrangeOpts, allowBy, store bool, start, stop string, args []string) (zrangeOpts, string) {
+	for len(args) > 0 {
+		switch opt := strings.ToUpper(args[0]); {
+		case opt == "WITHSCORES" && !store:
+			opts.withScores = true
+			args = args[1:]
+		case opt == "LIMIT":
+			if len(args) < 3 {
+				return opts, msgSyntaxError
+			}
+			offset, err := strconv.Atoi(args[1])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			count, err := strconv.Atoi(args[2])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			opts.withLimit, opts.offset, opts.count = true, offset, count
+			args = args[3:]
+		case (opt == "BYSCORE" || opt == "BYLEX") && allowBy:
+			opts.by = opt
+			args = args[1:]
+		case opt == "REV" && allowBy:
+			opts.rev = true
+			args = args[1:]
+		default:
+			return opts, msgSyntaxError
+		}
+	}
+	if opts.withLimit && opts.by == "" {
+		return opts, msgLimitCombination
+	}
+	if opts.withScores && opts.by == "BYLEX" {
+		return opts, "ERR syntax error, WITHSCORES not supported in combination with BYLEX"
+	}
+
+	min, max := start, stop
+	if opts.rev && opts.by != "" {
+		// the range is given as max, min
+		min, max = stop, start
+	}
+	switch opts.by {
+	case "BYSCORE":
+		smin, err := parseScoreBound(min)
+		if err != nil {
+			return opts, msgInvalidMinMax
+		}
+		smax, err := parseScoreBound(max)
+		if err != nil {
+			return opts, msgInvalidMinMax
+		}
+		opts.min, opts.max = smin, smax
+	case "BYLEX":
+		lmin, ok := parseLexBound(min)
+		if !ok {
+			return opts, msgInvalidRangeItem
+		}
+		lmax, ok := parseLexBound(max)
+		if !ok {
+			return opts, msgInvalidRangeItem
+		}
+		opts.min, opts.max = lmin, lmax
+	default:
+		s, err := strconv.Atoi(start)
+		if err != nil {
+			return opts, msgInvalidInt
+		}
+		e, err := strconv.Atoi(stop)
+		if err != nil {
+			return opts, msgInvalidInt
+		}
+		opts.start, opts.end = s, e
+	}
+	return opts, ""
+}
+
+// zrange gives the elements selected by a parsed ZRANGE.
+func (ss *sortedSet) zrange(opts zrangeOpts) []ssElem {
+	if opts.by == "" {
+		rs, re := redisRange(ss.card(), opts.start, opts.end, false)
+		return ss.rangeByRank(rs, re-1, opts.rev)
+	}
+	offset, count := 0, -1
+	if opts.withLimit {
+		offset, count = opts.offset, opts.count
+	}
+	return ss.rangeByBounds(opts.min, opts.max, opts.rev, offset, count)
+}
+
+// ZRANGE
+func (m *ShinyRedis) cmdZrange(c *server.Peer, cmd string, args []string) {
+	m.cmdZrangeGeneric(c, cmd, args, zrangeOpts{}, true)
+}
+
+// ZREVRANGE
+func (m *ShinyRedis) cmdZrevrange(c *server.Peer, cmd string, args []string) {
+	m.cmdZrangeGeneric(c, cmd, args, zrangeOpts{rev: true}, false)
+}
+
+// ZRANGEBYSCORE
+func (m *ShinyRedis) cmdZrangebyscore(c *server.Peer, cmd string, args []string) {
+	m.cmdZrangeGeneric(c, cmd, args, zrangeOpts{by: "BYSCORE"}, false)
+}
+
+// ZREVRANGEBYSCORE
+func (m *ShinyRedis) cmdZrevrangebyscore(c *server.Peer, cmd string, args []string) {
+	m.cmdZrangeGeneric(c, cmd, args, zrangeOpts{by: "BYSCORE", rev: true}, false)
+}
+
+// ZRANGEBYLEX
+func (m *ShinyRedis) cmdZrangebylex(c *server.Peer, cmd string, args []string) {
+	m.cmdZrangeGeneric(c, cmd, args, zrangeOpts{by: "BYLEX"}, false)
+}
+
+// ZREVRANGEBYLEX
+func (m *ShinyRedis) cmdZrevrangebylex(c *server.Peer, cmd string, args []string) {
+	m.cmdZrangeGeneric(c, cmd, args, zrangeOpts{by: "BYLEX", rev: true}, false)
+}
+
+func (m *ShinyRedis) cmdZrangeGeneric(c *server.Peer, cmd string, args []string, opts zrangeOpts, allowBy bool) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseZrange(opts, allowBy, false, args[1], args[2], args[3:])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteLen(0)
+			return
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		elems := db.sortedsetKeys[key].zrange(opts)
+		c.Block(func(w *server.Writer) {
+			writeScored(w, elems, opts.withScores)
+		})
+	})
+}
+
+// ZRANGESTORE
+func (m *ShinyRedis) cmdZrangestore(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	dst, src := args[0], args[1]
+	opts, msg := parseZrange(zrangeOpts{}, true, true, args[2], args[3], args[4:])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(src) && db.t(src) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		res := newSortedSet()
+		if ss, ok := db.sortedsetKeys[src]; ok {
+			for _, e := range ss.zrange(opts) {
+				res.set(e.score, e.member)
+			}
+		}
+		db.del(dst, true)
+		db.ssetSet(dst, res)
+		c.WriteInt(res.card())
+	})
+}
+
+// ZREMRANGEBYRANK
+func (m *ShinyRedis) cmdZremrangebyrank(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseZrange(zrangeOpts{}, false, true, args[1], args[2], nil)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+	m.zremrange(c, key, opts)
+}
+
+// ZREMRANGEBYSCORE
+func (m *ShinyRedis) cmdZremrangebyscore(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseZrange(zrangeOpts{by: "BYSCORE"}, false, true, args[1], args[2], nil)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+	m.zremrange(c, key, opts)
+}
+
+// ZREMRANGEBYLEX
+func (m *ShinyRedis) cmdZremrangebylex(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	opts, msg := parseZrange(zrangeOpts{by: "BYLEX"}, false, true, args[1], args[2], nil)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+	m.zremrange(c, key, opts)
+}
+
+// zremrange removes the elements selected by a parsed range.
+func (m *ShinyRedis) zremrange(c *server.Peer, key string, opts zrangeOpts) {
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		var members []string
+		for _, e := range db.sortedsetKeys[key].zrange(opts) {
+			members = append(members, e.member)
+		}
+		c.WriteInt(db.ssetRem(key, members...))
+	})
+}
+
+// ZPOPMIN
+func (m *ShinyRedis) cmdZpopmin(c *server.Peer, cmd string, args []string) {
+	m.cmdZpop(c, cmd, args, false)
+}
+
+// ZPOPMAX
+func (m *ShinyRedis) cmdZpopmax(c *server.Peer, cmd string, args []string) {
+	m.cmdZpop(c, cmd, args, true)
+}
+
+func (m *ShinyRedis) cmdZpop(c *server.Peer, cmd string, args []string, reverse bool) {
+	if len(args) < 1 || len(args) > 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	withCount := len(args) == 2
+	count := 1
+	if withCount {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			setDirty(c)
+			c.WriteError(msgNotPositive)
+			return
+		}
+		count = n
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteLen(0)
+			return
+		}
+		if db.t(key) != "zset" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		ss := db.sortedsetKeys[key]
+		if count > ss.card() {
+			count = ss.card()
+		}
+		elems := ss.rangeByRank(0, count-1, reverse)
+		for _, e := range elems {
+			db.ssetRem(key, e.member)
+		}
+		c.Block(func(w *server.Writer) {
+			if withCount {
+				writeScored(w, elems, true)
+				return
+			}
+			// without a count it's a flat [member, score], also in RESP3
+			w.WriteLen(len(elems) * 2)
+			for _, e := range elems {
+				w.WriteBulk(e.member)
+				w.WriteFloat(e.score)
+			}
+		})
+	})
+}
+
+// BZPOPMIN
+func (m *ShinyRedis) cmdBzpopmin(c *server.Peer, cmd string, args []string) {
+	m.cmdBzpop(c, cmd, args, false)
+}
+
+// BZPOPMAX
+func (m *ShinyRedis) cmdBzpopmax(c *server.Peer, cmd string, args []string) {
+	m.cmdBzpop(c, cmd, args, true)
+}
+
+func (m *ShinyRedis) cmdBzpop(c *server.Peer, cmd string, args []string, reverse bool) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	timeoutS := args[len(args)-1]
+	keys := args[:len(args)-1]
+
+	timeout, msg := parseBlockTimeout(timeoutS)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	blocking(
+		m,
+		c,
+		keys,
+		timeout,
+		func(c *server.Peer, ctx *connCtx) bool {
+			db := m.db(ctx.selectedDB)
+			for _, key := range keys {
+				if !db.exists(key) {
+					continue
+				}
+				if db.t(key) != "zset" {
+					c.WriteError(msgWrongType)
+					return true
+				}
+
+				e := db.sortedsetKeys[key].rangeByRank(0, 0, reverse)[0]
+				db.ssetRem(key, e.member)
+				c.Block(func(w *server.Writer) {
+					w.WriteLen(3)
+					w.WriteBulk(key)
+					w.WriteBulk(e.member)
+					w.WriteFloat(e.score)
+				})
+				return true
+			}
+			return false
+		},
+		func(c *server.Peer) {
+			// timeout
+			c.WriteNullArray()
+		},
+	)
+}
+
+// ZUNIONSTORE
+func (m *ShinyRedis) cmdZunionstore(c *server.Peer, cmd string, args []string) {
+	m.cmdZxstore(c, cmd, args, true)
+}
+
+// ZINTERSTORE
+func (m *ShinyRedis) cmdZinterstore(c *server.Peer, cmd string, args []string) {
+	m.cmdZxstore(c, cmd, args, false)
+}
+
+func (m *ShinyRedis) cmdZxstore(c *server.Peer, cmd string, args []string, union bool) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	dst := args[0]
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if numKeys < 1 {
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR at least 1 input key is needed for '%s' command", strings.ToLower(cmd)))
+		return
+	}
+	args = args[2:]
+	if len(args) < numKeys {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	keys, args := args[:numKeys], args[numKeys:]
+
+	var (
+		weights   []float64
+		aggregate = "SUM"
+	)
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "WEIGHTS":
+			if len(args) < numKeys+1 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			weights = nil
+			for i := 0; i < numKeys; i++ {
+				w, err := parseScore(args[i+1])
+				if err != nil {
+					setDirty(c)
+					c.WriteError("ERR weight value is not a float")
+					return
+				}
+				weights = append(weights, w)
+			}
+			args = args[numKeys+1:]
+		case "AGGREGATE":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			aggregate = strings.ToUpper(args[1])
+			switch aggregate {
+			case "SUM", "MIN", "MAX":
+			default:
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		for _, key := range keys {
+			if !db.exists(key) {
+				continue
+			}
+			if t := db.t(key); t != "zset" && t != "set" {
+				c.WriteError(msgWrongType)
+				return
+			}
+		}
+
+		var res map[string]float64
+		for i, key := range keys {
+			weight := 1.0
+			if weights != nil {
+				weight = weights[i]
+			}
+			scores := map[string]float64{}
+			for _, e := range db.ssetOrSetElems(key) {
+				v := e.score * weight
+				if math.IsNaN(v) {
+					v = 0
+				}
+				scores[e.member] = v
+			}
+
+			if res == nil {
+				res = scores
+				continue
+			}
+			if union {
+				for member, v := range scores {
+					if old, ok := res[member]; ok {
+						v = zaggregate(aggregate, old, v)
+					}
+					res[member] = v
+				}
+				continue
+			}
+			for member, old := range res {
+				v, ok := scores[member]
+				if !ok {
+					delete(res, member)
+					continue
+				}
+				res[member] = zaggregate(aggregate, old, v)
+			}
+		}
+
+		ss := newSortedSet()
+		for member, score := range res {
+			ss.set(score, member)
+		}
+		db.del(dst, true)
+		db.ssetSet(dst, ss)
+		c.WriteInt(ss.card())
+	})
+}
+
+// zaggregate combines two scores for ZUNIONSTORE and ZINTERSTORE.
+func zaggregate(aggregate string, a, b float64) float64 {
+	switch aggregate {
+	case "MIN":
+		return math.Min(a, b)
+	case "MAX":
+		return math.Max(a, b)
+	default:
+		if v := a + b; !math.IsNaN(v) {
+			return v
+		}
+		// inf + -inf
+		return 0
+	}
+}
+
+// writeScored writes sorted set elements, optionally with their scores. In
+// RESP3 each element with its score is a [member, score] pair.
+func writeScored(w *server.Writer, elems []ssElem, withScores bool) {
+	if !withScores {
+		w.WriteLen(len(elems))
+		for _, e := range elems {
+			w.WriteBulk(e.member)
+		}
+		return
+	}
+	if w.Resp3() {
+		w.WriteLen(len(elems))
+		for _, e := range elems {
+			w.WriteLen(2)
+			w.WriteBulk(e.member)
+			w.WriteFloat(e.score)
+		}
+		return
+	}
+	w.WriteLen(len(elems) * 2)
+	for _, e := range elems {
+		w.WriteBulk(e.member)
+		w.WriteFloat(e.score)
+	}
+}
+
+// parseScore parses a sorted set score. "inf" and "-inf" are fine, NaN is
+// not.
+func parseScore(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) {
+		return 0, errFloatValue
+	}
+	return v, nil
+}
+
+// parseScoreBound parses a ZRANGEBYSCORE style bound: "1.5", "(1.5", "-inf",
+// "+inf".
+func parseScoreBound(s string) (scoreBound, error) {
+	excl := strings.HasPrefix(s, "(")
+	if excl {
+		s = s[1:]
+	}
+	v, err := parseScore(s)
+	if err != nil {
+		return scoreBound{}, err
+	}
+	return scoreBound{v: v, excl: excl}, nil
+}
+
+// parseLexBound parses a ZRANGEBYLEX style bound: "[a", "(a", "-", "+".
+func parseLexBound(s string) (lexBound, bool) {
+	switch {
+	case s == "-":
+		return lexBound{inf: -1}, true
+	case s == "+":
+		return lexBound{inf: 1}, true
+	case strings.HasPrefix(s, "["):
+		return lexBound{v: s[1:]}, true
+	case strings.HasPrefix(s, "("):
+		return lexBound{v: s[1:], excl: true}, true
+	default:
+		return lexBound{}, false
+	}
+}
@@ -0,0 +1,120 @@
+package datastructure
+
+import "testing"
+
+func TestZadd(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "ZADD", "z", "1", "a", "2", "b", "3", "c")
+	c.must(":0", "ZADD", "z", "1", "a")
+	c.must(":1", "ZADD", "z", "CH", "5", "a")
+	c.must(":0", "ZADD", "z", "GT", "4", "a")
+	c.must(":1", "ZADD", "z", "LT", "CH", "0.5", "a")
+	c.must(`$"1.5"`, "ZADD", "z", "INCR", "1", "a")
+	c.must(`nil`, "ZADD", "z", "NX", "INCR", "1", "a")
+	c.must(":0", "ZADD", "z", "XX", "1", "new")
+	c.must("-"+msgXXandNX, "ZADD", "z", "NX", "XX", "1", "a")
+	c.must("-"+msgGTLTandNX, "ZADD", "z", "NX", "GT", "1", "a")
+	c.must("-"+msgSingleElementPair, "ZADD", "z", "INCR", "1", "a", "2", "b")
+	c.must("-"+msgInvalidFloat, "ZADD", "z", "nan", "a")
+	c.must("-"+msgSyntaxError, "ZADD", "z", "1", "a", "2")
+	c.must(`:3`, "ZCARD", "z")
+	c.must(`:0`, "ZCARD", "nosuch")
+	c.must(`$"5"`, "ZINCRBY", "z", "2", "c")
+	c.must(`$"5"`, "ZSCORE", "z", "c")
+	c.must(`nil`, "ZSCORE", "z", "x")
+	c.must(`*[$"5" nil]`, "ZMSCORE", "z", "c", "x")
+	c.must(`:1`, "ZREM", "z", "c", "x")
+	c.must(`:2`, "ZCARD", "z")
+
+	c.must(":1", "SADD", "s", "a")
+	c.must("-"+msgWrongType, "ZADD", "s", "1", "a")
+}
+
+func TestZscoreFormat(t *testing.T) {
+	_, c := start(t)
+	c.must(":4", "ZADD", "z", "1e300", "a", "0.00001", "b", "1000000", "c", "-inf", "d")
+	c.must(`$"1e+300"`, "ZSCORE", "z", "a")
+	c.must(`$"1e-05"`, "ZSCORE", "z", "b")
+	c.must(`$"1000000"`, "ZSCORE", "z", "c")
+	c.must(`$"-inf"`, "ZSCORE", "z", "d")
+	c.must(`$"2e+300"`, "ZINCRBY", "z", "1e300", "a")
+	c.must(`*[$"d" $"-inf" $"b" $"1e-05" $"c" $"1000000" $"a" $"2e+300"]`, "ZRANGE", "z", "0", "-1", "WITHSCORES")
+	c.do("HELLO", "3")
+	c.must(`,2e+300`, "ZSCORE", "z", "a")
+}
+
+func TestZrange(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "ZADD", "z", "1.5", "a", "2", "b", "3", "c")
+	c.must(`*[$"a" $"b" $"c"]`, "ZRANGE", "z", "0", "-1")
+	c.must(`*[$"c" $"b"]`, "ZRANGE", "z", "0", "1", "REV")
+	c.must(`*[$"a" $"1.5" $"b" $"2"]`, "ZRANGE", "z", "0", "1", "WITHSCORES")
+	c.must(`*[$"b" $"c"]`, "ZRANGE", "z", "(1.5", "+inf", "BYSCORE")
+	c.must(`*[$"c" $"b"]`, "ZRANGE", "z", "+inf", "(1.5", "BYSCORE", "REV")
+	c.must(`*[$"b"]`, "ZRANGE", "z", "-inf", "+inf", "BYSCORE", "LIMIT", "1", "1")
+	c.must("-"+msgLimitCombination, "ZRANGE", "z", "0", "1", "LIMIT", "0", "1")
+	c.must(`*[$"c" $"b" $"a"]`, "ZREVRANGE", "z", "0", "-1")
+	c.must(`*[$"a" $"b"]`, "ZRANGEBYSCORE", "z", "-inf", "2")
+	c.must(`*[$"b" $"a"]`, "ZREVRANGEBYSCORE", "z", "2", "-inf")
+	c.must(`-ERR min or max is not a float`, "ZRANGEBYSCORE", "z", "x", "2")
+	c.must(`*[]`, "ZRANGE", "nosuch", "0", "-1")
+	c.must(`:2`, "ZCOUNT", "z", "1", "2")
+	c.must(`:1`, "ZRANK", "z", "b")
+	c.must(`:1`, "ZREVRANK", "z", "b")
+	c.must(`*[:2 $"3"]`, "ZRANK", "z", "c", "WITHSCORE")
+	c.must(`nil`, "ZRANK", "z", "x")
+	c.must(`nilarr`, "ZRANK", "z", "x", "WITHSCORE")
+
+	c.must(":4", "ZADD", "l", "0", "a", "0", "b", "0", "c", "0", "d")
+	c.must(`*[$"b" $"c"]`, "ZRANGEBYLEX", "l", "(a", "[c")
+	c.must(`*[$"d" $"c" $"b" $"a"]`, "ZREVRANGEBYLEX", "l", "+", "-")
+	c.must(`*[$"c" $"b"]`, "ZRANGE", "l", "[c", "(a", "BYLEX", "REV")
+	c.must(`:3`, "ZLEXCOUNT", "l", "-", "(d")
+	c.must(`-ERR min or max not valid string range item`, "ZLEXCOUNT", "l", "a", "b")
+	c.must(`:2`, "ZRANGESTORE", "dst", "l", "0", "1")
+	c.must(`*[$"a" $"b"]`, "ZRANGE", "dst", "0", "-1")
+
+	c.do("HELLO", "3")
+	c.must(`*[*[$"a" ,1.5] *[$"b" ,2]]`, "ZRANGE", "z", "0", "1", "WITHSCORES")
+}
+
+func TestZpop(t *testing.T) {
+	m, c := start(t)
+	c.must(":4", "ZADD", "l", "0", "a", "0", "b", "0", "c", "0", "d")
+	c.must(`*[$"a" $"0"]`, "ZPOPMIN", "l")
+	c.must(`*[$"d" $"0" $"c" $"0"]`, "ZPOPMAX", "l", "2")
+	c.must(`*[$"b" $"0"]`, "ZPOPMAX", "l", "5")
+	c.must(`:0`, "EXISTS", "l")
+	c.must(`*[]`, "ZPOPMIN", "nosuch")
+
+	c.must(":2", "ZADD", "z", "1", "a", "2", "b")
+	c.must(`*[$"z" $"a" $"1"]`, "BZPOPMIN", "nosuch", "z", "0")
+	c.must(`*[$"z" $"b" $"2"]`, "BZPOPMAX", "z", "0")
+	c.must(`nilarr`, "BZPOPMAX", "nosuch", "0.01")
+
+	c2 := dial(t, m)
+	c2.send("BZPOPMIN", "z", "0")
+	waitBlocked(t, m, 1)
+	c.must(":1", "ZADD", "z", "3", "c")
+	if got := c2.read(); got != `*[$"z" $"c" $"3"]` {
+		t.Errorf("BZPOPMIN: got %q", got)
+	}
+}
+
+func TestZstore(t *testing.T) {
+	_, c := start(t)
+	c.must(":3", "ZADD", "z", "1.5", "a", "2", "b", "5", "c")
+	c.must(":2", "ZADD", "dst", "0", "a", "0", "b")
+	c.must(`:3`, "ZUNIONSTORE", "u", "2", "z", "dst", "WEIGHTS", "1", "2")
+	c.must(`*[$"a" $"1.5" $"b" $"2" $"c" $"5"]`, "ZRANGE", "u", "0", "-1", "WITHSCORES")
+	c.must(`:2`, "ZINTERSTORE", "i", "2", "z", "dst", "AGGREGATE", "MAX")
+	c.must(`*[$"a" $"1.5" $"b" $"2"]`, "ZRANGE", "i", "0", "-1", "WITHSCORES")
+	c.must(`-ERR at least 1 input key is needed for 'zunionstore' command`, "ZUNIONSTORE", "u", "0", "z")
+	c.must(`:1`, "SADD", "s", "a")
+	c.must(`:3`, "ZUNIONSTORE", "u", "2", "z", "s")
+	c.must(`$"2.5"`, "ZSCORE", "u", "a")
+	c.must(`:1`, "ZREMRANGEBYRANK", "u", "0", "0")
+	c.must(`:2`, "ZREMRANGEBYSCORE", "u", "(2", "+inf")
+	c.must(`:0`, "ZCARD", "u")
+	c.must(`:0`, "EXISTS", "u")
+}
@@ -0,0 +1,1798 @@
+package datastructure
+
+import (
+	"fmt"
+	"math"
+	"shiny_redis/server"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandsStream handles all stream operations.
+func commandsStream(m *ShinyRedis) {
+	m.srv.Register("XACK", m.cmdXack, -4, "write fast", 1, 1, 1)
+	m.srv.Register("XADD", m.cmdXadd, -5, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("XAUTOCLAIM", m.cmdXautoclaim, -6, "write fast", 1, 1, 1)
+	m.srv.Register("XCLAIM", m.cmdXclaim, -6, "write fast", 1, 1, 1)
+	m.srv.Register("XDEL", m.cmdXdel, -3, "write fast", 1, 1, 1)
+	m.srv.Register("XGROUP", m.cmdXgroup, -2, "write denyoom", 2, 2, 1)
+	m.srv.Register("XINFO", m.cmdXinfo, -2, "readonly", 2, 2, 1)
+	m.srv.Register("XLEN", m.cmdXlen, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("XPENDING", m.cmdXpending, -3, "readonly", 1, 1, 1)
+	m.srv.Register("XRANGE", m.cmdXrange, -4, "readonly", 1, 1, 1)
+	m.srv.Register("XREAD", m.cmdXread, -4, "readonly blocking movablekeys", 0, 0, 0)
+	m.srv.Register("XREADGROUP", m.cmdXreadgroup, -7, "write blocking movablekeys", 0, 0, 0)
+	m.srv.Register("XREVRANGE", m.cmdXrevrange, -4, "readonly", 1, 1, 1)
+	m.srv.Register("XTRIM", m.cmdXtrim, -4, "write", 1, 1, 1)
+}
+
+// streamTrim is a parsed MAXLEN or MINID option of XADD and XTRIM.
+type streamTrim struct {
+	strategy string // "", "MAXLEN", or "MINID"
+	maxLen   int
+	minID    streamID
+	limit    int
+}
+
+// parseStreamTrim parses a MAXLEN or MINID option, if args starts with one.
+// Returns the remaining args, or an error message.
+func parseStreamTrim(args []string) (streamTrim, []string, string) {
+	var t streamTrim
+	if len(args) == 0 {
+		return t, args, ""
+	}
+	switch s := strings.ToUpper(args[0]); s {
+	case "MAXLEN", "MINID":
+		t.strategy = s
+	default:
+		return t, args, ""
+	}
+	args = args[1:]
+
+	approx := false
+	if len(args) > 0 && (args[0] == "=" || args[0] == "~") {
+		approx = args[0] == "~"
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return t, args, msgSyntaxError
+	}
+	if t.strategy == "MAXLEN" {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return t, args, msgInvalidInt
+		}
+		if n < 0 {
+			return t, args, "ERR The MAXLEN argument must be >= 0."
+		}
+		t.maxLen = n
+	} else {
+		id, err := parseStreamID(args[0], 0)
+		if err != nil {
+			return t, args, msgInvalidStreamID
+		}
+		t.minID = id
+	}
+	args = args[1:]
+
+	if len(args) > 0 && strings.ToUpper(args[0]) == "LIMIT" {
+		if len(args) < 2 {
+			return t, args, msgSyntaxError
+		}
+		if !approx {
+			return t, args, "ERR syntax error, LIMIT cannot be used without the special ~ option"
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return t, args, msgInvalidInt
+		}
+		if n < 0 {
+			return t, args, "ERR The LIMIT argument must be >= 0."
+		}
+		t.limit = n
+		args = args[2:]
+	}
+	return t, args, ""
+}
+
+// apply trims the stream. Approximate trimming ("~") is always done exactly.
+// Returns the number of removed entries.
+func (t streamTrim) apply(s *streamKey) int {
+	switch t.strategy {
+	case "MAXLEN":
+		return s.trimMaxLen(t.maxLen, t.limit)
+	case "MINID":
+		return s.trimMinID(t.minID, t.limit)
+	default:
+		return 0
+	}
+}
+
+// XADD
+func (m *ShinyRedis) cmdXadd(c *server.Peer, cmd string, args []string) {
+	if len(args) < 4 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key        = args[0]
+		nomkstream bool
+		trim       streamTrim
+		msg        string
+	)
+	args = args[1:]
+outer:
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "NOMKSTREAM":
+			nomkstream = true
+			args = args[1:]
+		case "MAXLEN", "MINID":
+			trim, args, msg = parseStreamTrim(args)
+			if msg != "" {
+				setDirty(c)
+				c.WriteError(msg)
+				return
+			}
+		default:
+			break outer
+		}
+	}
+	if len(args) < 3 || len(args)%2 != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	id, values := args[0], args[1:]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		s, ok := db.streamKeys[key]
+		if !ok && nomkstream {
+			c.WriteNull()
+			return
+		}
+		if !ok {
+			s = newStreamKey()
+		}
+		newID, err := s.generateID(id, m.effectiveNow())
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		if !ok {
+			db.streamCreate(key, s)
+		}
+		s.add(newID, values)
+		trim.apply(s)
+		db.keyVersion[key]++
+		c.WriteBulk(newID.String())
+	})
+}
+
+// XLEN
+func (m *ShinyRedis) cmdXlen(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(len(db.streamKeys[key].entries))
+	})
+}
+
+// XTRIM
+func (m *ShinyRedis) cmdXtrim(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	trim, rest, msg := parseStreamTrim(args[1:])
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+	if trim.strategy == "" || len(rest) > 0 {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		n := trim.apply(db.streamKeys[key])
+		if n > 0 {
+			db.keyVersion[key]++
+		}
+		c.WriteInt(n)
+	})
+}
+
+// XDEL
+func (m *ShinyRedis) cmdXdel(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	ids, err := parseStreamIDs(args[1:])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		n := db.streamKeys[key].delete(ids)
+		if n > 0 {
+			db.keyVersion[key]++
+		}
+		c.WriteInt(n)
+	})
+}
+
+// XRANGE
+func (m *ShinyRedis) cmdXrange(c *server.Peer, cmd string, args []string) {
+	m.cmdXrangeGeneric(c, cmd, args, false)
+}
+
+// XREVRANGE
+func (m *ShinyRedis) cmdXrevrange(c *server.Peer, cmd string, args []string) {
+	m.cmdXrangeGeneric(c, cmd, args, true)
+}
+
+func (m *ShinyRedis) cmdXrangeGeneric(c *server.Peer, cmd string, args []string, reverse bool) {
+	if len(args) != 3 && len(args) != 5 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, startS, endS := args[0], args[1], args[2]
+	if reverse {
+		startS, endS = endS, startS
+	}
+	start, err := parseStreamBound(startS, false)
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+	end, err := parseStreamBound(endS, true)
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+	count := -1
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "COUNT" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		n, err := strconv.Atoi(args[4])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		count = n
+		if count < 0 {
+			count = 0
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteLen(0)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		if count == 0 {
+			c.WriteLen(0)
+			return
+		}
+
+		entries := db.streamKeys[key].rangeIDs(start, end, count, reverse)
+		c.Block(func(w *server.Writer) {
+			writeStreamEntries(w, entries)
+		})
+	})
+}
+
+// streamRead is the reply for a single key of XREAD and XREADGROUP.
+type streamRead struct {
+	key     string
+	entries []streamEntry
+}
+
+// streamReadOpts are the parsed arguments of XREAD and XREADGROUP.
+type streamReadOpts struct {
+	count   int
+	block   bool
+	timeout time.Duration
+	noack   bool
+	keys    []string
+	ids     []string // "$", ">", or an ID
+}
+
+// parseStreamRead parses the options of XREAD and XREADGROUP, starting at
+// COUNT/BLOCK up to and including the STREAMS list.
+func parseStreamRead(cmd string, args []string, group bool) (streamReadOpts, string) {
+	var opts streamReadOpts
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "COUNT":
+			if len(args) < 2 {
+				return opts, msgSyntaxError
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				return opts, msgInvalidInt
+			}
+			if n < 0 {
+				n = 0
+			}
+			opts.count = n
+			args = args[2:]
+		case "BLOCK":
+			if len(args) < 2 {
+				return opts, msgSyntaxError
+			}
+			ms, err := strconv.Atoi(args[1])
+			if err != nil {
+				return opts, msgInvalidTimeout
+			}
+			if ms < 0 {
+				return opts, msgNegTimeout
+			}
+			opts.block = true
+			opts.timeout = time.Duration(ms) * time.Millisecond
+			args = args[2:]
+		case "NOACK":
+			if !group {
+				return opts, msgSyntaxError
+			}
+			opts.noack = true
+			args = args[1:]
+		case "STREAMS":
+			args = args[1:]
+			if len(args) == 0 || len(args)%2 != 0 {
+				special := "$"
+				if group {
+					special = ">"
+				}
+				return opts, fmt.Sprintf("ERR Unbalanced '%s' list of streams: for each stream key an ID or '%s' must be specified.", strings.ToLower(cmd), special)
+			}
+			opts.keys, opts.ids = args[:len(args)/2], args[len(args)/2:]
+			for _, id := range opts.ids {
+				switch {
+				case id == "$" && group:
+					return opts, "ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set."
+				case id == ">" && !group:
+					return opts, "ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option."
+				case id == "$" || id == ">":
+				default:
+					if _, err := parseStreamID(id, 0); err != nil {
+						return opts, msgInvalidStreamID
+					}
+				}
+			}
+			return opts, ""
+		default:
+			return opts, msgSyntaxError
+		}
+	}
+	return opts, msgSyntaxError
+}
+
+// XREAD
+func (m *ShinyRedis) cmdXread(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	opts, msg := parseStreamRead(cmd, args, false)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	// "$" is the last ID at the time of the first attempt
+	var (
+		ids      = make([]streamID, len(opts.ids))
+		resolved bool
+	)
+	read := func(c *server.Peer, ctx *connCtx) bool {
+		db := m.db(ctx.selectedDB)
+
+		for _, key := range opts.keys {
+			if db.exists(key) && db.t(key) != "stream" {
+				c.WriteError(msgWrongType)
+				return true
+			}
+		}
+		if !resolved {
+			for i, id := range opts.ids {
+				if id == "$" {
+					if s, ok := db.streamKeys[opts.keys[i]]; ok {
+						ids[i] = s.lastID
+					}
+					continue
+				}
+				ids[i], _ = parseStreamID(id, 0)
+			}
+			resolved = true
+		}
+
+		var res []streamRead
+		for i, key := range opts.keys {
+			s, ok := db.streamKeys[key]
+			if !ok {
+				continue
+			}
+			if entries := s.after(ids[i], opts.count); len(entries) > 0 {
+				res = append(res, streamRead{key: key, entries: entries})
+			}
+		}
+		if len(res) == 0 {
+			return false
+		}
+		c.Block(func(w *server.Writer) {
+			writeStreamReads(w, res)
+		})
+		return true
+	}
+
+	if !opts.block {
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			if !read(c, ctx) {
+				c.WriteNullArray()
+			}
+		})
+		return
+	}
+	blocking(
+		m,
+		c,
+		opts.keys,
+		opts.timeout,
+		read,
+		func(c *server.Peer) {
+			// timeout
+			c.WriteNullArray()
+		},
+	)
+}
+
+// XREADGROUP
+func (m *ShinyRedis) cmdXreadgroup(c *server.Peer, cmd string, args []string) {
+	if len(args) < 6 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	if strings.ToUpper(args[0]) != "GROUP" {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+	group, consumer := args[1], args[2]
+	opts, msg := parseStreamRead(cmd, args[3:], true)
+	if msg != "" {
+		setDirty(c)
+		c.WriteError(msg)
+		return
+	}
+
+	read := func(c *server.Peer, ctx *connCtx) bool {
+		db := m.db(ctx.selectedDB)
+		now := m.effectiveNow()
+
+		for _, key := range opts.keys {
+			if !db.exists(key) {
+				c.WriteError(fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group))
+				return true
+			}
+			if db.t(key) != "stream" {
+				c.WriteError(msgWrongType)
+				return true
+			}
+			if _, ok := db.streamKeys[key].groups[group]; !ok {
+				c.WriteError(fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group))
+				return true
+			}
+		}
+
+		var res []streamRead
+		for i, key := range opts.keys {
+			s := db.streamKeys[key]
+			g := s.groups[group]
+			cons, _ := g.consumer(consumer, now)
+			cons.seenTime = now
+
+			if opts.ids[i] != ">" {
+				// the history of this consumer, which doesn't block
+				id, _ := parseStreamID(opts.ids[i], 0)
+				var entries []streamEntry
+				for _, p := range g.consumerPending(consumer) {
+					if p.id.less(id) || p.id == id {
+						continue
+					}
+					if opts.count > 0 && len(entries) >= opts.count {
+						break
+					}
+					e, ok := s.get(p.id)
+					if !ok {
+						e = streamEntry{id: p.id}
+					}
+					entries = append(entries, e)
+					p.deliveryTime = now
+					p.deliveryCount++
+					g.setPending(p)
+				}
+				res = append(res, streamRead{key: key, entries: entries})
+				continue
+			}
+
+			entries := s.after(g.lastID, opts.count)
+			if len(entries) == 0 {
+				continue
+			}
+			g.lastID = entries[len(entries)-1].id
+			if !opts.noack {
+				for _, e := range entries {
+					g.setPending(streamPending{
+						id:            e.id,
+						consumer:      consumer,
+						deliveryTime:  now,
+						deliveryCount: 1,
+					})
+				}
+			}
+			cons.activeTime = now
+			db.keyVersion[key]++
+			res = append(res, streamRead{key: key, entries: entries})
+		}
+		if len(res) == 0 {
+			return false
+		}
+		c.Block(func(w *server.Writer) {
+			writeStreamReads(w, res)
+		})
+		return true
+	}
+
+	if !opts.block {
+		withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+			if !read(c, ctx) {
+				c.WriteNullArray()
+			}
+		})
+		return
+	}
+	blocking(
+		m,
+		c,
+		opts.keys,
+		opts.timeout,
+		read,
+		func(c *server.Peer) {
+			// timeout
+			c.WriteNullArray()
+		},
+	)
+}
+
+// XGROUP
+func (m *ShinyRedis) cmdXgroup(c *server.Peer, cmd string, args []string) {
+	if len(args) == 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	sub, args := strings.ToUpper(args[0]), args[1:]
+	switch sub {
+	case "CREATE":
+		m.xgroupCreate(c, args)
+	case "DESTROY":
+		m.xgroupDestroy(c, args)
+	case "SETID":
+		m.xgroupSetid(c, args)
+	case "CREATECONSUMER":
+		m.xgroupCreateconsumer(c, args)
+	case "DELCONSUMER":
+		m.xgroupDelconsumer(c, args)
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try XGROUP HELP.", sub))
+	}
+}
+
+// XGROUP CREATE
+func (m *ShinyRedis) xgroupCreate(c *server.Peer, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xgroup|create"))
+		return
+	}
+
+	key, group, idS := args[0], args[1], args[2]
+	mkstream := false
+	for _, opt := range args[3:] {
+		if strings.ToUpper(opt) != "MKSTREAM" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		mkstream = true
+	}
+	var id streamID
+	if idS != "$" {
+		var err error
+		if id, err = parseStreamID(idS, 0); err != nil {
+			setDirty(c)
+			c.WriteError(err.Error())
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		s, ok := db.streamKeys[key]
+		if !ok {
+			if !mkstream {
+				c.WriteError(msgXgroupKeyNotFound)
+				return
+			}
+			s = newStreamKey()
+			db.streamCreate(key, s)
+		}
+		if idS == "$" {
+			id = s.lastID
+		}
+		if !s.createGroup(group, id) {
+			c.WriteError("BUSYGROUP Consumer Group name already exists")
+			return
+		}
+		db.keyVersion[key]++
+		c.WriteOK()
+	})
+}
+
+// XGROUP DESTROY
+func (m *ShinyRedis) xgroupDestroy(c *server.Peer, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xgroup|destroy"))
+		return
+	}
+
+	key, group := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteError(msgXgroupKeyNotFound)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		s := db.streamKeys[key]
+		if _, ok := s.groups[group]; !ok {
+			c.WriteInt(0)
+			return
+		}
+		delete(s.groups, group)
+		db.keyVersion[key]++
+		c.WriteInt(1)
+	})
+}
+
+// XGROUP SETID
+func (m *ShinyRedis) xgroupSetid(c *server.Peer, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xgroup|setid"))
+		return
+	}
+
+	key, group, idS := args[0], args[1], args[2]
+	var id streamID
+	if idS != "$" {
+		var err error
+		if id, err = parseStreamID(idS, 0); err != nil {
+			setDirty(c)
+			c.WriteError(err.Error())
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		g, ok := m.streamGroup(c, db, key, group)
+		if !ok {
+			return
+		}
+		if idS == "$" {
+			id = db.streamKeys[key].lastID
+		}
+		g.lastID = id
+		db.keyVersion[key]++
+		c.WriteOK()
+	})
+}
+
+// XGROUP CREATECONSUMER
+func (m *ShinyRedis) xgroupCreateconsumer(c *server.Peer, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xgroup|createconsumer"))
+		return
+	}
+
+	key, group, consumer := args[0], args[1], args[2]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		g, ok := m.streamGroup(c, db, key, group)
+		if !ok {
+			return
+		}
+		if _, created := g.consumer(consumer, m.effectiveNow()); !created {
+			c.WriteInt(0)
+			return
+		}
+		db.keyVersion[key]++
+		c.WriteInt(1)
+	})
+}
+
+// XGROUP DELCONSUMER
+func (m *ShinyRedis) xgroupDelconsumer(c *server.Peer, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xgroup|delconsumer"))
+		return
+	}
+
+	key, group, consumer := args[0], args[1], args[2]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		g, ok := m.streamGroup(c, db, key, group)
+		if !ok {
+			return
+		}
+		if _, ok := g.consumers[consumer]; !ok {
+			c.WriteInt(0)
+			return
+		}
+		n := g.deleteConsumer(consumer)
+		db.keyVersion[key]++
+		c.WriteInt(n)
+	})
+}
+
+// streamGroup gives the consumer group of a stream key, or writes the error
+// if there is no such key or group.
+func (m *ShinyRedis) streamGroup(c *server.Peer, db *RedisDB, key, group string) (*streamGroup, bool) {
+	if !db.exists(key) {
+		c.WriteError(msgXgroupKeyNotFound)
+		return nil, false
+	}
+	if db.t(key) != "stream" {
+		c.WriteError(msgWrongType)
+		return nil, false
+	}
+	g, ok := db.streamKeys[key].groups[group]
+	if !ok {
+		c.WriteError(fmt.Sprintf("NOGROUP No such consumer group '%s' for key name '%s'", group, key))
+		return nil, false
+	}
+	return g, true
+}
+
+// XACK
+func (m *ShinyRedis) cmdXack(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, group := args[0], args[1]
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		g, ok := db.streamKeys[key].groups[group]
+		if !ok {
+			c.WriteInt(0)
+			return
+		}
+		n := g.ack(ids)
+		if n > 0 {
+			db.keyVersion[key]++
+		}
+		c.WriteInt(n)
+	})
+}
+
+// XPENDING
+func (m *ShinyRedis) cmdXpending(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, group, args := args[0], args[1], args[2:]
+	var (
+		extended   = len(args) > 0
+		minIdle    time.Duration
+		start, end streamID
+		count      int
+		consumer   string
+	)
+	if extended {
+		if strings.ToUpper(args[0]) == "IDLE" {
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			ms, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			minIdle = time.Duration(ms) * time.Millisecond
+			args = args[2:]
+		}
+		if len(args) != 3 && len(args) != 4 {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		var err error
+		if start, err = parseStreamBound(args[0], false); err != nil {
+			setDirty(c)
+			c.WriteError(err.Error())
+			return
+		}
+		if end, err = parseStreamBound(args[1], true); err != nil {
+			setDirty(c)
+			c.WriteError(err.Error())
+			return
+		}
+		if count, err = strconv.Atoi(args[2]); err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		if len(args) == 4 {
+			consumer = args[3]
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		var g *streamGroup
+		if s, ok := db.streamKeys[key]; ok {
+			g = s.groups[group]
+		}
+		if g == nil {
+			c.WriteError(fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s'", key, group))
+			return
+		}
+
+		if !extended {
+			writeXpendingSummary(c, g)
+			return
+		}
+
+		now := m.effectiveNow()
+		var res []streamPending
+		for _, p := range g.pending {
+			if count <= 0 || len(res) >= count {
+				break
+			}
+			if p.id.less(start) || end.less(p.id) {
+				continue
+			}
+			if consumer != "" && p.consumer != consumer {
+				continue
+			}
+			if now.Sub(p.deliveryTime) < minIdle {
+				continue
+			}
+			res = append(res, p)
+		}
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(res))
+			for _, p := range res {
+				w.WriteLen(4)
+				w.WriteBulk(p.id.String())
+				w.WriteBulk(p.consumer)
+				w.WriteInt(int(now.Sub(p.deliveryTime).Milliseconds()))
+				w.WriteInt(p.deliveryCount)
+			}
+		})
+	})
+}
+
+// writeXpendingSummary writes the XPENDING reply without a range.
+func writeXpendingSummary(c *server.Peer, g *streamGroup) {
+	if len(g.pending) == 0 {
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(4)
+			w.WriteInt(0)
+			w.WriteNull()
+			w.WriteNull()
+			w.WriteNullArray()
+		})
+		return
+	}
+
+	counts := map[string]int{}
+	for _, p := range g.pending {
+		counts[p.consumer]++
+	}
+	var consumers []string
+	for name := range counts {
+		consumers = append(consumers, name)
+	}
+	sort.Strings(consumers)
+
+	c.Block(func(w *server.Writer) {
+		w.WriteLen(4)
+		w.WriteInt(len(g.pending))
+		w.WriteBulk(g.pending[0].id.String())
+		w.WriteBulk(g.pending[len(g.pending)-1].id.String())
+		w.WriteLen(len(consumers))
+		for _, name := range consumers {
+			w.WriteLen(2)
+			w.WriteBulk(name)
+			w.WriteBulk(strconv.Itoa(counts[name]))
+		}
+	})
+}
+
+// XCLAIM
+func (m *ShinyRedis) cmdXclaim(c *server.Peer, cmd string, args []string) {
+	if len(args) < 5 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, group, consumer := args[0], args[1], args[2]
+	minIdleMs, err := strconv.Atoi(args[3])
+	if err != nil {
+		setDirty(c)
+		c.WriteError("ERR Invalid min-idle-time argument for XCLAIM")
+		return
+	}
+	args = args[4:]
+
+	var ids []streamID
+	for len(args) > 0 {
+		id, err := parseStreamID(args[0], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+		args = args[1:]
+	}
+	if len(ids) == 0 {
+		setDirty(c)
+		c.WriteError(msgInvalidStreamID)
+		return
+	}
+
+	var (
+		deliveryIdle  = time.Duration(-1)
+		deliveryTime  time.Time
+		retryCount    = -1
+		force, justID bool
+		lastID        *streamID
+	)
+	for len(args) > 0 {
+		opt := strings.ToUpper(args[0])
+		switch opt {
+		case "FORCE":
+			force = true
+			args = args[1:]
+			continue
+		case "JUSTID":
+			justID = true
+			args = args[1:]
+			continue
+		case "IDLE", "TIME", "RETRYCOUNT", "LASTID":
+		default:
+			setDirty(c)
+			c.WriteError(fmt.Sprintf("ERR Unrecognized XCLAIM option '%s'", args[0]))
+			return
+		}
+		if len(args) < 2 {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		if opt == "LASTID" {
+			id, err := parseStreamID(args[1], 0)
+			if err != nil {
+				setDirty(c)
+				c.WriteError(err.Error())
+				return
+			}
+			lastID = &id
+			args = args[2:]
+			continue
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			setDirty(c)
+			c.WriteError(msgInvalidInt)
+			return
+		}
+		switch opt {
+		case "IDLE":
+			deliveryIdle = time.Duration(n) * time.Millisecond
+		case "TIME":
+			deliveryTime = time.UnixMilli(int64(n))
+		case "RETRYCOUNT":
+			retryCount = n
+		}
+		args = args[2:]
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		g, ok := m.streamGroup(c, db, key, group)
+		if !ok {
+			return
+		}
+		s := db.streamKeys[key]
+		now := m.effectiveNow()
+		if lastID != nil && g.lastID.less(*lastID) {
+			g.lastID = *lastID
+		}
+
+		newTime := now
+		switch {
+		case deliveryIdle >= 0:
+			newTime = now.Add(-deliveryIdle)
+		case !deliveryTime.IsZero():
+			newTime = deliveryTime
+		}
+
+		cons, _ := g.consumer(consumer, now)
+		cons.seenTime = now
+		var claimed []streamEntry
+		for _, id := range ids {
+			e, exists := s.get(id)
+			p, pending := g.getPending(id)
+			if !pending {
+				if !force || !exists {
+					continue
+				}
+				g.setPending(streamPending{id: id, consumer: consumer, deliveryTime: now})
+				p, _ = g.getPending(id)
+			} else if !exists {
+				// deleted from the stream, no point in keeping it around
+				g.ack([]streamID{id})
+				continue
+			} else if now.Sub(p.deliveryTime) < time.Duration(minIdleMs)*time.Millisecond {
+				continue
+			}
+			p.consumer = consumer
+			p.deliveryTime = newTime
+			switch {
+			case retryCount >= 0:
+				p.deliveryCount = retryCount
+			case !justID:
+				p.deliveryCount++
+			}
+			cons.activeTime = now
+			claimed = append(claimed, e)
+		}
+		db.keyVersion[key]++
+
+		c.Block(func(w *server.Writer) {
+			if justID {
+				w.WriteLen(len(claimed))
+				for _, e := range claimed {
+					w.WriteBulk(e.id.String())
+				}
+				return
+			}
+			writeStreamEntries(w, claimed)
+		})
+	})
+}
+
+// XAUTOCLAIM
+func (m *ShinyRedis) cmdXautoclaim(c *server.Peer, cmd string, args []string) {
+	if len(args) < 5 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, group, consumer := args[0], args[1], args[2]
+	minIdleMs, err := strconv.Atoi(args[3])
+	if err != nil || minIdleMs < 0 {
+		setDirty(c)
+		c.WriteError("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+		return
+	}
+	start, err := parseStreamBound(args[4], false)
+	if err != nil {
+		setDirty(c)
+		c.WriteError(err.Error())
+		return
+	}
+	args = args[5:]
+
+	var (
+		count  = 100
+		justID bool
+	)
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case "COUNT":
+			if len(args) < 2 {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				setDirty(c)
+				c.WriteError("ERR COUNT must be > 0")
+				return
+			}
+			count = n
+			args = args[2:]
+		case "JUSTID":
+			justID = true
+			args = args[1:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		var g *streamGroup
+		if s, ok := db.streamKeys[key]; ok {
+			g = s.groups[group]
+		}
+		if g == nil {
+			c.WriteError(fmt.Sprintf("NOGROUP No such key '%s' or consumer group '%s'", key, group))
+			return
+		}
+		s := db.streamKeys[key]
+		now := m.effectiveNow()
+		minIdle := time.Duration(minIdleMs) * time.Millisecond
+
+		cons, _ := g.consumer(consumer, now)
+		cons.seenTime = now
+		var (
+			claimed  []streamEntry
+			deleted  []streamID
+			attempts = count * 10
+			i        = g.findPending(start)
+		)
+		for ; i < len(g.pending) && attempts > 0 && len(claimed) < count; attempts-- {
+			p := &g.pending[i]
+			e, exists := s.get(p.id)
+			if !exists {
+				deleted = append(deleted, p.id)
+				g.pending = append(g.pending[:i], g.pending[i+1:]...)
+				continue
+			}
+			i++
+			if now.Sub(p.deliveryTime) < minIdle {
+				continue
+			}
+			p.consumer = consumer
+			p.deliveryTime = now
+			if !justID {
+				p.deliveryCount++
+			}
+			cons.activeTime = now
+			claimed = append(claimed, e)
+		}
+		next := streamID{}
+		if i < len(g.pending) {
+			next = g.pending[i].id
+		}
+		db.keyVersion[key]++
+
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(3)
+			w.WriteBulk(next.String())
+			if justID {
+				w.WriteLen(len(claimed))
+				for _, e := range claimed {
+					w.WriteBulk(e.id.String())
+				}
+			} else {
+				writeStreamEntries(w, claimed)
+			}
+			w.WriteLen(len(deleted))
+			for _, id := range deleted {
+				w.WriteBulk(id.String())
+			}
+		})
+	})
+}
+
+// XINFO
+func (m *ShinyRedis) cmdXinfo(c *server.Peer, cmd string, args []string) {
+	if len(args) == 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	sub, args := strings.ToUpper(args[0]), args[1:]
+	switch sub {
+	case "STREAM":
+		m.xinfoStream(c, args)
+	case "GROUPS":
+		m.xinfoGroups(c, args)
+	case "CONSUMERS":
+		m.xinfoConsumers(c, args)
+	default:
+		setDirty(c)
+		c.WriteError(fmt.Sprintf("ERR unknown subcommand '%s'. Try XINFO HELP.", sub))
+	}
+}
+
+// XINFO STREAM
+func (m *ShinyRedis) xinfoStream(c *server.Peer, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xinfo|stream"))
+		return
+	}
+
+	key, args := args[0], args[1:]
+	var (
+		full  bool
+		count = 10
+	)
+	if len(args) > 0 {
+		if strings.ToUpper(args[0]) != "FULL" {
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+		full = true
+		args = args[1:]
+		if len(args) > 0 {
+			if len(args) != 2 || strings.ToUpper(args[0]) != "COUNT" {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				setDirty(c)
+				c.WriteError(msgInvalidInt)
+				return
+			}
+			count = n
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		s := db.streamKeys[key]
+
+		if full {
+			writeXinfoFull(c, s, count, m.effectiveNow())
+			return
+		}
+		c.Block(func(w *server.Writer) {
+			w.WriteMapLen(8)
+			writeXinfoCommon(w, s)
+			w.WriteBulk("groups")
+			w.WriteInt(len(s.groups))
+			w.WriteBulk("first-entry")
+			if len(s.entries) == 0 {
+				w.WriteNull()
+			} else {
+				writeStreamEntry(w, s.entries[0])
+			}
+			w.WriteBulk("last-entry")
+			if len(s.entries) == 0 {
+				w.WriteNull()
+			} else {
+				writeStreamEntry(w, s.entries[len(s.entries)-1])
+			}
+		})
+	})
+}
+
+// writeXinfoCommon writes the first 5 fields of XINFO STREAM, with and
+// without FULL.
+func writeXinfoCommon(w *server.Writer, s *streamKey) {
+	first := streamID{}
+	if len(s.entries) > 0 {
+		first = s.entries[0].id
+	}
+	w.WriteBulk("length")
+	w.WriteInt(len(s.entries))
+	w.WriteBulk("last-generated-id")
+	w.WriteBulk(s.lastID.String())
+	w.WriteBulk("max-deleted-entry-id")
+	w.WriteBulk(s.maxDeletedID.String())
+	w.WriteBulk("entries-added")
+	w.WriteInt(s.entriesAdded)
+	w.WriteBulk("recorded-first-entry-id")
+	w.WriteBulk(first.String())
+}
+
+// writeXinfoFull writes XINFO STREAM FULL. count limits the entries and the
+// pending lists, 0 is no limit.
+func writeXinfoFull(c *server.Peer, s *streamKey, count int, now time.Time) {
+	limit := func(n int) int {
+		if count > 0 && n > count {
+			return count
+		}
+		return n
+	}
+	groups := sortedGroups(s)
+
+	c.Block(func(w *server.Writer) {
+		w.WriteMapLen(7)
+		writeXinfoCommon(w, s)
+		w.WriteBulk("entries")
+		entries := s.entries[:limit(len(s.entries))]
+		writeStreamEntries(w, entries)
+		w.WriteBulk("groups")
+		w.WriteLen(len(groups))
+		for _, name := range groups {
+			g := s.groups[name]
+			lag := s.lag(g)
+			w.WriteMapLen(7)
+			w.WriteBulk("name")
+			w.WriteBulk(name)
+			w.WriteBulk("last-delivered-id")
+			w.WriteBulk(g.lastID.String())
+			w.WriteBulk("entries-read")
+			w.WriteInt(s.entriesAdded - lag)
+			w.WriteBulk("lag")
+			w.WriteInt(lag)
+			w.WriteBulk("pel-count")
+			w.WriteInt(len(g.pending))
+			w.WriteBulk("pending")
+			pending := g.pending[:limit(len(g.pending))]
+			w.WriteLen(len(pending))
+			for _, p := range pending {
+				w.WriteLen(4)
+				w.WriteBulk(p.id.String())
+				w.WriteBulk(p.consumer)
+				w.WriteInt(int(p.deliveryTime.UnixMilli()))
+				w.WriteInt(p.deliveryCount)
+			}
+			w.WriteBulk("consumers")
+			consumers := sortedConsumers(g)
+			w.WriteLen(len(consumers))
+			for _, cname := range consumers {
+				cons := g.consumers[cname]
+				cpending := g.consumerPending(cname)
+				w.WriteMapLen(5)
+				w.WriteBulk("name")
+				w.WriteBulk(cname)
+				w.WriteBulk("seen-time")
+				w.WriteInt(int(cons.seenTime.UnixMilli()))
+				w.WriteBulk("active-time")
+				if cons.activeTime.IsZero() {
+					w.WriteInt(-1)
+				} else {
+					w.WriteInt(int(cons.activeTime.UnixMilli()))
+				}
+				w.WriteBulk("pel-count")
+				w.WriteInt(len(cpending))
+				w.WriteBulk("pending")
+				cpending = cpending[:limit(len(cpending))]
+				w.WriteLen(len(cpending))
+				for _, p := range cpending {
+					w.WriteLen(3)
+					w.WriteBulk(p.id.String())
+					w.WriteInt(int(p.deliveryTime.UnixMilli()))
+					w.WriteInt(p.deliveryCount)
+				}
+			}
+		}
+	})
+}
+
+// XINFO GROUPS
+func (m *ShinyRedis) xinfoGroups(c *server.Peer, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xinfo|groups"))
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		if db.t(key) != "stream" {
+			c.WriteError(msgWrongType)
+			return
+		}
+		s := db.streamKeys[key]
+
+		groups := sortedGroups(s)
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(groups))
+			for _, name := range groups {
+				g := s.groups[name]
+				lag := s.lag(g)
+				w.WriteMapLen(6)
+				w.WriteBulk("name")
+				w.WriteBulk(name)
+				w.WriteBulk("consumers")
+				w.WriteInt(len(g.consumers))
+				w.WriteBulk("pending")
+				w.WriteInt(len(g.pending))
+				w.WriteBulk("last-delivered-id")
+				w.WriteBulk(g.lastID.String())
+				w.WriteBulk("entries-read")
+				w.WriteInt(s.entriesAdded - lag)
+				w.WriteBulk("lag")
+				w.WriteInt(lag)
+			}
+		})
+	})
+}
+
+// XINFO CONSUMERS
+func (m *ShinyRedis) xinfoConsumers(c *server.Peer, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber("xinfo|consumers"))
+		return
+	}
+
+	key, group := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteError(msgKeyNotFound)
+			return
+		}
+		g, ok := m.streamGroup(c, db, key, group)
+		if !ok {
+			return
+		}
+
+		now := m.effectiveNow()
+		consumers := sortedConsumers(g)
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(consumers))
+			for _, name := range consumers {
+				cons := g.consumers[name]
+				w.WriteMapLen(4)
+				w.WriteBulk("name")
+				w.WriteBulk(name)
+				w.WriteBulk("pending")
+				w.WriteInt(len(g.consumerPending(name)))
+				w.WriteBulk("idle")
+				w.WriteInt(int(now.Sub(cons.seenTime).Milliseconds()))
+				w.WriteBulk("inactive")
+				if cons.activeTime.IsZero() {
+					w.WriteInt(-1)
+				} else {
+					w.WriteInt(int(now.Sub(cons.activeTime).Milliseconds()))
+				}
+			}
+		})
+	})
+}
+
+func sortedGroups(s *streamKey) []string {
+	var names []string
+	for name := range s.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedConsumers(g *streamGroup) []string {
+	var names []string
+	for name := range g.consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeStreamEntry writes a single [id, [field, value, ...]] entry. An entry
+// without values was deleted, and has a nil instead.
+func writeStreamEntry(w *server.Writer, e streamEntry) {
+	w.WriteLen(2)
+	w.WriteBulk(e.id.String())
+	if e.values == nil {
+		w.WriteNullArray()
+		return
+	}
+	w.WriteStrings(e.values)
+}
+
+func writeStreamEntries(w *server.Writer, entries []streamEntry) {
+	w.WriteLen(len(entries))
+	for _, e := range entries {
+		writeStreamEntry(w, e)
+	}
+}
+
+// writeStreamReads writes the XREAD reply, which is a map in RESP3.
+func writeStreamReads(w *server.Writer, res []streamRead) {
+	if w.Resp3() {
+		w.WriteMapLen(len(res))
+	} else {
+		w.WriteLen(len(res))
+	}
+	for _, r := range res {
+		if !w.Resp3() {
+			w.WriteLen(2)
+		}
+		w.WriteBulk(r.key)
+		writeStreamEntries(w, r.entries)
+	}
+}
+
+// parseStreamIDs parses a list of full or incomplete IDs.
+func parseStreamIDs(args []string) ([]streamID, error) {
+	var ids []streamID
+	for _, a := range args {
+		id, err := parseStreamID(a, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseStreamBound parses an XRANGE bound: "-", "+", an ID, an incomplete ID,
+// or an exclusive "(<id>". An incomplete end ID includes all its sequences.
+func parseStreamBound(s string, isEnd bool) (streamID, error) {
+	switch s {
+	case "-":
+		return streamID{}, nil
+	case "+":
+		return streamID{math.MaxUint64, math.MaxUint64}, nil
+	}
+	excl := len(s) > 1 && s[0] == '('
+	if excl {
+		s = s[1:]
+	}
+	missingSeq := uint64(0)
+	if isEnd {
+		missingSeq = math.MaxUint64
+	}
+	id, err := parseStreamID(s, missingSeq)
+	if err != nil || !excl {
+		return id, err
+	}
+	if isEnd {
+		id, ok := id.prev()
+		if !ok {
+			return id, errInvalidEndID
+		}
+		return id, nil
+	}
+	id, ok := id.next()
+	if !ok {
+		return id, errInvalidStartID
+	}
+	return id, nil
+}
@@ -0,0 +1,124 @@
+package datastructure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestXaddExhausted(t *testing.T) {
+	_, c := start(t)
+	const max = "18446744073709551615-18446744073709551615"
+	c.must(`$"`+max+`"`, "XADD", "st", max, "f", "v")
+	c.must("-"+msgStreamExhausted, "XADD", "st", "*", "f", "v")
+	c.must("-"+msgStreamExhausted, "XADD", "st", "18446744073709551615-*", "f", "v")
+	c.must("-"+msgStreamExhausted, "XADD", "st", max, "f", "v")
+	c.must(":1", "XLEN", "st")
+
+	c.must(`$"18446744073709551615-18446744073709551614"`, "XADD", "s2", "18446744073709551615-18446744073709551614", "f", "v")
+	c.must(`$"`+max+`"`, "XADD", "s2", "*", "f", "v")
+	c.must("-"+msgStreamExhausted, "XADD", "s2", "*", "f", "v")
+}
+
+func TestXadd(t *testing.T) {
+	m, c := start(t)
+	setNow(m, time.UnixMilli(1000))
+	c.must(`$"1000-0"`, "XADD", "s", "*", "f", "v")
+	c.must(`$"1000-1"`, "XADD", "s", "*", "f", "w")
+	c.must(`$"1000-5"`, "XADD", "s", "1000-5", "a", "b")
+	c.must(`$"1000-6"`, "XADD", "s", "1000-*", "a", "b")
+	c.must(`$"2000-0"`, "XADD", "s", "2000-*", "a", "b")
+	c.must("-"+msgStreamIDTooSmall, "XADD", "s", "1000-5", "a", "b")
+	c.must("-"+msgStreamIDTooSmall, "XADD", "s", "1000-*", "a", "b")
+	c.must("-"+msgStreamIDZero, "XADD", "t", "0-0", "a", "b")
+	c.must("-"+msgInvalidStreamID, "XADD", "t", "x", "a", "b")
+	c.must(`-ERR wrong number of arguments for 'xadd' command`, "XADD", "t", "*", "a", "b", "c")
+	c.must(`nil`, "XADD", "t", "NOMKSTREAM", "*", "a", "b")
+	c.must(`:0`, "EXISTS", "t")
+	c.must(`:5`, "XLEN", "s")
+	c.must(`:0`, "XLEN", "nosuch")
+	c.must(`+stream`, "TYPE", "s")
+
+	c.must(`+OK`, "SET", "str", "x")
+	c.must("-"+msgWrongType, "XADD", "str", "*", "a", "b")
+}
+
+func TestXrange(t *testing.T) {
+	m, c := start(t)
+	setNow(m, time.UnixMilli(1000))
+	c.must(`$"1000-0"`, "XADD", "s", "*", "f", "v")
+	c.must(`$"1000-1"`, "XADD", "s", "*", "f", "w")
+	c.must(`$"1000-5"`, "XADD", "s", "1000-5", "a", "b")
+	c.must(`$"1000-6"`, "XADD", "s", "1000-*", "a", "b")
+	c.must(`*[*[$"1000-0" *[$"f" $"v"]] *[$"1000-1" *[$"f" $"w"]]]`, "XRANGE", "s", "-", "+", "COUNT", "2")
+	c.must(`*[*[$"1000-6" *[$"a" $"b"]]]`, "XREVRANGE", "s", "+", "(1000-5")
+	c.must(`*[*[$"1000-1" *[$"f" $"w"]]]`, "XRANGE", "s", "(1000-0", "(1000-5")
+	c.must(`*[]`, "XRANGE", "nosuch", "-", "+")
+	c.must(`:1`, "XDEL", "s", "1000-5", "9-9")
+	c.must(`$"1000-7"`, "XADD", "s", "MAXLEN", "2", "*", "x", "y")
+	c.must(`:2`, "XLEN", "s")
+	c.must(`:1`, "XTRIM", "s", "MINID", "1000-7")
+	c.must(`-ERR syntax error, LIMIT cannot be used without the special ~ option`, "XTRIM", "s", "MAXLEN", "1", "LIMIT", "1")
+}
+
+func TestXread(t *testing.T) {
+	m, c := start(t)
+	setNow(m, time.UnixMilli(1000))
+	c.must(`$"1000-7"`, "XADD", "s", "1000-7", "x", "y")
+	c.must(`*[*[$"s" *[*[$"1000-7" *[$"x" $"y"]]]]]`, "XREAD", "STREAMS", "s", "0")
+	c.must(`nilarr`, "XREAD", "STREAMS", "s", "$")
+	c.must(`-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.`, "XREAD", "STREAMS", "s", "t", "0")
+	c.must(`nilarr`, "XREAD", "BLOCK", "10", "STREAMS", "s", "$")
+
+	c2 := dial(t, m)
+	c2.send("XREAD", "BLOCK", "0", "STREAMS", "s", "$")
+	waitBlocked(t, m, 1)
+	c.must(`$"1000-8"`, "XADD", "s", "*", "k", "v")
+	if got := c2.read(); got != `*[*[$"s" *[*[$"1000-8" *[$"k" $"v"]]]]]` {
+		t.Errorf("XREAD BLOCK: got %q", got)
+	}
+
+	c.do("HELLO", "3")
+	c.must(`%[$"s" *[*[$"1000-7" *[$"x" $"y"]] *[$"1000-8" *[$"k" $"v"]]]]`, "XREAD", "STREAMS", "s", "0")
+}
+
+func TestXgroup(t *testing.T) {
+	m, c := start(t)
+	setNow(m, time.UnixMilli(1000))
+	c.must(`$"1000-7"`, "XADD", "s", "1000-7", "x", "y")
+	c.must(`$"1000-8"`, "XADD", "s", "1000-8", "k", "v")
+
+	c.must(`+OK`, "XGROUP", "CREATE", "s", "g", "0")
+	c.must(`-BUSYGROUP Consumer Group name already exists`, "XGROUP", "CREATE", "s", "g", "0")
+	c.must("-"+msgXgroupKeyNotFound, "XGROUP", "CREATE", "nosuch", "g", "$")
+	c.must(`+OK`, "XGROUP", "CREATE", "new", "g", "$", "MKSTREAM")
+	c.must(`:0`, "XLEN", "new")
+
+	c.must(`*[*[$"s" *[*[$"1000-7" *[$"x" $"y"]]]]]`, "XREADGROUP", "GROUP", "g", "alice", "COUNT", "1", "STREAMS", "s", ">")
+	c.must(`*[*[$"s" *[*[$"1000-8" *[$"k" $"v"]]]]]`, "XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">")
+	c.must(`nilarr`, "XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", ">")
+	c.must(`*[*[$"s" *[*[$"1000-8" *[$"k" $"v"]]]]]`, "XREADGROUP", "GROUP", "g", "bob", "STREAMS", "s", "0")
+	c.must(`-NOGROUP No such key 's' or consumer group 'nog' in XREADGROUP with GROUP option`, "XREADGROUP", "GROUP", "nog", "bob", "STREAMS", "s", ">")
+	c.must(`*[:2 $"1000-7" $"1000-8" *[*[$"alice" $"1"] *[$"bob" $"1"]]]`, "XPENDING", "s", "g")
+
+	setNow(m, time.UnixMilli(6000))
+	c.must(`*[*[$"1000-8" $"bob" :5000 :2]]`, "XPENDING", "s", "g", "-", "+", "10", "bob")
+	c.must(`*[*[$"1000-7" *[$"x" $"y"]]]`, "XCLAIM", "s", "g", "bob", "1000", "1000-7")
+	c.must(`*[]`, "XCLAIM", "s", "g", "bob", "1000", "1000-7")
+	c.must(`*[:2 $"1000-7" $"1000-8" *[*[$"bob" $"2"]]]`, "XPENDING", "s", "g")
+
+	setNow(m, time.UnixMilli(9000))
+	c.must(`*[$"0-0" *[$"1000-7" $"1000-8"] *[]]`, "XAUTOCLAIM", "s", "g", "carol", "1000", "0", "JUSTID")
+	c.must(`:1`, "XACK", "s", "g", "1000-7", "1000-99")
+	c.must(`:1`, "XDEL", "s", "1000-8")
+	c.must(`*[$"0-0" *[] *[$"1000-8"]]`, "XAUTOCLAIM", "s", "g", "carol", "0", "0")
+	c.must(`:1`, "XGROUP", "CREATECONSUMER", "s", "g", "dave")
+	c.must(`:0`, "XGROUP", "CREATECONSUMER", "s", "g", "dave")
+	c.must(`:0`, "XGROUP", "DELCONSUMER", "s", "g", "carol")
+	c.must(`+OK`, "XGROUP", "SETID", "s", "g", "$")
+	c.must(`nilarr`, "XREADGROUP", "GROUP", "g", "dave", "STREAMS", "s", ">")
+	c.must(`+OK`, "XGROUP", "SETID", "s", "g", "0")
+	c.must(`*[*[$"s" *[*[$"1000-7" *[$"x" $"y"]]]]]`, "XREADGROUP", "GROUP", "g", "dave", "STREAMS", "s", ">")
+	c.must(`:1`, "XGROUP", "DESTROY", "s", "g")
+	c.must(`:0`, "XGROUP", "DESTROY", "s", "g")
+	c.must(`*[]`, "XINFO", "GROUPS", "s")
+}
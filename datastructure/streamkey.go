@@ -0,0 +1,401 @@
+package datastructure
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errInvalidStreamID  = errors.New(msgInvalidStreamID)
+	errStreamIDTooSmall = errors.New(msgStreamIDTooSmall)
+	errStreamIDZero     = errors.New(msgStreamIDZero)
+	errStreamExhausted  = errors.New(msgStreamExhausted)
+	errInvalidStartID   = errors.New("ERR invalid start ID for the interval")
+	errInvalidEndID     = errors.New("ERR invalid end ID for the interval")
+)
+
+// streamID is an entry ID: milliseconds and a sequence number.
+type streamID struct {
+	ms  uint64
+	seq uint64
+}
+
+func (id streamID) String() string {
+	return fmt.Sprintf("%d-%d", id.ms, id.seq)
+}
+
+// less tells whether id sorts before o.
+func (id streamID) less(o streamID) bool {
+	return id.ms < o.ms || (id.ms == o.ms && id.seq < o.seq)
+}
+
+// next gives the smallest ID after id. The bool is false on overflow.
+func (id streamID) next() (streamID, bool) {
+	switch {
+	case id.seq < math.MaxUint64:
+		return streamID{id.ms, id.seq + 1}, true
+	case id.ms < math.MaxUint64:
+		return streamID{id.ms + 1, 0}, true
+	default:
+		return id, false
+	}
+}
+
+// prev gives the largest ID before id. The bool is false on underflow.
+func (id streamID) prev() (streamID, bool) {
+	switch {
+	case id.seq > 0:
+		return streamID{id.ms, id.seq - 1}, true
+	case id.ms > 0:
+		return streamID{id.ms - 1, math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// parseStreamID parses "<ms>-<seq>", or "<ms>", in which case the sequence is
+// missingSeq.
+func parseStreamID(s string, missingSeq uint64) (streamID, error) {
+	ms, seq, found := strings.Cut(s, "-")
+	var (
+		id  streamID
+		err error
+	)
+	if id.ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, errInvalidStreamID
+	}
+	if !found {
+		id.seq = missingSeq
+		return id, nil
+	}
+	if id.seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return id, errInvalidStreamID
+	}
+	return id, nil
+}
+
+// streamEntry is a single stream entry. values are field/value pairs.
+type streamEntry struct {
+	id     streamID
+	values []string
+}
+
+// streamPending is an entry in the pending entries list of a group: delivered
+// to a consumer, but not acknowledged yet.
+type streamPending struct {
+	id            streamID
+	consumer      string
+	deliveryTime  time.Time
+	deliveryCount int
+}
+
+// streamConsumer is a consumer in a group.
+type streamConsumer struct {
+	seenTime   time.Time // last interaction
+	activeTime time.Time // last successful read or claim, zero if never
+}
+
+// streamGroup is a consumer group.
+type streamGroup struct {
+	lastID    streamID
+	pending   []streamPending // ordered by id
+	consumers map[string]*streamConsumer
+}
+
+// streamKey is the value of a "stream" key. Entries are ordered by ID.
+type streamKey struct {
+	entries      []streamEntry
+	lastID       streamID // the last generated ID, which survives deletes
+	maxDeletedID streamID
+	entriesAdded int
+	groups       map[string]*streamGroup
+}
+
+func newStreamKey() *streamKey {
+	return &streamKey{
+		groups: map[string]*streamGroup{},
+	}
+}
+
+// copy gives an independent copy, with copies of all groups.
+func (s *streamKey) copy() *streamKey {
+	res := *s
+	res.entries = append([]streamEntry(nil), s.entries...)
+	res.groups = map[string]*streamGroup{}
+	for name, g := range s.groups {
+		cg := &streamGroup{
+			lastID:    g.lastID,
+			pending:   append([]streamPending(nil), g.pending...),
+			consumers: map[string]*streamConsumer{},
+		}
+		for cname, c := range g.consumers {
+			cc := *c
+			cg.consumers[cname] = &cc
+		}
+		res.groups[name] = cg
+	}
+	return &res
+}
+
+// generateID gives the ID for a new entry. id is "*", "<ms>-*", or an
+// explicit ID. Once the last possible ID is used nothing can be added, no
+// matter the ID.
+func (s *streamKey) generateID(id string, now time.Time) (streamID, error) {
+	next, ok := s.lastID.next()
+	if !ok {
+		return streamID{}, errStreamExhausted
+	}
+
+	if id == "*" {
+		ms := uint64(now.UnixMilli())
+		if ms > s.lastID.ms {
+			return streamID{ms: ms}, nil
+		}
+		return next, nil
+	}
+
+	if ms, ok := strings.CutSuffix(id, "-*"); ok {
+		v, err := strconv.ParseUint(ms, 10, 64)
+		if err != nil {
+			return streamID{}, errInvalidStreamID
+		}
+		switch {
+		case v < s.lastID.ms:
+			return streamID{}, errStreamIDTooSmall
+		case v > s.lastID.ms:
+			return streamID{ms: v}, nil
+		}
+		if next.ms != v {
+			return streamID{}, errStreamIDTooSmall
+		}
+		return next, nil
+	}
+
+	res, err := parseStreamID(id, 0)
+	if err != nil {
+		return res, err
+	}
+	if res == (streamID{}) {
+		return res, errStreamIDZero
+	}
+	if !s.lastID.less(res) {
+		return res, errStreamIDTooSmall
+	}
+	return res, nil
+}
+
+// add appends an entry. The ID must be valid, as given by generateID().
+func (s *streamKey) add(id streamID, values []string) {
+	s.entries = append(s.entries, streamEntry{id: id, values: values})
+	s.lastID = id
+	s.entriesAdded++
+}
+
+// find gives the position of the first entry with an ID >= id.
+func (s *streamKey) find(id streamID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return !s.entries[i].id.less(id)
+	})
+}
+
+// get gives the entry with the given ID.
+func (s *streamKey) get(id streamID) (streamEntry, bool) {
+	i := s.find(id)
+	if i < len(s.entries) && s.entries[i].id == id {
+		return s.entries[i], true
+	}
+	return streamEntry{}, false
+}
+
+// rangeIDs gives the entries with start <= id <= end, at most count if count
+// is positive. With reverse it walks from end to start.
+func (s *streamKey) rangeIDs(start, end streamID, count int, reverse bool) []streamEntry {
+	if end.less(start) {
+		return nil
+	}
+	from, to := s.find(start), s.find(end)
+	if to < len(s.entries) && s.entries[to].id == end {
+		to++
+	}
+	elems := s.entries[from:to]
+	var res []streamEntry
+	for i := range elems {
+		if count > 0 && len(res) >= count {
+			break
+		}
+		if reverse {
+			res = append(res, elems[len(elems)-1-i])
+			continue
+		}
+		res = append(res, elems[i])
+	}
+	return res
+}
+
+// after gives the entries with an ID after id, at most count if count is
+// positive.
+func (s *streamKey) after(id streamID, count int) []streamEntry {
+	next, ok := id.next()
+	if !ok {
+		return nil
+	}
+	return s.rangeIDs(next, streamID{math.MaxUint64, math.MaxUint64}, count, false)
+}
+
+// delete removes the entries with the given IDs. Returns the number of
+// removed entries.
+func (s *streamKey) delete(ids []streamID) int {
+	removed := 0
+	for _, id := range ids {
+		i := s.find(id)
+		if i >= len(s.entries) || s.entries[i].id != id {
+			continue
+		}
+		s.entries = append(s.entries[:i], s.entries[i+1:]...)
+		if s.maxDeletedID.less(id) {
+			s.maxDeletedID = id
+		}
+		removed++
+	}
+	return removed
+}
+
+// trimMaxLen removes the oldest entries until there are at most n left, but
+// no more than limit entries if limit is positive. Returns the number of
+// removed entries.
+func (s *streamKey) trimMaxLen(n, limit int) int {
+	remove := len(s.entries) - n
+	if remove <= 0 {
+		return 0
+	}
+	if limit > 0 && remove > limit {
+		remove = limit
+	}
+	return s.trimFront(remove)
+}
+
+// trimMinID removes the entries with an ID below id, but no more than limit
+// entries if limit is positive. Returns the number of removed entries.
+func (s *streamKey) trimMinID(id streamID, limit int) int {
+	remove := s.find(id)
+	if limit > 0 && remove > limit {
+		remove = limit
+	}
+	return s.trimFront(remove)
+}
+
+func (s *streamKey) trimFront(n int) int {
+	if n == 0 {
+		return 0
+	}
+	if last := s.entries[n-1].id; s.maxDeletedID.less(last) {
+		s.maxDeletedID = last
+	}
+	s.entries = append([]streamEntry(nil), s.entries[n:]...)
+	return n
+}
+
+// createGroup adds a new consumer group. Returns false if it already exists.
+func (s *streamKey) createGroup(name string, lastID streamID) bool {
+	if _, ok := s.groups[name]; ok {
+		return false
+	}
+	s.groups[name] = &streamGroup{
+		lastID:    lastID,
+		consumers: map[string]*streamConsumer{},
+	}
+	return true
+}
+
+// lag gives the number of entries after the group's last delivered ID.
+func (s *streamKey) lag(g *streamGroup) int {
+	return len(s.after(g.lastID, 0))
+}
+
+// consumer gives the consumer with the given name, creating it if needed.
+// Returns whether it was created.
+func (g *streamGroup) consumer(name string, now time.Time) (*streamConsumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &streamConsumer{seenTime: now}
+	g.consumers[name] = c
+	return c, true
+}
+
+// findPending gives the position of the first pending entry with an ID >= id.
+func (g *streamGroup) findPending(id streamID) int {
+	return sort.Search(len(g.pending), func(i int) bool {
+		return !g.pending[i].id.less(id)
+	})
+}
+
+// getPending gives the pending entry with the given ID.
+func (g *streamGroup) getPending(id streamID) (*streamPending, bool) {
+	i := g.findPending(id)
+	if i < len(g.pending) && g.pending[i].id == id {
+		return &g.pending[i], true
+	}
+	return nil, false
+}
+
+// setPending adds or replaces a pending entry.
+func (g *streamGroup) setPending(p streamPending) {
+	i := g.findPending(p.id)
+	if i < len(g.pending) && g.pending[i].id == p.id {
+		g.pending[i] = p
+		return
+	}
+	g.pending = append(g.pending, streamPending{})
+	copy(g.pending[i+1:], g.pending[i:])
+	g.pending[i] = p
+}
+
+// ack removes the entries from the pending list. Returns the number of
+// removed entries.
+func (g *streamGroup) ack(ids []streamID) int {
+	acked := 0
+	for _, id := range ids {
+		i := g.findPending(id)
+		if i < len(g.pending) && g.pending[i].id == id {
+			g.pending = append(g.pending[:i], g.pending[i+1:]...)
+			acked++
+		}
+	}
+	return acked
+}
+
+// consumerPending gives the pending entries of a single consumer.
+func (g *streamGroup) consumerPending(name string) []streamPending {
+	var res []streamPending
+	for _, p := range g.pending {
+		if p.consumer == name {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+// deleteConsumer removes a consumer and all its pending entries. Returns the
+// number of removed pending entries.
+func (g *streamGroup) deleteConsumer(name string) int {
+	var (
+		keep    []streamPending
+		removed = 0
+	)
+	for _, p := range g.pending {
+		if p.consumer == name {
+			removed++
+			continue
+		}
+		keep = append(keep, p)
+	}
+	g.pending = keep
+	delete(g.consumers, name)
+	return removed
+}
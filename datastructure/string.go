@@ -0,0 +1,839 @@
+package datastructure
+
+import (
+	"math"
+	"shiny_redis/server"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStringLen is proto-max-bulk-len, the maximum size of a string value.
+const maxStringLen = 512 * 1024 * 1024
+
+// commandsString handles all string value operations.
+func commandsString(m *ShinyRedis) {
+	m.srv.Register("APPEND", m.cmdAppend, 3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("DECR", m.cmdDecr, 2, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("DECRBY", m.cmdDecrby, 3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("GET", m.cmdGet, 2, "readonly fast", 1, 1, 1)
+	m.srv.Register("GETDEL", m.cmdGetdel, 2, "write fast", 1, 1, 1)
+	m.srv.Register("GETEX", m.cmdGetex, -2, "write fast", 1, 1, 1)
+	m.srv.Register("GETRANGE", m.cmdGetrange, 4, "readonly", 1, 1, 1)
+	m.srv.Register("GETSET", m.cmdGetset, 3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("INCR", m.cmdIncr, 2, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("INCRBY", m.cmdIncrby, 3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("INCRBYFLOAT", m.cmdIncrbyfloat, 3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("MGET", m.cmdMget, -2, "readonly fast", 1, -1, 1)
+	m.srv.Register("MSET", m.cmdMset, -3, "write denyoom", 1, -1, 2)
+	m.srv.Register("MSETNX", m.cmdMsetnx, -3, "write denyoom", 1, -1, 2)
+	m.srv.Register("PSETEX", m.cmdPsetex, 4, "write denyoom", 1, 1, 1)
+	m.srv.Register("SET", m.cmdSet, -3, "write denyoom", 1, 1, 1)
+	m.srv.Register("SETEX", m.cmdSetex, 4, "write denyoom", 1, 1, 1)
+	m.srv.Register("SETNX", m.cmdSetnx, 3, "write denyoom fast", 1, 1, 1)
+	m.srv.Register("SETRANGE", m.cmdSetrange, 4, "write denyoom", 1, 1, 1)
+	m.srv.Register("STRLEN", m.cmdStrlen, 2, "readonly fast", 1, 1, 1)
+}
+
+// expireOpt is a parsed EX/PX/EXAT/PXAT option, as used by SET and GETEX.
+type expireOpt struct {
+	set bool
+	at  bool          // EXAT or PXAT
+	v   time.Duration // the relative TTL, or the unix time for *AT
+}
+
+// parseExpireOpt parses the argument of an EX/PX/EXAT/PXAT option. It returns
+// an error message if the value is not valid.
+func parseExpireOpt(cmd, opt, arg string) (expireOpt, string) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return expireOpt{}, msgInvalidInt
+	}
+	if n <= 0 {
+		return expireOpt{}, errInvalidExpire(cmd)
+	}
+	unit := time.Second
+	if opt == "PX" || opt == "PXAT" {
+		unit = time.Millisecond
+	}
+	if n > math.MaxInt64/int(unit) {
+		return expireOpt{}, errInvalidExpire(cmd)
+	}
+	return expireOpt{
+		set: true,
+		at:  opt == "EXAT" || opt == "PXAT",
+		v:   time.Duration(n) * unit,
+	}, ""
+}
+
+// ttl gives the TTL relative to now.
+func (e expireOpt) ttl(m *ShinyRedis) time.Duration {
+	if e.at {
+		return time.Unix(0, 0).Add(e.v).Sub(m.effectiveNow())
+	}
+	return e.v
+}
+
+// setTTL sets the TTL on an existing key. A TTL in the past removes the key.
+func (db *RedisDB) setTTL(k string, ttl time.Duration) {
+	if ttl <= 0 {
+		db.del(k, true)
+		return
+	}
+	db.ttl[k] = ttl
+	db.keyVersion[k]++
+}
+
+// SET
+func (m *ShinyRedis) cmdSet(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key     = args[0]
+		value   = args[1]
+		nx      bool
+		xx      bool
+		get     bool
+		keepTTL bool
+		expire  expireOpt
+	)
+	args = args[2:]
+	for len(args) > 0 {
+		switch opt := strings.ToUpper(args[0]); opt {
+		case "NX":
+			nx = true
+			args = args[1:]
+		case "XX":
+			xx = true
+			args = args[1:]
+		case "GET":
+			get = true
+			args = args[1:]
+		case "KEEPTTL":
+			if expire.set {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			keepTTL = true
+			args = args[1:]
+		case "EX", "PX", "EXAT", "PXAT":
+			if len(args) < 2 || expire.set || keepTTL {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			e, msg := parseExpireOpt(cmd, opt, args[1])
+			if msg != "" {
+				setDirty(c)
+				c.WriteError(msg)
+				return
+			}
+			expire = e
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+	if nx && xx {
+		setDirty(c)
+		c.WriteError(msgSyntaxError)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		var (
+			old    string
+			hasOld bool
+		)
+		if db.exists(key) {
+			if get && db.t(key) != "string" {
+				c.WriteError(msgWrongType)
+				return
+			}
+			if get {
+				old, hasOld = db.stringGet(key), true
+			}
+		}
+
+		done := (nx && db.exists(key)) || (xx && !db.exists(key))
+		if !done {
+			ttl, hasTTL := db.ttl[key]
+			db.del(key, true)
+			db.stringSet(key, value)
+			switch {
+			case expire.set:
+				db.setTTL(key, expire.ttl(m))
+			case keepTTL && hasTTL:
+				db.ttl[key] = ttl
+			}
+		}
+
+		switch {
+		case get && hasOld:
+			c.WriteBulk(old)
+		case get, done:
+			c.WriteNull()
+		default:
+			c.WriteOK()
+		}
+	})
+}
+
+// SETEX
+func (m *ShinyRedis) cmdSetex(c *server.Peer, cmd string, args []string) {
+	m.cmdXsetex(c, cmd, args, time.Second)
+}
+
+// PSETEX
+func (m *ShinyRedis) cmdPsetex(c *server.Peer, cmd string, args []string) {
+	m.cmdXsetex(c, cmd, args, time.Millisecond)
+}
+
+func (m *ShinyRedis) cmdXsetex(c *server.Peer, cmd string, args []string, unit time.Duration) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[2]
+	n, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if n <= 0 || n > math.MaxInt64/int(unit) {
+		setDirty(c)
+		c.WriteError(errInvalidExpire(cmd))
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		db.del(key, true) // Clear any existing keys.
+		db.stringSet(key, value)
+		db.setTTL(key, time.Duration(n)*unit)
+		c.WriteOK()
+	})
+}
+
+// SETNX
+func (m *ShinyRedis) cmdSetnx(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) {
+			c.WriteInt(0)
+			return
+		}
+
+		db.stringSet(key, value)
+		c.WriteInt(1)
+	})
+}
+
+// MSET
+func (m *ShinyRedis) cmdMset(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 || len(args)%2 != 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		for i := 0; i < len(args); i += 2 {
+			key, value := args[i], args[i+1]
+			db.del(key, true) // clear TTL
+			db.stringSet(key, value)
+		}
+		c.WriteOK()
+	})
+}
+
+// MSETNX
+func (m *ShinyRedis) cmdMsetnx(c *server.Peer, cmd string, args []string) {
+	if len(args) < 2 || len(args)%2 != 0 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		for i := 0; i < len(args); i += 2 {
+			if db.exists(args[i]) {
+				c.WriteInt(0)
+				return
+			}
+		}
+		for i := 0; i < len(args); i += 2 {
+			db.stringSet(args[i], args[i+1])
+		}
+		c.WriteInt(1)
+	})
+}
+
+// GET
+func (m *ShinyRedis) cmdGet(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteBulk(db.stringGet(key))
+	})
+}
+
+// GETSET
+func (m *ShinyRedis) cmdGetset(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if db.exists(key) && db.t(key) != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		old, ok := db.stringKeys[key]
+		db.del(key, true) // GETSET clears the TTL
+		db.stringSet(key, value)
+		if !ok {
+			c.WriteNull()
+			return
+		}
+		c.WriteBulk(old)
+	})
+}
+
+// GETDEL
+func (m *ShinyRedis) cmdGetdel(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v := db.stringGet(key)
+		db.del(key, true)
+		c.WriteBulk(v)
+	})
+}
+
+// GETEX
+func (m *ShinyRedis) cmdGetex(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	var (
+		key     = args[0]
+		persist bool
+		expire  expireOpt
+	)
+	args = args[1:]
+	for len(args) > 0 {
+		switch opt := strings.ToUpper(args[0]); opt {
+		case "PERSIST":
+			if expire.set {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			persist = true
+			args = args[1:]
+		case "EX", "PX", "EXAT", "PXAT":
+			if len(args) < 2 || expire.set || persist {
+				setDirty(c)
+				c.WriteError(msgSyntaxError)
+				return
+			}
+			e, msg := parseExpireOpt(cmd, opt, args[1])
+			if msg != "" {
+				setDirty(c)
+				c.WriteError(msg)
+				return
+			}
+			expire = e
+			args = args[2:]
+		default:
+			setDirty(c)
+			c.WriteError(msgSyntaxError)
+			return
+		}
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if !db.exists(key) {
+			c.WriteNull()
+			return
+		}
+		if db.t(key) != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v := db.stringGet(key)
+		switch {
+		case persist:
+			if _, ok := db.ttl[key]; ok {
+				delete(db.ttl, key)
+				db.keyVersion[key]++
+			}
+		case expire.set:
+			db.setTTL(key, expire.ttl(m))
+		}
+		c.WriteBulk(v)
+	})
+}
+
+// MGET
+func (m *ShinyRedis) cmdMget(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		c.Block(func(w *server.Writer) {
+			w.WriteLen(len(args))
+			for _, k := range args {
+				if t, ok := db.keys[k]; !ok || t != "string" {
+					w.WriteNull()
+					continue
+				}
+				w.WriteBulk(db.stringGet(k))
+			}
+		})
+	})
+}
+
+// APPEND
+func (m *ShinyRedis) cmdAppend(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, value := args[0], args[1]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		newValue := db.stringKeys[key] + value
+		if len(newValue) > maxStringLen {
+			c.WriteError(msgStringTooLong)
+			return
+		}
+		db.stringSet(key, newValue)
+
+		c.WriteInt(len(newValue))
+	})
+}
+
+// STRLEN
+func (m *ShinyRedis) cmdStrlen(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		c.WriteInt(len(db.stringKeys[key]))
+	})
+}
+
+// GETRANGE
+func (m *ShinyRedis) cmdGetrange(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	start, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	end, err := strconv.Atoi(args[2])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v := db.stringKeys[key]
+		rs, re := redisRange(len(v), start, end, true)
+		c.WriteBulk(v[rs:re])
+	})
+}
+
+// SETRANGE
+func (m *ShinyRedis) cmdSetrange(c *server.Peer, cmd string, args []string) {
+	if len(args) != 3 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key, subst := args[0], args[2]
+	pos, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if pos < 0 {
+		setDirty(c)
+		c.WriteError("ERR offset is out of range")
+		return
+	}
+	// no pos+len(subst), that overflows with a huge offset
+	if len(subst) > 0 && pos > maxStringLen-len(subst) {
+		setDirty(c)
+		c.WriteError(msgStringTooLong)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v := []byte(db.stringKeys[key])
+		if len(subst) == 0 {
+			// nothing to set, and a missing key isn't created
+			c.WriteInt(len(v))
+			return
+		}
+		if end := pos + len(subst); len(v) < end {
+			v = append(v, make([]byte, end-len(v))...)
+		}
+		copy(v[pos:], subst)
+		db.stringSet(key, string(v))
+		c.WriteInt(len(v))
+	})
+}
+
+// INCR
+func (m *ShinyRedis) cmdIncr(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	m.cmdXincr(c, args[0], 1)
+}
+
+// DECR
+func (m *ShinyRedis) cmdDecr(c *server.Peer, cmd string, args []string) {
+	if len(args) != 1 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	m.cmdXincr(c, args[0], -1)
+}
+
+// INCRBY
+func (m *ShinyRedis) cmdIncrby(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	delta, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	m.cmdXincr(c, args[0], delta)
+}
+
+// DECRBY
+func (m *ShinyRedis) cmdDecrby(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	delta, err := strconv.Atoi(args[1])
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidInt)
+		return
+	}
+	if delta == math.MinInt64 {
+		setDirty(c)
+		c.WriteError("ERR decrement would overflow")
+		return
+	}
+	m.cmdXincr(c, args[0], -delta)
+}
+
+// cmdXincr is the shared part of INCR, DECR, INCRBY, and DECRBY, after the
+// arguments are checked.
+func (m *ShinyRedis) cmdXincr(c *server.Peer, key string, delta int) {
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v, err := db.stringIncr(key, delta)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteInt(v)
+	})
+}
+
+// INCRBYFLOAT
+func (m *ShinyRedis) cmdIncrbyfloat(c *server.Peer, cmd string, args []string) {
+	if len(args) != 2 {
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
+		return
+	}
+
+	key := args[0]
+	delta, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		setDirty(c)
+		c.WriteError(msgInvalidFloat)
+		return
+	}
+
+	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
+		db := m.db(ctx.selectedDB)
+
+		if t, ok := db.keys[key]; ok && t != "string" {
+			c.WriteError(msgWrongType)
+			return
+		}
+
+		v, err := db.stringIncrfloat(key, delta)
+		if err != nil {
+			c.WriteError(err.Error())
+			return
+		}
+		c.WriteBulk(formatIncrFloat(v))
+	})
+}
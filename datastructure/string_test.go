@@ -0,0 +1,128 @@
+package datastructure
+
+import (
+	"testing"
+	"time"
+)
+
+// mustTTL compares the TTL of a key in the first database, 0 is no TTL.
+func mustTTL(t *testing.T, m *ShinyRedis, key string, want time.Duration) {
+	t.Helper()
+	m.Lock()
+	defer m.Unlock()
+	if got := m.db(0).ttl[key]; got != want {
+		t.Errorf("TTL of %q: got %v, want %v", key, got, want)
+	}
+}
+
+func TestSetrangeOverflow(t *testing.T) {
+	_, c := start(t)
+	c.must("-"+msgStringTooLong, "SETRANGE", "k", "9223372036854775807", "ab")
+	c.must("-"+msgStringTooLong, "SETRANGE", "k", "536870911", "ab")
+	c.must(":0", "SETRANGE", "k", "9223372036854775807", "")
+	c.must("nil", "GET", "k")
+	c.must(":3", "SETRANGE", "k", "1", "ab")
+	c.must(`$"\x00ab"`, "GET", "k")
+	c.must("+PONG", "PING")
+}
+
+func TestSet(t *testing.T) {
+	m, c := start(t)
+	c.must("+OK", "SET", "a", "1")
+	c.must(`$"1"`, "GET", "a")
+	c.must(`nil`, "GET", "nosuch")
+	c.must(`nil`, "SET", "a", "2", "NX")
+	c.must(`$"1"`, "SET", "a", "2", "GET")
+	c.must(`nil`, "SET", "b", "2", "XX", "GET")
+	c.must("nil", "GET", "b")
+	c.must("-ERR syntax error", "SET", "b", "2", "XX", "NX")
+	c.must("-ERR syntax error", "SET", "b", "2", "EX", "1", "PX", "3")
+	c.must("-ERR invalid expire time in 'set' command", "SET", "b", "2", "EX", "0")
+	c.must("-"+msgInvalidInt, "SET", "b", "2", "EX", "a")
+	c.must("+OK", "SET", "b", "2", "EX", "100")
+	mustTTL(t, m, "b", 100*time.Second)
+	c.must("+OK", "SET", "b", "3", "KEEPTTL")
+	mustTTL(t, m, "b", 100*time.Second)
+	c.must("+OK", "SET", "b", "3")
+	mustTTL(t, m, "b", 0)
+	c.must("+OK", "SET", "b", "3", "EXAT", "1000")
+	c.must(`nil`, "GET", "b")
+
+	c.must(":1", "SETNX", "n", "x")
+	c.must(":0", "SETNX", "n", "x")
+	c.must("+OK", "SETEX", "e", "10", "x")
+	mustTTL(t, m, "e", 10*time.Second)
+	c.must("-ERR invalid expire time in 'psetex' command", "PSETEX", "e", "0", "x")
+	c.must(`$"x"`, "GETSET", "e", "new")
+	mustTTL(t, m, "e", 0)
+	c.must(`$"new"`, "GETDEL", "e")
+	c.must(`nil`, "GETDEL", "e")
+	c.must("+OK", "SET", "g", "v")
+	c.must(`$"v"`, "GETEX", "g", "PX", "5000")
+	mustTTL(t, m, "g", 5*time.Second)
+	c.must(`$"v"`, "GETEX", "g", "PERSIST")
+	mustTTL(t, m, "g", 0)
+
+	c.must(":1", "LPUSH", "l", "x")
+	c.must("-"+msgWrongType, "GET", "l")
+	c.must("+OK", "SET", "l", "x")
+	c.must(`$"x"`, "GET", "l")
+}
+
+func TestMset(t *testing.T) {
+	_, c := start(t)
+	c.must("+OK", "MSET", "k1", "v1", "k2", "v2")
+	c.must("-ERR wrong number of arguments for 'mset' command", "MSET", "k1", "v1", "k2")
+	c.must(":1", "RPUSH", "l", "x")
+	c.must(`*[$"v1" nil $"v2" nil]`, "MGET", "k1", "nosuch", "k2", "l")
+	c.must(":0", "MSETNX", "k1", "v1", "k3", "v2")
+	c.must("nil", "GET", "k3")
+	c.must(":1", "MSETNX", "k4", "v1", "k3", "v2")
+	c.must(`*[$"v1" $"v2"]`, "MGET", "k4", "k3")
+}
+
+func TestStringRange(t *testing.T) {
+	_, c := start(t)
+	c.must(":5", "APPEND", "s", "hello")
+	c.must(":11", "APPEND", "s", " world")
+	c.must(":11", "STRLEN", "s")
+	c.must(":0", "STRLEN", "nosuch")
+	c.must(`$"hell"`, "GETRANGE", "s", "0", "3")
+	c.must(`$"world"`, "GETRANGE", "s", "-5", "-1")
+	c.must(`$"h"`, "GETRANGE", "s", "0", "-100")
+	c.must(`$""`, "GETRANGE", "s", "-1", "-5")
+	c.must(`$""`, "GETRANGE", "nosuch", "0", "-1")
+	c.must(":11", "SETRANGE", "s", "6", "WORLD")
+	c.must(`$"hello WORLD"`, "GET", "s")
+	c.must(":8", "SETRANGE", "z", "5", "abc")
+	c.must(`$"\x00\x00\x00\x00\x00abc"`, "GET", "z")
+	c.must(":0", "SETRANGE", "zz", "5", "")
+	c.must("nil", "GET", "zz")
+	c.must("-ERR offset is out of range", "SETRANGE", "s", "-1", "x")
+}
+
+func TestIncr(t *testing.T) {
+	_, c := start(t)
+	c.must(":1", "INCR", "i")
+	c.must(":11", "INCRBY", "i", "10")
+	c.must(":10", "DECR", "i")
+	c.must(":0", "DECRBY", "i", "10")
+	c.must("-"+msgInvalidInt, "INCRBY", "i", "x")
+	c.must("+OK", "SET", "s", "foo")
+	c.must("-"+msgInvalidInt, "INCR", "s")
+	c.must("+OK", "SET", "i", "9223372036854775807")
+	c.must("-ERR increment or decrement would overflow", "INCR", "i")
+	c.must(`$"9223372036854775807"`, "GET", "i")
+
+	c.must(`$"10.5"`, "INCRBYFLOAT", "f", "10.5")
+	c.must(`$"10.6"`, "INCRBYFLOAT", "f", "0.1")
+	c.must(`$"3"`, "INCRBYFLOAT", "f", "-7.6")
+	c.must("-"+msgInvalidFloat, "INCRBYFLOAT", "s", "0.1")
+	// no exponent here, unlike scores
+	c.must(`$"1000000000000000000000"`, "INCRBYFLOAT", "big", "1e21")
+
+	c.must("+OK", "MULTI")
+	c.must("+QUEUED", "SET", "t", "1")
+	c.must("+QUEUED", "INCR", "t")
+	c.must(`*[+OK :2]`, "EXEC")
+}
@@ -2,9 +2,14 @@ package datastructure
 
 import (
 	"regexp"
+	"sort"
 	"sync"
 )
 
+// subscriberBuffer is how many messages can be queued for a subscriber. A
+// subscriber which falls further behind is disconnected, see send().
+const subscriberBuffer = 1024
+
 type PubsubMessage struct {
 	Channel string
 	Message string
@@ -16,11 +21,201 @@ type PubsubPmessage struct {
 	Message string
 }
 
-// Subscriber has the (p)subscriptions.
+// PubsubSmessage is a message on a shard channel.
+type PubsubSmessage struct {
+	Channel string
+	Message string
+}
+
+// Subscriber has the (p)subscriptions, and the sharded subscriptions, which
+// are kept apart.
 type Subscriber struct {
-	publish  chan PubsubMessage
-	ppublish chan PubsubPmessage
-	channels map[string]struct{}
-	patterns map[string]*regexp.Regexp
-	mu       sync.Mutex
+	messages      chan interface{} // PubsubMessage, PubsubPmessage, or PubsubSmessage
+	channels      map[string]struct{}
+	patterns      map[string]*regexp.Regexp
+	shardChannels map[string]struct{}
+	onFull        func() // called when a message doesn't fit in the buffer
+	mu            sync.Mutex
+}
+
+// newSubscriber makes a new subscriber. Someone needs to keep reading the
+// messages channel, see subscribedState(). All messages go through the one
+// channel, so they arrive in the order they were published. Use Close() when
+// done.
+func newSubscriber() *Subscriber {
+	return &Subscriber{
+		messages:      make(chan interface{}, subscriberBuffer),
+		channels:      map[string]struct{}{},
+		patterns:      map[string]*regexp.Regexp{},
+		shardChannels: map[string]struct{}{},
+	}
+}
+
+// Close the messages channel
+func (s *Subscriber) Close() {
+	close(s.messages)
+}
+
+// Count the total number of channels, patterns, and shard channels. The
+// client is in subscribed mode as long as this is not 0.
+func (s *Subscriber) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count() + len(s.shardChannels)
+}
+
+func (s *Subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+// Subscribe to a channel. Returns the total number of (p)subscriptions after
+// subscribing.
+func (s *Subscriber) Subscribe(c string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.channels[c] = struct{}{}
+	return s.count()
+}
+
+// Unsubscribe a channel. Returns the total number of (p)subscriptions after
+// unsubscribing.
+func (s *Subscriber) Unsubscribe(c string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.channels, c)
+	return s.count()
+}
+
+// Psubscribe subscribes to a channel pattern. Returns the total number of
+// (p)subscriptions after subscribing.
+func (s *Subscriber) Psubscribe(pat string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.patterns[pat] = patternRE(pat)
+	return s.count()
+}
+
+// Punsubscribe unsubscribes a channel pattern. Returns the total number of
+// (p)subscriptions after unsubscribing.
+func (s *Subscriber) Punsubscribe(pat string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.patterns, pat)
+	return s.count()
+}
+
+// Ssubscribe subscribes to a shard channel. Returns the number of shard
+// subscriptions after subscribing.
+func (s *Subscriber) Ssubscribe(c string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shardChannels[c] = struct{}{}
+	return len(s.shardChannels)
+}
+
+// Sunsubscribe unsubscribes a shard channel. Returns the number of shard
+// subscriptions after unsubscribing.
+func (s *Subscriber) Sunsubscribe(c string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.shardChannels, c)
+	return len(s.shardChannels)
+}
+
+// Channels gives the subscribed channels, sorted.
+func (s *Subscriber) Channels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cs []string
+	for c := range s.channels {
+		cs = append(cs, c)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// Patterns gives the subscribed patterns, sorted.
+func (s *Subscriber) Patterns() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ps []string
+	for p := range s.patterns {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// ShardChannels gives the subscribed shard channels, sorted.
+func (s *Subscriber) ShardChannels() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cs []string
+	for c := range s.shardChannels {
+		cs = append(cs, c)
+	}
+	sort.Strings(cs)
+	return cs
+}
+
+// Publish a message to everything this subscriber listens to, directly or by
+// pattern. Returns the number of matches.
+func (s *Subscriber) Publish(c, msg string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := 0
+	if _, ok := s.channels[c]; ok {
+		s.send(PubsubMessage{c, msg})
+		found++
+	}
+
+	var pats []string
+	for pat, re := range s.patterns {
+		if re != nil && re.MatchString(c) {
+			pats = append(pats, pat)
+		}
+	}
+	sort.Strings(pats)
+	for _, pat := range pats {
+		s.send(PubsubPmessage{pat, c, msg})
+		found++
+	}
+	return found
+}
+
+// send queues a message without waiting, since PUBLISH runs with the lock.
+// If the buffer is full the client can't keep up, and the message is dropped
+// and onFull is called. Redis disconnects such a client as well, when it
+// hits the pubsub output buffer limit. Needs s.mu.
+func (s *Subscriber) send(msg interface{}) {
+	select {
+	case s.messages <- msg:
+	default:
+		if s.onFull != nil {
+			s.onFull()
+		}
+	}
+}
+
+// Spublish a message to a shard channel. Patterns don't match shard channels.
+// Returns the number of matches.
+func (s *Subscriber) Spublish(c, msg string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.shardChannels[c]; !ok {
+		return 0
+	}
+	s.send(PubsubSmessage{c, msg})
+	return 1
 }
@@ -4,31 +4,63 @@ import "shiny_redis/server"
 
 // commandsTransaction handles MULTI &c.
 func commandsTransaction(m *ShinyRedis) {
-	m.srv.Register("DISCARD", m.cmdDiscard)
-	m.srv.Register("EXEC", m.cmdExec)
-	m.srv.Register("MULTI", m.cmdMulti)
-	m.srv.Register("UNWATCH", m.cmdUnwatch)
-	//m.srv.Register("WATCH", m.cmdWatch)
+	m.srv.Register("DISCARD", m.cmdDiscard, 1, "noscript loading stale fast", 0, 0, 0)
+	m.srv.Register("EXEC", m.cmdExec, 1, "noscript loading stale", 0, 0, 0)
+	m.srv.Register("MULTI", m.cmdMulti, 1, "noscript loading stale fast", 0, 0, 0)
+	m.srv.Register("UNWATCH", m.cmdUnwatch, 1, "noscript loading stale fast", 0, 0, 0)
+	m.srv.Register("WATCH", m.cmdWatch, -2, "noscript loading stale fast", 1, -1, 1)
 }
 
 // DISCARD
 func (m *ShinyRedis) cmdDiscard(c *server.Peer, cmd string, args []string) {
 	if len(args) != 0 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkPusbsub
 	ctx := getCtx(c)
 	if !inTx(ctx) {
 		c.WriteError("ERR DISCARD without MULTI")
 		return
 	}
 
+	m.Lock()
 	stopTx(ctx)
-	//c.WriteOK()
+	m.Unlock()
+	c.WriteOK()
+}
+
+// setDirty marks the transaction as failed, so EXEC will abort. It's a no-op
+// when not in a transaction.
+func setDirty(c *server.Peer) {
+	if c.Ctx == nil {
+		// No transaction. Not relevant.
+		return
+	}
+	if ctx := getCtx(c); inTx(ctx) {
+		ctx.dirtyTransaction = true
+	}
 }
+
+// dirtyOnReject gives a pre hook which marks the transaction dirty if the
+// dispatcher is going to reject the command: it's unknown, or it has the
+// wrong number of arguments.
+func dirtyOnReject(s *server.Server) server.Callback {
+	return func(c *server.Peer, cmd string, args ...string) bool {
+		spec, ok := s.Command(cmd)
+		if !ok || !spec.ArityOK(len(args)+1) {
+			setDirty(c)
+		}
+		return false
+	}
+}
+
 func inTx(ctx *connCtx) bool {
 	return ctx.transaction != nil
 }
@@ -56,16 +88,20 @@ func watch(db *RedisDB, ctx *connCtx, key string) {
 // EXEC
 func (m *ShinyRedis) cmdExec(c *server.Peer, cmd string, args []string) {
 	if len(args) != 0 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkPusbsub
 
 	ctx := getCtx(c)
 	if ctx.nested {
-		c.WriteError("msgNotFromScripts")
+		c.WriteError(msgNotFromScripts)
 		return
 	}
 	if !inTx(ctx) {
@@ -73,6 +109,9 @@ func (m *ShinyRedis) cmdExec(c *server.Peer, cmd string, args []string) {
 		return
 	}
 
+	m.Lock()
+	defer m.Unlock()
+
 	if ctx.dirtyTransaction {
 		c.WriteError("EXECABORT Transaction discarded because of previous errors.")
 		// a failed EXEC finishes the tx
@@ -80,15 +119,12 @@ func (m *ShinyRedis) cmdExec(c *server.Peer, cmd string, args []string) {
 		return
 	}
 
-	m.Lock()
-	defer m.Unlock()
-
 	// Check WATCHed keys.
 	for t, version := range ctx.watch {
 		if m.db(t.db).keyVersion[t.key] > version {
 			// Abort! Abort!
 			stopTx(ctx)
-			//c.WriteNull()
+			c.WriteNullArray()
 			return
 		}
 	}
@@ -97,8 +133,8 @@ func (m *ShinyRedis) cmdExec(c *server.Peer, cmd string, args []string) {
 	for _, fn := range ctx.transaction {
 		fn(c, ctx)
 	}
-	// wake up anyone who waits on anything.
-	m.signal.Broadcast()
+	// serve the clients waiting on the keys we changed.
+	m.serveBlocked()
 
 	stopTx(ctx)
 }
@@ -106,15 +142,19 @@ func (m *ShinyRedis) cmdExec(c *server.Peer, cmd string, args []string) {
 // MULTI
 func (m *ShinyRedis) cmdMulti(c *server.Peer, cmd string, args []string) {
 	if len(args) != 0 {
-		c.WriteError("errWrongNumber(cmd)")
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkPusbsub
 
 	ctx := getCtx(c)
 	if ctx.nested {
-		c.WriteError("msgNotFromScripts")
+		c.WriteError(msgNotFromScripts)
 		return
 	}
 	if inTx(ctx) {
@@ -122,47 +162,57 @@ func (m *ShinyRedis) cmdMulti(c *server.Peer, cmd string, args []string) {
 		return
 	}
 
+	m.Lock()
 	startTx(ctx)
+	m.Unlock()
 
-	//c.WriteOK()
+	c.WriteOK()
 }
 
 // UNWATCH
 func (m *ShinyRedis) cmdUnwatch(c *server.Peer, cmd string, args []string) {
 	if len(args) != 0 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkPusbsub
 
 	// Doesn't matter if UNWATCH is in a TX or not. Looks like a Redis bug to me.
 	unwatch(getCtx(c))
 
 	withTx(m, c, func(c *server.Peer, ctx *connCtx) {
 		// Do nothing if it's called in a transaction.
-		//c.WriteOK()
+		c.WriteOK()
 	})
 }
 
 // WATCH
 func (m *ShinyRedis) cmdWatch(c *server.Peer, cmd string, args []string) {
 	if len(args) == 0 {
-		//setDirty(c)
-		c.WriteError("errWrongNumber(cmd)")
+		setDirty(c)
+		c.WriteError(errWrongNumber(cmd))
+		return
+	}
+	if !m.handleAuth(c) {
+		return
+	}
+	if m.checkPubsub(c, cmd) {
 		return
 	}
-	//handleAuth
-	//checkPusbsub
 
 	ctx := getCtx(c)
 	if ctx.nested {
-		c.WriteError("msgNotFromScripts")
+		c.WriteError(msgNotFromScripts)
 		return
 	}
 	if inTx(ctx) {
-		c.WriteError("ERR WATCH in MULTI")
+		c.WriteError("ERR WATCH inside MULTI is not allowed")
 		return
 	}
 
@@ -173,5 +223,5 @@ func (m *ShinyRedis) cmdWatch(c *server.Peer, cmd string, args []string) {
 	for _, key := range args {
 		watch(db, ctx, key)
 	}
-	//c.WriteOK()
+	c.WriteOK()
 }
@@ -0,0 +1,123 @@
+package datastructure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMulti(t *testing.T) {
+	_, c := start(t)
+	c.must(`-ERR EXEC without MULTI`, "EXEC")
+	c.must(`-ERR DISCARD without MULTI`, "DISCARD")
+	c.must(`+OK`, "MULTI")
+	c.must(`-ERR MULTI calls can not be nested`, "MULTI")
+	c.must(`+QUEUED`, "SET", "a", "1")
+	c.must(`+QUEUED`, "INCR", "a")
+	c.must(`+QUEUED`, "GET", "a")
+	c.must(`*[+OK :2 $"2"]`, "EXEC")
+
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "SET", "a", "3")
+	c.must(`+OK`, "DISCARD")
+	c.must(`$"2"`, "GET", "a")
+
+	c.must(`+OK`, "MULTI")
+	c.must(`*[]`, "EXEC")
+}
+
+func TestMultiDirty(t *testing.T) {
+	_, c := start(t)
+
+	// argument errors, unknown commands, and a wrong number of arguments
+	// all abort the EXEC
+	for _, args := range [][]string{
+		{"INCRBY", "a", "x"},
+		{"NOSUCH"},
+		{"GET"},
+		{"SET", "a", "1", "EX", "x"},
+		{"HELLO", "4"},
+	} {
+		c.must(`+OK`, "MULTI")
+		c.must(`+QUEUED`, "SET", "a", "1")
+		if got := c.do(args...); got[0] != '-' {
+			t.Errorf("%q: got %q", args, got)
+		}
+		c.must(`-EXECABORT Transaction discarded because of previous errors.`, "EXEC")
+		c.must(`nil`, "GET", "a")
+		c.must(`-ERR EXEC without MULTI`, "EXEC")
+	}
+}
+
+func TestMultiRuntimeErrors(t *testing.T) {
+	_, c := start(t)
+	c.must(`+OK`, "SET", "str", "x")
+
+	// errors while running go in their slot, the rest still runs
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "LPUSH", "str", "a")
+	c.must(`+QUEUED`, "INCR", "str")
+	c.must(`+QUEUED`, "SET", "b", "1")
+	c.must(`*[-`+msgWrongType+` -`+msgInvalidInt+` +OK]`, "EXEC")
+	c.must(`$"1"`, "GET", "b")
+}
+
+func TestWatch(t *testing.T) {
+	m, c := start(t)
+	c2 := dial(t, m)
+
+	c.must(`+OK`, "WATCH", "a", "b")
+	c.must(`+OK`, "MULTI")
+	c.must(`-ERR WATCH inside MULTI is not allowed`, "WATCH", "c")
+	c.must(`+QUEUED`, "SET", "a", "1")
+	c.must(`*[+OK]`, "EXEC")
+
+	// a change by someone else aborts
+	c.must(`+OK`, "WATCH", "a")
+	c2.must(`+OK`, "SET", "a", "2")
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "SET", "a", "3")
+	c.must(`nilarr`, "EXEC")
+	c.must(`$"2"`, "GET", "a")
+
+	// EXEC and DISCARD both unwatch
+	c.must(`+OK`, "WATCH", "a")
+	c.must(`+OK`, "MULTI")
+	c.must(`+OK`, "DISCARD")
+	c2.must(`+OK`, "SET", "a", "4")
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "GET", "a")
+	c.must(`*[$"4"]`, "EXEC")
+
+	// so does UNWATCH
+	c.must(`+OK`, "WATCH", "a")
+	c.must(`+OK`, "UNWATCH")
+	c2.must(`+OK`, "SET", "a", "5")
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "GET", "a")
+	c.must(`*[$"5"]`, "EXEC")
+
+	// deleting a key which doesn't exist is not a change
+	c.must(`+OK`, "WATCH", "nosuch")
+	c2.must(`:0`, "DEL", "nosuch")
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "SET", "x", "1")
+	c.must(`*[+OK]`, "EXEC")
+
+	// a watch is per database
+	c.must(`+OK`, "WATCH", "a")
+	c2.must(`+OK`, "SELECT", "1")
+	c2.must(`+OK`, "SET", "a", "other db")
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "GET", "a")
+	c.must(`*[$"5"]`, "EXEC")
+
+	// a key which expires is
+	c.must(`+OK`, "SET", "e", "1", "EX", "10")
+	c.must(`+OK`, "WATCH", "e")
+	m.FastForward(10 * time.Second)
+	c.must(`+OK`, "MULTI")
+	c.must(`+QUEUED`, "SET", "x", "2")
+	c.must(`nilarr`, "EXEC")
+
+	c.must(`-ERR wrong number of arguments for 'watch' command`, "WATCH")
+}
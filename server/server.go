@@ -2,12 +2,18 @@ package server
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/tls"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"shiny_redis/parser"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
@@ -15,26 +21,128 @@ import (
 type Callback func(*Peer, string, ...string) bool
 type Cmd func(c *Peer, cmd string, args []string)
 
+// Command flags, as used by Register() and reported by COMMAND.
+var knownFlags = map[string]bool{
+	"write":       true, // may modify the dataset
+	"readonly":    true, // only reads the dataset
+	"denyoom":     true, // may grow the dataset
+	"admin":       true, // administrative command
+	"pubsub":      true, // pub/sub related, allowed in subscribed mode
+	"noscript":    true, // not allowed from scripts
+	"random":      true, // output isn't deterministic
+	"loading":     true, // allowed while loading the dataset
+	"stale":       true, // allowed on a replica with stale data
+	"fast":        true, // O(1) or O(log(N))
+	"blocking":    true, // may block the client
+	"movablekeys": true, // key positions can't be given by first/last/step
+}
+
+// CmdSpec is the metadata of a registered command, as reported by COMMAND.
+type CmdSpec struct {
+	Name     string   // lowercase command name
+	Arity    int      // number of arguments including the command name. Negative means "at least -Arity".
+	Flags    []string // see knownFlags
+	FirstKey int      // position of the first key, 0 if there are no keys
+	LastKey  int      // position of the last key, negative counts from the end
+	Step     int      // distance between keys
+}
+
+// HasFlag tells whether the command has the given flag.
+func (cs CmdSpec) HasFlag(flag string) bool {
+	for _, f := range cs.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// ArityOK tells whether a call with argc arguments, including the command
+// name, is allowed.
+func (cs CmdSpec) ArityOK(argc int) bool {
+	if cs.Arity < 0 {
+		return argc >= -cs.Arity
+	}
+	return argc == cs.Arity
+}
+
+// Keys gives the keys in a command call. args don't include the command
+// name. Commands with "movablekeys" can't be handled, ok will be false.
+func (cs CmdSpec) Keys(args []string) (keys []string, ok bool) {
+	if cs.HasFlag("movablekeys") {
+		return nil, false
+	}
+	if cs.FirstKey == 0 {
+		return nil, true
+	}
+	last := cs.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	for i := cs.FirstKey; i <= last && i <= len(args); i += cs.Step {
+		keys = append(keys, args[i-1])
+	}
+	return keys, true
+}
+
+type command struct {
+	fn   Cmd
+	spec CmdSpec
+}
+
+// ReplyMode is set by CLIENT REPLY.
+type ReplyMode int
+
+const (
+	ReplyOn   ReplyMode = iota // normal replies
+	ReplyOff                   // no replies at all
+	ReplySkip                  // no reply for the next command
+)
+
 //client
 type Peer struct {
 	writer    *bufio.Writer
+	conn      net.Conn
 	closed    bool
-	Resp3     bool
-	Ctx       interface{} // anything goes, server won't touch this
-	DisconnCB []func()    // list of callbacks
-	mu        sync.Mutex  // for Block()
+	id        int
+	created   time.Time
+	lastCmd   string    // lowercase name of the last known command
+	lastTime  time.Time // last command
+	name      string    // set by HELLO SETNAME
+	replyMode ReplyMode
+	silent    bool          // replies of the current command are dropped
+	resp3     bool          // set by HELLO
+	busy      bool          // a command is running
+	pending   bytes.Buffer  // pushes held back until the command is done
+	errs      []string      // prefixes of the errors written by the current command
+	Ctx       interface{}   // anything goes, server won't touch this
+	DisconnCB []func()      // list of callbacks
+	gone      chan struct{} // closed when the connection is gone
+	mu        sync.Mutex    // for Block()
 }
 
 //server
 type Server struct {
 	listener  net.Listener
-	cmds      map[string]Cmd
+	cmds      map[string]*command
 	preHook   Callback
-	peers     map[net.Conn]struct{}
+	peers     map[net.Conn]*Peer
+	nextID    int
 	mu        sync.Mutex
 	wg        sync.WaitGroup
 	infoConns int
 	CmdCnt    int
+	started   time.Time
+	cmdStats  map[string]*CmdStats // by lowercase command name
+	errStats  map[string]int       // by error prefix
+}
+
+// CmdStats are the statistics of a single command, for INFO commandstats.
+type CmdStats struct {
+	Calls         int
+	Usec          int // total time spent in the command
+	RejectedCalls int // rejected before it ran, such as a wrong number of arguments
+	FailedCalls   int // replied with an error
 }
 
 // NewServer makes a server listening on addr. Close with .Close().
@@ -56,9 +164,12 @@ func NewServerTLS(addr string, cfg *tls.Config) (*Server, error) {
 
 func newServer(l net.Listener) *Server {
 	s := Server{
-		cmds:     map[string]Cmd{},
-		peers:    map[net.Conn]struct{}{},
+		cmds:     map[string]*command{},
+		peers:    map[net.Conn]*Peer{},
 		listener: l,
+		started:  time.Now(),
+		cmdStats: map[string]*CmdStats{},
+		errStats: map[string]int{},
 	}
 
 	s.wg.Add(1)
@@ -78,6 +189,30 @@ func (s *Server) close() {
 	s.mu.Unlock()
 }
 
+// Close a server started with NewServer. It will wait until all clients are
+// closed.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for c := range s.peers {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Addr has the net.Addr struct
+func (s *Server) Addr() *net.TCPAddr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr().(*net.TCPAddr)
+}
+
 func (s *Server) serve(l net.Listener) {
 	for {
 		conn, err := l.Accept()
@@ -92,7 +227,9 @@ func (s *Server) serve(l net.Listener) {
 func (s *Server) ServeConn(conn net.Conn) {
 	s.wg.Add(1)
 	s.mu.Lock()
-	s.peers[conn] = struct{}{}
+	s.nextID++
+	peer := newPeer(conn, s.nextID)
+	s.peers[conn] = peer
 	s.infoConns++
 	s.mu.Unlock()
 
@@ -100,7 +237,7 @@ func (s *Server) ServeConn(conn net.Conn) {
 		defer s.wg.Done()
 		defer conn.Close()
 
-		s.servePeer(conn)
+		s.servePeer(conn, peer)
 
 		s.mu.Lock()
 		delete(s.peers, conn)
@@ -108,22 +245,42 @@ func (s *Server) ServeConn(conn net.Conn) {
 	}()
 }
 
-func (s *Server) servePeer(c net.Conn) {
-	r := bufio.NewReader(c)
-	peer := &Peer{
-		writer: bufio.NewWriter(c),
+func newPeer(c net.Conn, id int) *Peer {
+	now := time.Now()
+	return &Peer{
+		writer:   bufio.NewWriter(c),
+		conn:     c,
+		id:       id,
+		created:  now,
+		lastTime: now,
+		gone:     make(chan struct{}),
 	}
+}
+
+func (s *Server) servePeer(c net.Conn, peer *Peer) {
 	defer func() {
 		for _, f := range peer.DisconnCB {
 			f()
 		}
 	}()
 
-	for {
-		args, err := parser.ReadArray(r)
-		if err != nil {
-			return
+	// Commands are read by their own goroutine, so a client which goes away
+	// is noticed while a command blocks. See Gone().
+	cmds := make(chan []string)
+	go func() {
+		defer close(peer.gone)
+		defer close(cmds)
+		r := bufio.NewReader(c)
+		for {
+			args, err := parser.ReadArray(r)
+			if err != nil {
+				return
+			}
+			cmds <- args
 		}
+	}()
+
+	for args := range cmds {
 		s.Dispatch(peer, args)
 		peer.Flush()
 
@@ -139,6 +296,19 @@ func (s *Server) servePeer(c net.Conn) {
 func (s *Server) Dispatch(c *Peer, args []string) {
 	cmd, args := args[0], args[1:]
 	cmdUp := strings.ToUpper(cmd)
+
+	// CLIENT REPLY, for everything this command writes
+	c.mu.Lock()
+	c.silent = c.replyMode != ReplyOn
+	if c.replyMode == ReplySkip {
+		c.replyMode = ReplyOn
+	}
+	c.errs = nil
+	c.busy = true
+	c.mu.Unlock()
+	defer c.done()
+	defer s.countErrors(c)
+
 	s.mu.Lock()
 	fn := s.preHook
 	s.mu.Unlock()
@@ -152,14 +322,124 @@ func (s *Server) Dispatch(c *Peer, args []string) {
 	cb, ok := s.cmds[cmdUp]
 	s.mu.Unlock()
 	if !ok {
-		//todo
+		c.WriteError(errUnknownCommand(cmd, args))
+		return
+	}
+	if !cb.spec.ArityOK(len(args) + 1) {
+		c.WriteError(errWrongNumber(cmd))
+		s.mu.Lock()
+		s.stats(cb.spec.Name).RejectedCalls++
+		s.mu.Unlock()
 		return
 	}
 
 	s.mu.Lock()
 	s.CmdCnt++
 	s.mu.Unlock()
-	cb(c, cmdUp, args)
+	start := time.Now()
+	c.mu.Lock()
+	c.lastCmd = cb.spec.Name
+	c.lastTime = start
+	c.mu.Unlock()
+
+	cb.fn(c, cmdUp, args)
+
+	c.mu.Lock()
+	failed := len(c.errs) > 0
+	c.mu.Unlock()
+	s.mu.Lock()
+	st := s.stats(cb.spec.Name)
+	st.Calls++
+	st.Usec += int(time.Since(start).Microseconds())
+	if failed {
+		st.FailedCalls++
+	}
+	s.mu.Unlock()
+}
+
+// stats gives the stats of a command, creating them if needed. Needs s.mu.
+func (s *Server) stats(name string) *CmdStats {
+	st, ok := s.cmdStats[name]
+	if !ok {
+		st = &CmdStats{}
+		s.cmdStats[name] = st
+	}
+	return st
+}
+
+// countErrors adds the errors the peer got during the last command to the
+// error stats.
+func (s *Server) countErrors(c *Peer) {
+	c.mu.Lock()
+	errs := c.errs
+	c.errs = nil
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range errs {
+		s.errStats[e]++
+	}
+}
+
+// CommandStats gives the stats of every command which was called at least
+// once, by lowercase name.
+func (s *Server) CommandStats() map[string]CmdStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := map[string]CmdStats{}
+	for name, st := range s.cmdStats {
+		res[name] = *st
+	}
+	return res
+}
+
+// ErrorStats gives the number of error replies, by error prefix ("ERR",
+// "WRONGTYPE", ...).
+func (s *Server) ErrorStats() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := map[string]int{}
+	for e, n := range s.errStats {
+		res[e] = n
+	}
+	return res
+}
+
+// TotalConnections gives the number of connections ever accepted.
+func (s *Server) TotalConnections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.infoConns
+}
+
+// Started gives the time the server started.
+func (s *Server) Started() time.Time {
+	return s.started
+}
+
+// errUnknownCommand is what redis replies for a command it doesn't know. The
+// arguments are quoted and truncated, same as redis does.
+func errUnknownCommand(cmd string, args []string) string {
+	var a strings.Builder
+	for _, arg := range args {
+		if a.Len() >= 128 {
+			break
+		}
+		if n := 128 - a.Len(); len(arg) > n {
+			arg = arg[:n]
+		}
+		fmt.Fprintf(&a, "'%s' ", arg)
+	}
+	if len(cmd) > 128 {
+		cmd = cmd[:128]
+	}
+	return fmt.Sprintf("ERR unknown command '%s', with args beginning with: %s", cmd, a.String())
+}
+
+// errWrongNumber is the error for a call with the wrong number of arguments.
+func errWrongNumber(cmd string) string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
 }
 
 func (c *Peer) Flush() {
@@ -174,27 +454,180 @@ func (c *Peer) Close() {
 	c.closed = true
 }
 
+// ID gives the unique id of the peer. The first peer is 1.
+func (c *Peer) ID() int {
+	return c.id
+}
+
+// Addr gives the address of the client, as "ip:port".
+func (c *Peer) Addr() string {
+	if c.conn == nil {
+		return ""
+	}
+	return c.conn.RemoteAddr().String()
+}
+
+// LocalAddr gives the address of our side of the connection, as "ip:port".
+func (c *Peer) LocalAddr() string {
+	if c.conn == nil {
+		return ""
+	}
+	return c.conn.LocalAddr().String()
+}
+
+// Created gives the time the peer connected.
+func (c *Peer) Created() time.Time {
+	return c.created
+}
+
+// LastCmd gives the lowercase name of the last command, and when it was
+// called.
+func (c *Peer) LastCmd() (string, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastCmd, c.lastTime
+}
+
+// SetReplyMode is CLIENT REPLY. Turning replies on takes effect right away,
+// so the reply to the command which does it is sent.
+func (c *Peer) SetReplyMode(mode ReplyMode) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replyMode = mode
+	if mode == ReplyOn {
+		c.silent = false
+	}
+}
+
+// Gone is closed when the connection is gone. Unlike the DisconnCB callbacks
+// that's right away, also when a command, a blocking one say, still runs.
+func (c *Peer) Gone() <-chan struct{} {
+	return c.gone
+}
+
+// Kill closes the connection right away. The disconnect callbacks will run.
+func (c *Peer) Kill() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// SetName sets the client name
+func (c *Peer) SetName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.name = name
+}
+
+// Name gives the client name, or "" if none was set
+func (c *Peer) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
+
+// SetResp3 switches the protocol of the replies to RESP3, or back to RESP2
+func (c *Peer) SetResp3(v bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resp3 = v
+}
+
+// Resp3 is true if the client switched to RESP3
+func (c *Peer) Resp3() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resp3
+}
+
+// Peers gives all connected peers, ordered by id.
+func (s *Server) Peers() []*Peer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*Peer, 0, len(s.peers))
+	for _, p := range s.peers {
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].id < res[j].id })
+	return res
+}
+
+// SetPreHook sets a callback which is called before every command, before
+// the command lookup and the arity check. If it returns true the command is
+// considered handled.
+func (s *Server) SetPreHook(cb Callback) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preHook = cb
+}
+
 func (s *Server) TotalCommands() int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	return s.CmdCnt
 }
 
-func (s *Server) Register(cmd string, f Cmd) error {
+// Register adds a command. arity, flags and the key positions are the same as
+// in the redis command table: arity counts the command name and is negative
+// for "at least", flags is a space separated list such as "write denyoom",
+// and firstKey, lastKey, and step give the key arguments (1 is the first
+// argument, a negative lastKey counts from the end). The dispatcher checks the
+// arity before calling f.
+func (s *Server) Register(cmd string, f Cmd, arity int, flags string, firstKey, lastKey, step int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	cmd = strings.ToUpper(cmd)
 	if _, ok := s.cmds[cmd]; ok {
 		return fmt.Errorf("command already registered: %s", cmd)
 	}
-	s.cmds[cmd] = f
+	fl := strings.Fields(flags)
+	for _, f := range fl {
+		if !knownFlags[f] {
+			return fmt.Errorf("unknown flag for %s: %s", cmd, f)
+		}
+	}
+	s.cmds[cmd] = &command{
+		fn: f,
+		spec: CmdSpec{
+			Name:     strings.ToLower(cmd),
+			Arity:    arity,
+			Flags:    fl,
+			FirstKey: firstKey,
+			LastKey:  lastKey,
+			Step:     step,
+		},
+	}
 	return nil
 }
 
+// Command gives the metadata of a registered command.
+func (s *Server) Command(cmd string) (CmdSpec, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cmds[strings.ToUpper(cmd)]
+	if !ok {
+		return CmdSpec{}, false
+	}
+	return c.spec, true
+}
+
+// Commands gives the metadata of all registered commands, sorted by name.
+func (s *Server) Commands() []CmdSpec {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var specs []CmdSpec
+	for _, c := range s.cmds {
+		specs = append(specs, c.spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs
+}
+
 // A Writer is given to the callback in Block()
 type Writer struct {
 	w     *bufio.Writer
 	resp3 bool
+	peer  *Peer // the peer, locked
 }
 
 func (c *Peer) WriteInline(s string) {
@@ -203,8 +636,47 @@ func (c *Peer) WriteInline(s string) {
 	})
 }
 
+// Push writes out-of-band data, such as pub/sub messages, and flushes it. It
+// never ends up in the middle of a reply: while a command runs the push is
+// held back until the command is done.
+func (c *Peer) Push(fn func(*Writer)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.busy {
+		w := bufio.NewWriter(&c.pending)
+		fn(&Writer{w, c.resp3, nil})
+		w.Flush()
+		return
+	}
+	fn(&Writer{c.writer, c.resp3, nil})
+	c.writer.Flush()
+}
+
+// done ends the current command, and writes the pushes held back during it.
+func (c *Peer) done() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.busy = false
+	c.writer.Write(c.pending.Bytes())
+	c.pending.Reset()
+}
+
+// Block locks the peer and hands a Writer to fn. Use it to write a reply
+// which consists of several parts.
 func (c *Peer) Block(fn func(*Writer)) {
-	fn(&Writer{c.writer, c.Resp3})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.silent {
+		fn(&Writer{bufio.NewWriter(io.Discard), c.resp3, c})
+		return
+	}
+	fn(&Writer{c.writer, c.resp3, c})
+}
+
+// Resp3 tells whether the peer talks RESP3, for replies which differ in more
+// than their encoding.
+func (w *Writer) Resp3() bool {
+	return w.resp3
 }
 
 // WriteInline writes a redis inline string
@@ -230,6 +702,10 @@ func (c *Peer) WriteError(e string) {
 }
 
 func (w *Writer) WriteError(e string) {
+	if w.peer != nil {
+		prefix, _, _ := strings.Cut(e, " ")
+		w.peer.errs = append(w.peer.errs, prefix)
+	}
 	fmt.Fprintf(w.w, "-%s\r\n", toInline(e))
 }
 
@@ -244,3 +720,233 @@ func (c *Peer) WriteBulk(s string) {
 func (w *Writer) WriteBulk(s string) {
 	fmt.Fprintf(w.w, "$%d\r\n%s\r\n", len(s), s)
 }
+
+// WriteOK writes the inline string `OK`
+func (c *Peer) WriteOK() {
+	c.WriteInline("OK")
+}
+
+// WriteNull writes a redis Null element
+func (c *Peer) WriteNull() {
+	c.Block(func(w *Writer) {
+		w.WriteNull()
+	})
+}
+
+// WriteNull writes a null bulk string, or a RESP3 null.
+func (w *Writer) WriteNull() {
+	if w.resp3 {
+		fmt.Fprint(w.w, "_\r\n")
+		return
+	}
+	fmt.Fprint(w.w, "$-1\r\n")
+}
+
+// WriteNullArray writes a null array, or a RESP3 null.
+func (c *Peer) WriteNullArray() {
+	c.Block(func(w *Writer) {
+		w.WriteNullArray()
+	})
+}
+
+// WriteNullArray writes a null array, or a RESP3 null.
+func (w *Writer) WriteNullArray() {
+	if w.resp3 {
+		fmt.Fprint(w.w, "_\r\n")
+		return
+	}
+	fmt.Fprint(w.w, "*-1\r\n")
+}
+
+// WriteLen starts an array with the given length
+func (c *Peer) WriteLen(n int) {
+	c.Block(func(w *Writer) {
+		w.WriteLen(n)
+	})
+}
+
+// WriteLen starts an array with the given length
+func (w *Writer) WriteLen(n int) {
+	fmt.Fprintf(w.w, "*%d\r\n", n)
+}
+
+// WriteMapLen starts a map with the given length (number of keys)
+func (c *Peer) WriteMapLen(n int) {
+	c.Block(func(w *Writer) {
+		w.WriteMapLen(n)
+	})
+}
+
+// WriteMapLen starts a map with the given length (number of keys). In RESP2
+// this is a flat array of key and value pairs.
+func (w *Writer) WriteMapLen(n int) {
+	if w.resp3 {
+		fmt.Fprintf(w.w, "%%%d\r\n", n)
+		return
+	}
+	w.WriteLen(n * 2)
+}
+
+// WriteSetLen starts a set with the given length (number of elements)
+func (c *Peer) WriteSetLen(n int) {
+	c.Block(func(w *Writer) {
+		w.WriteSetLen(n)
+	})
+}
+
+// WriteSetLen starts a set with the given length (number of elements)
+func (w *Writer) WriteSetLen(n int) {
+	if w.resp3 {
+		fmt.Fprintf(w.w, "~%d\r\n", n)
+		return
+	}
+	w.WriteLen(n)
+}
+
+// WritePushLen starts a push-data array with the given length
+func (c *Peer) WritePushLen(n int) {
+	c.Block(func(w *Writer) {
+		w.WritePushLen(n)
+	})
+}
+
+// WritePushLen starts a push-data array with the given length
+func (w *Writer) WritePushLen(n int) {
+	if w.resp3 {
+		fmt.Fprintf(w.w, ">%d\r\n", n)
+		return
+	}
+	w.WriteLen(n)
+}
+
+// WriteInt writes an integer
+func (c *Peer) WriteInt(i int) {
+	c.Block(func(w *Writer) {
+		w.WriteInt(i)
+	})
+}
+
+// WriteInt writes an integer
+func (w *Writer) WriteInt(i int) {
+	fmt.Fprintf(w.w, ":%d\r\n", i)
+}
+
+// WriteFloat writes a double. RESP2 has no double type, so it's written as
+// a bulk string there.
+func (c *Peer) WriteFloat(n float64) {
+	c.Block(func(w *Writer) {
+		w.WriteFloat(n)
+	})
+}
+
+// WriteFloat writes a double
+func (w *Writer) WriteFloat(n float64) {
+	if w.resp3 {
+		fmt.Fprintf(w.w, ",%s\r\n", FormatFloat(n))
+		return
+	}
+	w.WriteBulk(FormatFloat(n))
+}
+
+// WriteBool writes a boolean. RESP2 has no boolean type, it gets 1 or 0.
+func (c *Peer) WriteBool(b bool) {
+	c.Block(func(w *Writer) {
+		w.WriteBool(b)
+	})
+}
+
+// WriteBool writes a boolean
+func (w *Writer) WriteBool(b bool) {
+	if w.resp3 {
+		if b {
+			fmt.Fprint(w.w, "#t\r\n")
+		} else {
+			fmt.Fprint(w.w, "#f\r\n")
+		}
+		return
+	}
+	if b {
+		w.WriteInt(1)
+	} else {
+		w.WriteInt(0)
+	}
+}
+
+// WriteBigNumber writes a number which doesn't fit in a 64 bit integer. It's
+// a bulk string in RESP2.
+func (c *Peer) WriteBigNumber(n string) {
+	c.Block(func(w *Writer) {
+		w.WriteBigNumber(n)
+	})
+}
+
+// WriteBigNumber writes a big number
+func (w *Writer) WriteBigNumber(n string) {
+	if w.resp3 {
+		fmt.Fprintf(w.w, "(%s\r\n", n)
+		return
+	}
+	w.WriteBulk(n)
+}
+
+// WriteVerbatim writes a verbatim string with a three letter format, such as
+// "txt" or "mkd". It's a plain bulk string in RESP2.
+func (c *Peer) WriteVerbatim(format, s string) {
+	c.Block(func(w *Writer) {
+		w.WriteVerbatim(format, s)
+	})
+}
+
+// WriteVerbatim writes a verbatim string
+func (w *Writer) WriteVerbatim(format, s string) {
+	if w.resp3 {
+		fmt.Fprintf(w.w, "=%d\r\n%s:%s\r\n", len(format)+1+len(s), format, s)
+		return
+	}
+	w.WriteBulk(s)
+}
+
+// WriteStrings is a helper to (bulk)write a string list
+func (c *Peer) WriteStrings(strs []string) {
+	c.Block(func(w *Writer) {
+		w.WriteStrings(strs)
+	})
+}
+
+// WriteStrings writes a string list as an array of bulk strings
+func (w *Writer) WriteStrings(strs []string) {
+	w.WriteLen(len(strs))
+	for _, s := range strs {
+		w.WriteBulk(s)
+	}
+}
+
+// WriteRaw writes a raw redis response
+func (c *Peer) WriteRaw(s string) {
+	c.Block(func(w *Writer) {
+		w.WriteRaw(s)
+	})
+}
+
+// WriteRaw writes a raw redis response
+func (w *Writer) WriteRaw(s string) {
+	fmt.Fprint(w.w, s)
+}
+
+// FormatFloat formats a score or a double the way redis does ("%.17g"), but
+// with the shortest digits which read back to the same value: 1.5, 1000000,
+// 1e+300, 1e-05, and "inf"/"-inf".
+func FormatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "inf"
+	}
+	if math.IsInf(v, -1) {
+		return "-inf"
+	}
+	e := strconv.FormatFloat(v, 'e', -1, 64)
+	exp, _ := strconv.Atoi(e[strings.LastIndexByte(e, 'e')+1:])
+	if exp < -4 || exp >= 17 {
+		return e
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
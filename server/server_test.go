@@ -0,0 +1,313 @@
+package server
+
+import (
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testServer starts a server with the given commands, and gives a
+// connection to it.
+func testServer(t *testing.T, register func(s *Server)) (*Server, net.Conn) {
+	t.Helper()
+	s, err := NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	register(s)
+
+	c, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return s, c
+}
+
+// mustReply sends a command and compares the raw reply.
+func mustReply(t *testing.T, c net.Conn, want string, args ...string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+	if _, err := c.Write([]byte(b.String())); err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatalf("%q: %v (read %q)", args, err, got)
+	}
+	if string(got) != want {
+		t.Errorf("%q: got %q, want %q", args, got, want)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	for v, want := range map[float64]string{
+		0:               "0",
+		1:               "1",
+		-1.5:            "-1.5",
+		0.1:             "0.1",
+		3.14159:         "3.14159",
+		1000000:         "1000000",
+		1e16:            "10000000000000000",
+		1e17:            "1e+17",
+		1e300:           "1e+300",
+		-1e300:          "-1e+300",
+		0.0001:          "0.0001",
+		0.00001:         "1e-05",
+		1.5e-300:        "1.5e-300",
+		math.Inf(1):     "inf",
+		math.Inf(-1):    "-inf",
+		math.MaxFloat64: "1.7976931348623157e+308",
+	} {
+		if got := FormatFloat(v); got != want {
+			t.Errorf("FormatFloat(%v): got %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestWriters(t *testing.T) {
+	_, c := testServer(t, func(s *Server) {
+		s.Register("PROTO", func(c *Peer, cmd string, args []string) {
+			c.SetResp3(args[0] == "3")
+			c.WriteOK()
+		}, 2, "", 0, 0, 0)
+		s.Register("ALL", func(c *Peer, cmd string, args []string) {
+			c.Block(func(w *Writer) {
+				w.WriteLen(10)
+				w.WriteNull()
+				w.WriteNullArray()
+				w.WriteFloat(1e300)
+				w.WriteFloat(math.Inf(-1))
+				w.WriteBool(true)
+				w.WriteBigNumber("12345678901234567890")
+				w.WriteVerbatim("txt", "hi")
+				w.WriteMapLen(1)
+				w.WriteBulk("k")
+				w.WriteInt(1)
+				w.WriteSetLen(1)
+				w.WriteInline("a\nb")
+				w.WritePushLen(1)
+				w.WriteError("ERR oops")
+			})
+		}, 1, "", 0, 0, 0)
+	})
+
+	mustReply(t, c, "*10\r\n"+
+		"$-1\r\n"+
+		"*-1\r\n"+
+		"$6\r\n1e+300\r\n"+
+		"$4\r\n-inf\r\n"+
+		":1\r\n"+
+		"$20\r\n12345678901234567890\r\n"+
+		"$2\r\nhi\r\n"+
+		"*2\r\n$1\r\nk\r\n:1\r\n"+
+		"*1\r\n+a b\r\n"+
+		"*1\r\n-ERR oops\r\n",
+		"ALL")
+
+	mustReply(t, c, "+OK\r\n", "PROTO", "3")
+	mustReply(t, c, "*10\r\n"+
+		"_\r\n"+
+		"_\r\n"+
+		",1e+300\r\n"+
+		",-inf\r\n"+
+		"#t\r\n"+
+		"(12345678901234567890\r\n"+
+		"=6\r\ntxt:hi\r\n"+
+		"%1\r\n$1\r\nk\r\n:1\r\n"+
+		"~1\r\n+a b\r\n"+
+		">1\r\n-ERR oops\r\n",
+		"ALL")
+}
+
+func TestDispatchErrors(t *testing.T) {
+	s, c := testServer(t, func(s *Server) {
+		s.Register("ECHO", func(c *Peer, cmd string, args []string) {
+			c.WriteBulk(args[0])
+		}, 2, "fast", 0, 0, 0)
+	})
+
+	mustReply(t, c, "$2\r\nhi\r\n", "echo", "hi")
+	mustReply(t, c, "-ERR unknown command 'foo', with args beginning with: 'a' 'b c' \r\n", "foo", "a", "b c")
+	mustReply(t, c, "-ERR unknown command 'foo', with args beginning with: \r\n", "foo")
+	mustReply(t, c, "-ERR unknown command 'foo', with args beginning with: '"+strings.Repeat("x", 128)+"' \r\n", "foo", strings.Repeat("x", 200), "y")
+	mustReply(t, c, "-ERR wrong number of arguments for 'echo' command\r\n", "ECHO")
+	mustReply(t, c, "-ERR wrong number of arguments for 'echo' command\r\n", "echo", "a", "b")
+
+	st := s.CommandStats()["echo"]
+	if st.Calls != 1 || st.RejectedCalls != 2 {
+		t.Errorf("stats: %+v", st)
+	}
+	if got := s.ErrorStats()["ERR"]; got != 5 {
+		t.Errorf("error stats: got %d, want 5", got)
+	}
+}
+
+func TestCmdSpec(t *testing.T) {
+	s, err := NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Register("MSET", func(*Peer, string, []string) {}, -3, "write denyoom", 1, -1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("MSET", func(*Peer, string, []string) {}, -3, "write", 1, -1, 2); err == nil {
+		t.Error("registering twice: no error")
+	}
+	if err := s.Register("BAD", func(*Peer, string, []string) {}, 1, "nosuchflag", 0, 0, 0); err == nil {
+		t.Error("unknown flag: no error")
+	}
+
+	cs, ok := s.Command("mset")
+	if !ok {
+		t.Fatal("no mset")
+	}
+	if cs.Name != "mset" || !cs.HasFlag("denyoom") || cs.HasFlag("fast") {
+		t.Errorf("spec: %+v", cs)
+	}
+	for argc, want := range map[int]bool{1: false, 2: false, 3: true, 5: true} {
+		if got := cs.ArityOK(argc); got != want {
+			t.Errorf("ArityOK(%d): got %t", argc, got)
+		}
+	}
+	keys, ok := cs.Keys([]string{"a", "1", "b", "2"})
+	if !ok || strings.Join(keys, ",") != "a,b" {
+		t.Errorf("Keys: %q %t", keys, ok)
+	}
+	if _, ok := (CmdSpec{Flags: []string{"movablekeys"}}).Keys([]string{"a"}); ok {
+		t.Error("Keys of a movablekeys command: ok")
+	}
+}
+
+func TestPush(t *testing.T) {
+	push := func(c *Peer) {
+		c.Push(func(w *Writer) {
+			w.WritePushLen(1)
+			w.WriteBulk("push")
+		})
+	}
+	s, c := testServer(t, func(s *Server) {
+		s.Register("PARTS", func(c *Peer, cmd string, args []string) {
+			c.WriteLen(2)
+			done := make(chan struct{})
+			go func() {
+				push(c)
+				close(done)
+			}()
+			<-done
+			c.WriteBulk("a")
+			c.WriteBulk("b")
+		}, 1, "", 0, 0, 0)
+		s.Register("PROTO", func(c *Peer, cmd string, args []string) {
+			c.SetResp3(args[0] == "3")
+			c.WriteOK()
+		}, 2, "", 0, 0, 0)
+	})
+
+	// a push while a command runs waits for its reply
+	mustReply(t, c, "*2\r\n$1\r\na\r\n$1\r\nb\r\n*1\r\n$4\r\npush\r\n", "PARTS")
+	mustReply(t, c, "+OK\r\n", "PROTO", "3")
+	mustReply(t, c, "*2\r\n$1\r\na\r\n$1\r\nb\r\n>1\r\n$4\r\npush\r\n", "PARTS")
+
+	// otherwise it's written right away
+	push(s.Peers()[0])
+	got := make([]byte, len(">1\r\n$4\r\npush\r\n"))
+	c.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadFull(c, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != ">1\r\n$4\r\npush\r\n" {
+		t.Errorf("push: got %q", got)
+	}
+}
+
+func TestGone(t *testing.T) {
+	gone := make(chan bool, 1)
+	_, c := testServer(t, func(s *Server) {
+		s.Register("WAIT", func(c *Peer, cmd string, args []string) {
+			select {
+			case <-c.Gone():
+				gone <- true
+			case <-time.After(3 * time.Second):
+				gone <- false
+			}
+		}, 1, "", 0, 0, 0)
+	})
+
+	if _, err := c.Write([]byte("*1\r\n$4\r\nWAIT\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	c.Close()
+	if !<-gone {
+		t.Error("Gone isn't closed while the command runs")
+	}
+}
+
+func TestPeers(t *testing.T) {
+	s, c := testServer(t, func(s *Server) {
+		s.Register("ECHO", func(c *Peer, cmd string, args []string) {
+			c.WriteBulk(args[0])
+		}, 2, "", 0, 0, 0)
+		s.Register("REPLY", func(c *Peer, cmd string, args []string) {
+			c.SetReplyMode(map[string]ReplyMode{"on": ReplyOn, "off": ReplyOff, "skip": ReplySkip}[args[0]])
+			c.WriteOK()
+		}, 2, "", 0, 0, 0)
+	})
+	c2, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	mustReply(t, c2, "$1\r\na\r\n", "ECHO", "a")
+	mustReply(t, c, "$1\r\nb\r\n", "ECHO", "b")
+
+	peers := s.Peers()
+	if len(peers) != 2 || peers[0].ID() != 1 || peers[1].ID() != 2 {
+		t.Fatalf("Peers: got %v", peers)
+	}
+	p := peers[0]
+	if p.Addr() != c.LocalAddr().String() || p.LocalAddr() != c.RemoteAddr().String() {
+		t.Errorf("addresses: got %s %s", p.Addr(), p.LocalAddr())
+	}
+	if cmd, last := p.LastCmd(); cmd != "echo" || last.Before(p.Created()) {
+		t.Errorf("LastCmd: got %q %s", cmd, last)
+	}
+	p.SetName("x")
+	if p.Name() != "x" {
+		t.Errorf("Name: got %q", p.Name())
+	}
+
+	// replies are dropped from the next command on, ON takes effect right
+	// away
+	send := func(args ...string) {
+		var b strings.Builder
+		b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+		for _, a := range args {
+			b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+		}
+		if _, err := c.Write([]byte(b.String())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustReply(t, c, "+OK\r\n", "REPLY", "skip")
+	send("ECHO", "skipped")
+	mustReply(t, c, "$1\r\nc\r\n", "ECHO", "c")
+	mustReply(t, c, "+OK\r\n", "REPLY", "off")
+	send("ECHO", "off")
+	mustReply(t, c, "+OK\r\n", "REPLY", "on")
+	mustReply(t, c, "$1\r\nd\r\n", "ECHO", "d")
+}